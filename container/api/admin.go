@@ -0,0 +1,71 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/api/idtoken"
+)
+
+// AdminAuth restricts the management API, which changes instances and enforcement, to an allow-list of admins.
+// Admins are identified by the email of the Google ID token the request is authorized with. Cloud Run verifies the
+// token and its audience before the request reaches the service, so the token is only validated again here to trust
+// its claims.
+type AdminAuth struct {
+	logger   logrus.FieldLogger
+	admins   map[string]struct{}
+	validate func(ctx context.Context, token, audience string) (*idtoken.Payload, error)
+}
+
+// NewAdminAuth returns an AdminAuth allowing the admins, as emails of users or service accounts. No caller is
+// allowed when admins is empty.
+func NewAdminAuth(admins []string) *AdminAuth {
+	a := &AdminAuth{
+		logger:   logrus.StandardLogger(),
+		admins:   make(map[string]struct{}, len(admins)),
+		validate: idtoken.Validate,
+	}
+	for _, admin := range admins {
+		a.admins[strings.ToLower(strings.TrimSpace(admin))] = struct{}{}
+	}
+	return a
+}
+
+// Wrap returns a handler calling next only for requests of admins, and responding with 401 Unauthorized without a
+// valid ID token or 403 Forbidden to other callers.
+func (a *AdminAuth) Wrap(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := a.logger.WithFields(logrus.Fields{
+			"method": r.Method,
+			"path":   r.URL.Path,
+		})
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			log.Warn("management request without an ID token")
+			http.Error(w, "missing ID token", http.StatusUnauthorized)
+			return
+		}
+
+		payload, err := a.validate(r.Context(), token, "")
+		if err != nil {
+			log.WithError(err).Warn("management request with an invalid ID token")
+			http.Error(w, "invalid ID token", http.StatusUnauthorized)
+			return
+		}
+
+		email, _ := payload.Claims["email"].(string)
+		verified, _ := payload.Claims["email_verified"].(bool)
+		log = log.WithField("caller", email)
+		if _, ok := a.admins[strings.ToLower(email)]; !ok || !verified {
+			log.Warn("management request of a caller which is not an admin")
+			http.Error(w, "caller is not an admin", http.StatusForbidden)
+			return
+		}
+
+		log.Info("management request of an admin")
+		next(w, r)
+	}
+}
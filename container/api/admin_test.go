@@ -0,0 +1,96 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/idtoken"
+)
+
+func TestAdminAuth(t *testing.T) {
+	t.Parallel()
+
+	tokens := map[string]map[string]any{
+		"admin":      {"email": "Admin@example.com", "email_verified": true},
+		"unverified": {"email": "admin@example.com", "email_verified": false},
+		"other":      {"email": "other@example.com", "email_verified": true},
+		"eventarc":   {"email": "validator@project.iam.gserviceaccount.com", "email_verified": true},
+	}
+
+	tests := []struct {
+		name          string
+		admins        []string
+		authorization string
+		wantStatus    int
+	}{
+		{
+			name:          "admin",
+			admins:        []string{"admin@example.com"},
+			authorization: "Bearer admin",
+			wantStatus:    http.StatusOK,
+		},
+		{
+			name:       "no ID token",
+			admins:     []string{"admin@example.com"},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:          "invalid ID token",
+			admins:        []string{"admin@example.com"},
+			authorization: "Bearer forged",
+			wantStatus:    http.StatusUnauthorized,
+		},
+		{
+			name:          "unverified email",
+			admins:        []string{"admin@example.com"},
+			authorization: "Bearer unverified",
+			wantStatus:    http.StatusForbidden,
+		},
+		{
+			name:          "caller allowed to invoke the service but not an admin",
+			admins:        []string{"admin@example.com"},
+			authorization: "Bearer eventarc",
+			wantStatus:    http.StatusForbidden,
+		},
+		{
+			name:          "no admins",
+			authorization: "Bearer other",
+			wantStatus:    http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			auth := NewAdminAuth(tt.admins)
+			auth.validate = func(_ context.Context, token, _ string) (*idtoken.Payload, error) {
+				claims, ok := tokens[token]
+				if !ok {
+					return nil, errors.New("invalid token")
+				}
+				return &idtoken.Payload{Claims: claims}, nil
+			}
+
+			called := false
+			handler := auth.Wrap(func(w http.ResponseWriter, _ *http.Request) {
+				called = true
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/breaker/reset", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+
+			require.Equal(t, tt.wantStatus, rec.Code)
+			require.Equal(t, tt.wantStatus == http.StatusOK, called)
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package api
+
+type auditLogLabel struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type AuditLog struct {
+	InsertID     string `json:"insertId"`
+	ProtoPayload struct {
+		ServiceName        string `json:"serviceName"`
+		MethodName         string `json:"methodName"`
+		ResourceName       string `json:"resourceName"`
+		AuthenticationInfo struct {
+			PrincipalEmail string `json:"principalEmail"`
+		} `json:"authenticationInfo"`
+		RequestMetadata struct {
+			CallerIP string `json:"callerIp"`
+		} `json:"requestMetadata"`
+		Status *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"status"`
+		Request struct {
+			Labels []auditLogLabel `json:"labels"`
+		} `json:"request"`
+		// Response is the compute operation started by the request.
+		Response struct {
+			ID       string `json:"id"`
+			TargetID string `json:"targetId"`
+			Status   string `json:"status"`
+			Error    *struct {
+				Errors []struct {
+					Code    string `json:"code"`
+					Message string `json:"message"`
+				} `json:"errors"`
+			} `json:"error"`
+		} `json:"response"`
+	} `json:"protoPayload"`
+	// Operation groups the audit logs of a long-running operation, which are written when the operation starts
+	// and when it completes.
+	Operation struct {
+		ID       string `json:"id"`
+		Producer string `json:"producer"`
+		First    bool   `json:"first"`
+		Last     bool   `json:"last"`
+	} `json:"operation"`
+	Resource struct {
+		Type   string `json:"type"`
+		Labels struct {
+			ProjectID string `json:"project_id"`
+		} `json:"labels"`
+	} `json:"resource"`
+
+	// raw is the audit log as delivered.
+	raw []byte
+}
+
+// labels returns the labels of the inserted instance, as requested. Audit logs of completed inserts have none.
+func (l *AuditLog) labels() map[string]string {
+	labels := make(map[string]string, len(l.ProtoPayload.Request.Labels))
+	for _, label := range l.ProtoPayload.Request.Labels {
+		labels[label.Key] = label.Value
+	}
+	return labels
+}
+
+// principal returns the email of the principal which inserted the instance.
+func (l *AuditLog) principal() string {
+	return l.ProtoPayload.AuthenticationInfo.PrincipalEmail
+}
+
+// skip reports whether the audit log does not need to be processed, and why.
+// An insert writes one audit log when the operation starts and another when it completes. Only the last one
+// is processed, as the instance is readable by then and the outcome of the insert is known.
+func (l *AuditLog) skip() (bool, string) {
+	if l.Operation.ID != "" && !l.Operation.Last {
+		return true, "operation not completed"
+	}
+
+	if l.ProtoPayload.Status != nil && l.ProtoPayload.Status.Code != 0 {
+		return true, "operation failed"
+	}
+
+	if l.ProtoPayload.Response.Error != nil && len(l.ProtoPayload.Response.Error.Errors) > 0 {
+		return true, "operation failed"
+	}
+
+	return false, ""
+}
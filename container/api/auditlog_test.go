@@ -0,0 +1,70 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditLogSkip(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		payload string
+		skip    bool
+	}{
+		{
+			name: "operation completed",
+			payload: `{
+				"protoPayload": {
+					"authenticationInfo": {"principalEmail": "castai@project.iam.gserviceaccount.com"},
+					"requestMetadata": {"callerIp": "10.0.0.1"},
+					"response": {"id": "123", "targetId": "456", "status": "DONE"}
+				},
+				"operation": {"id": "operation-1", "producer": "compute.googleapis.com", "last": true}
+			}`,
+		},
+		{
+			name: "operation started",
+			payload: `{
+				"protoPayload": {"response": {"id": "123", "targetId": "456", "status": "RUNNING"}},
+				"operation": {"id": "operation-1", "producer": "compute.googleapis.com", "first": true}
+			}`,
+			skip: true,
+		},
+		{
+			name: "operation failed",
+			payload: `{
+				"protoPayload": {"status": {"code": 3, "message": "INVALID_ARGUMENT"}},
+				"operation": {"id": "operation-1", "producer": "compute.googleapis.com", "last": true}
+			}`,
+			skip: true,
+		},
+		{
+			name: "operation completed with errors",
+			payload: `{
+				"protoPayload": {"response": {"status": "DONE", "error": {"errors": [{"code": "ZONE_RESOURCE_POOL_EXHAUSTED"}]}}},
+				"operation": {"id": "operation-1", "producer": "compute.googleapis.com", "last": true}
+			}`,
+			skip: true,
+		},
+		{
+			name:    "no operation",
+			payload: `{"protoPayload": {"resourceName": "projects/p/zones/z/instances/i"}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := require.New(t)
+
+			var logEntry AuditLog
+			r.NoError(json.Unmarshal([]byte(tt.payload), &logEntry))
+
+			skip, reason := logEntry.skip()
+			r.Equal(tt.skip, skip)
+			r.Equal(tt.skip, reason != "")
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/castai/gcp-node-validator/container/enforce"
+	"github.com/sirupsen/logrus"
+)
+
+// BreakerHandler reports and resets the enforcement circuit breaker.
+type BreakerHandler struct {
+	logger  logrus.FieldLogger
+	breaker *enforce.Breaker
+}
+
+func NewBreakerHandler(breaker *enforce.Breaker) *BreakerHandler {
+	return &BreakerHandler{
+		logger:  logrus.StandardLogger(),
+		breaker: breaker,
+	}
+}
+
+type breakerResponse struct {
+	Trips []enforce.Trip `json:"trips"`
+}
+
+// HandleStatus responds with the tripped clusters.
+func (h *BreakerHandler) HandleStatus(w http.ResponseWriter, r *http.Request) {
+	trips, err := h.breaker.Trips(r.Context())
+	if err != nil {
+		h.logger.WithError(err).Error("failed to load breaker trips")
+		http.Error(w, "failed to load breaker trips", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeTrips(w, trips)
+}
+
+// HandleReset resets the breaker of the cluster query parameter, or of all clusters without it, and responds
+// with the reset trips.
+func (h *BreakerHandler) HandleReset(w http.ResponseWriter, r *http.Request) {
+	clusterID := r.URL.Query().Get("cluster")
+	log := h.logger.WithField("clusterID", clusterID)
+
+	trips, err := h.breaker.Reset(r.Context(), clusterID)
+	if err != nil {
+		log.WithError(err).Error("failed to reset breaker")
+		http.Error(w, "failed to reset breaker", http.StatusInternalServerError)
+		return
+	}
+
+	for _, trip := range trips {
+		log.WithFields(logrus.Fields{
+			"clusterID": trip.ClusterID,
+			"reason":    trip.Reason,
+		}).Warn("enforcement circuit breaker reset")
+	}
+
+	h.writeTrips(w, trips)
+}
+
+func (h *BreakerHandler) writeTrips(w http.ResponseWriter, trips []enforce.Trip) {
+	if trips == nil {
+		trips = []enforce.Trip{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(breakerResponse{Trips: trips}); err != nil {
+		h.logger.WithError(err).Errorf("failed to write response")
+	}
+}
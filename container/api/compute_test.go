@@ -0,0 +1,86 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/castai/gcp-node-validator/container/findings"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/option"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// fakeInstances serves instances.get of the Compute Engine REST API. The instance is not found for the first
+// notFound reads, and every read fails with the status when it is set.
+type fakeInstances struct {
+	t *testing.T
+
+	mu       sync.Mutex
+	instance *computepb.Instance
+	notFound int
+	status   int
+	reads    int
+}
+
+func newFakeInstances(t *testing.T, f *fakeInstances) *compute.InstancesClient {
+	t.Helper()
+	f.t = t
+
+	srv := httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	t.Cleanup(srv.Close)
+
+	client, err := compute.NewInstancesRESTClient(context.Background(), option.WithEndpoint(srv.URL), option.WithoutAuthentication())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+
+	return client
+}
+
+func (f *fakeInstances) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.reads++
+
+	switch {
+	case f.status != 0:
+		http.Error(w, fmt.Sprintf(`{"error": {"code": %d, "message": "failed"}}`, f.status), f.status)
+	case f.reads <= f.notFound, f.instance == nil, !strings.HasSuffix(r.URL.Path, "/instances/"+f.instance.GetName()):
+		http.Error(w, `{"error": {"code": 404, "message": "not found"}}`, http.StatusNotFound)
+	default:
+		data, err := protojson.Marshal(f.instance)
+		require.NoError(f.t, err)
+		_, _ = w.Write(data)
+	}
+}
+
+func (f *fakeInstances) readCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.reads
+}
+
+// recordingSink records the reported validation results.
+type recordingSink struct {
+	mu      sync.Mutex
+	results []*findings.ValidationResult
+}
+
+func (s *recordingSink) Report(_ context.Context, result *findings.ValidationResult) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results = append(s.results, result)
+	return nil
+}
+
+func (s *recordingSink) reported() []*findings.ValidationResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.results
+}
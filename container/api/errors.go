@@ -0,0 +1,42 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/castai/gcp-node-validator/container/gcperr"
+	"github.com/castai/gcp-node-validator/container/queue"
+)
+
+var (
+	errInvalidRequest      = errors.New("invalid request")
+	errInstanceNotReadable = errors.New("instance not readable")
+)
+
+// responseStatusForError maps a processing error to the HTTP status returned to Eventarc.
+// Eventarc redelivers events answered with 429 or 5xx, so only transient failures map to those codes.
+// Permanent failures are acknowledged, otherwise the event would be retried until it expires.
+func responseStatusForError(err error) int {
+	switch {
+	case err == nil:
+		return http.StatusOK
+	case errors.Is(err, errInvalidRequest):
+		return http.StatusBadRequest
+	case gcperr.IsRateLimited(err), errors.Is(err, queue.ErrFull):
+		return http.StatusTooManyRequests
+	case isTransient(err):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusOK
+	}
+}
+
+// isTransient reports whether processing that failed with err can succeed when retried.
+func isTransient(err error) bool {
+	// Queued events are rejected while the queue is full or the service is shutting down.
+	if errors.Is(err, queue.ErrFull) || errors.Is(err, queue.ErrClosed) {
+		return true
+	}
+
+	return gcperr.IsTransient(err)
+}
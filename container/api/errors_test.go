@@ -0,0 +1,97 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/castai/gcp-node-validator/container/queue"
+	"github.com/googleapis/gax-go/v2/apierror"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func httpAPIError(t *testing.T, code int) error {
+	t.Helper()
+	err, ok := apierror.FromError(&googleapi.Error{Code: code})
+	require.True(t, ok)
+	return err
+}
+
+func TestResponseStatusForError(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		err  func(t *testing.T) error
+		want int
+	}{
+		{
+			name: "no error",
+			err:  func(t *testing.T) error { return nil },
+			want: http.StatusOK,
+		},
+		{
+			name: "invalid request",
+			err:  func(t *testing.T) error { return errInvalidRequest },
+			want: http.StatusBadRequest,
+		},
+		{
+			name: "http unavailable",
+			err: func(t *testing.T) error {
+				return fmt.Errorf("failed to get instance: %w", httpAPIError(t, http.StatusServiceUnavailable))
+			},
+			want: http.StatusServiceUnavailable,
+		},
+		{
+			name: "http too many requests",
+			err:  func(t *testing.T) error { return httpAPIError(t, http.StatusTooManyRequests) },
+			want: http.StatusTooManyRequests,
+		},
+		{
+			name: "http forbidden",
+			err:  func(t *testing.T) error { return httpAPIError(t, http.StatusForbidden) },
+			want: http.StatusOK,
+		},
+		{
+			name: "grpc unavailable",
+			err:  func(t *testing.T) error { return status.Error(codes.Unavailable, "unavailable") },
+			want: http.StatusServiceUnavailable,
+		},
+		{
+			name: "grpc resource exhausted",
+			err:  func(t *testing.T) error { return status.Error(codes.ResourceExhausted, "quota") },
+			want: http.StatusTooManyRequests,
+		},
+		{
+			name: "deadline exceeded",
+			err:  func(t *testing.T) error { return fmt.Errorf("failed to get whitelist: %w", context.DeadlineExceeded) },
+			want: http.StatusServiceUnavailable,
+		},
+		{
+			name: "queue full",
+			err:  func(t *testing.T) error { return fmt.Errorf("failed to enqueue audit log: %w", queue.ErrFull) },
+			want: http.StatusTooManyRequests,
+		},
+		{
+			name: "queue closed",
+			err:  func(t *testing.T) error { return queue.ErrClosed },
+			want: http.StatusServiceUnavailable,
+		},
+		{
+			name: "permanent error",
+			err:  func(t *testing.T) error { return errors.New("node pool name not found") },
+			want: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := require.New(t)
+			r.Equal(tt.want, responseStatusForError(tt.err(t)))
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/castai/gcp-node-validator/container/findings"
+	"github.com/castai/gcp-node-validator/container/validate"
+	"github.com/sirupsen/logrus"
+)
+
+// FindingsHandler queries the stored validation results.
+type FindingsHandler struct {
+	logger logrus.FieldLogger
+	store  findings.Store
+}
+
+func NewFindingsHandler(store findings.Store) *FindingsHandler {
+	return &FindingsHandler{
+		logger: logrus.StandardLogger(),
+		store:  store,
+	}
+}
+
+type findingsResponse struct {
+	Results []*findings.ValidationResult `json:"results"`
+}
+
+type instanceResponse struct {
+	Latest  *findings.ValidationResult   `json:"latest"`
+	History []*findings.ValidationResult `json:"history"`
+}
+
+// HandleList responds with the results matching the cluster, verdict, instance, since, until and limit query
+// parameters, newest first. Times are formatted as RFC 3339.
+func (h *FindingsHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	query, err := parseFindingsQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	results, err := h.store.Query(r.Context(), query)
+	if err != nil {
+		h.logger.WithError(err).Error("failed to query findings")
+		http.Error(w, "failed to query findings", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeJSON(w, findingsResponse{Results: results})
+}
+
+// HandleInstance responds with the latest result and the history of the instance identified by the name path value.
+func (h *FindingsHandler) HandleInstance(w http.ResponseWriter, r *http.Request) {
+	query, err := parseFindingsQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	query.Instance = r.PathValue("name")
+
+	history, err := h.store.Query(r.Context(), query)
+	if err != nil {
+		h.logger.WithError(err).WithField("instanceName", query.Instance).Error("failed to query findings")
+		http.Error(w, "failed to query findings", http.StatusInternalServerError)
+		return
+	}
+	if len(history) == 0 {
+		http.Error(w, fmt.Sprintf("no results of instance %s", query.Instance), http.StatusNotFound)
+		return
+	}
+
+	h.writeJSON(w, instanceResponse{Latest: history[0], History: history})
+}
+
+func (h *FindingsHandler) writeJSON(w http.ResponseWriter, response any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.logger.WithError(err).Errorf("failed to write response")
+	}
+}
+
+func parseFindingsQuery(values url.Values) (*findings.Query, error) {
+	query := &findings.Query{
+		Cluster:  values.Get("cluster"),
+		Verdict:  validate.Verdict(values.Get("verdict")),
+		Instance: values.Get("instance"),
+	}
+
+	switch query.Verdict {
+	case "", validate.VerdictValid, validate.VerdictInvalid, validate.VerdictUnverifiable:
+	default:
+		return nil, fmt.Errorf("invalid verdict %q, expected %s, %s or %s", query.Verdict, validate.VerdictValid, validate.VerdictInvalid, validate.VerdictUnverifiable)
+	}
+
+	for name, t := range map[string]*time.Time{"since": &query.Since, "until": &query.Until} {
+		value := values.Get(name)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s, expected an RFC 3339 time: %w", name, err)
+		}
+		*t = parsed
+	}
+
+	if value := values.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > findings.MaxLimit {
+			return nil, fmt.Errorf("invalid limit %q, expected 1 to %d", value, findings.MaxLimit)
+		}
+		query.Limit = limit
+	}
+
+	return query, nil
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/castai/gcp-node-validator/container/findings"
+	"github.com/castai/gcp-node-validator/container/validate"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindingsHandler(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	store, err := findings.OpenSQLStore(ctx, findings.DriverSQLite, filepath.Join(t.TempDir(), "findings.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+
+	for i, result := range []*findings.ValidationResult{
+		{Verdict: validate.VerdictValid, Instance: "node-1", Cluster: "cluster-1"},
+		{Verdict: validate.VerdictInvalid, Instance: "node-2", Cluster: "cluster-1"},
+		{Verdict: validate.VerdictInvalid, Instance: "node-2", Cluster: "cluster-1", Action: &findings.Action{Name: "delete", Result: "applied"}},
+		{Verdict: validate.VerdictUnverifiable, Instance: "node-3", Cluster: "cluster-2"},
+	} {
+		result.SchemaVersion = findings.SchemaVersion
+		result.ObservedAt = start.Add(time.Duration(i) * time.Minute)
+		require.NoError(t, store.Report(ctx, result))
+	}
+
+	handler := NewFindingsHandler(store)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/findings", handler.HandleList)
+	mux.HandleFunc("GET /api/v1/instances/{name}", handler.HandleInstance)
+
+	tests := []struct {
+		name          string
+		url           string
+		wantStatus    int
+		wantInstances []string
+		wantLatest    *findings.Action
+	}{
+		{
+			name:          "all findings",
+			url:           "/api/v1/findings",
+			wantStatus:    http.StatusOK,
+			wantInstances: []string{"node-3", "node-2", "node-2", "node-1"},
+		},
+		{
+			name:          "filters",
+			url:           "/api/v1/findings?cluster=cluster-1&verdict=invalid&since=2024-01-01T00:02:00Z&until=2024-01-01T01:00:00Z",
+			wantStatus:    http.StatusOK,
+			wantInstances: []string{"node-2"},
+		},
+		{
+			name:          "limit",
+			url:           "/api/v1/findings?instance=node-2&limit=1",
+			wantStatus:    http.StatusOK,
+			wantInstances: []string{"node-2"},
+		},
+		{
+			name:          "no findings",
+			url:           "/api/v1/findings?cluster=cluster-3",
+			wantStatus:    http.StatusOK,
+			wantInstances: []string{},
+		},
+		{
+			name:       "invalid verdict",
+			url:        "/api/v1/findings?verdict=bad",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid time",
+			url:        "/api/v1/findings?since=yesterday",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid limit",
+			url:        "/api/v1/findings?limit=0",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:          "instance",
+			url:           "/api/v1/instances/node-2",
+			wantStatus:    http.StatusOK,
+			wantInstances: []string{"node-2", "node-2"},
+			wantLatest:    &findings.Action{Name: "delete", Result: "applied"},
+		},
+		{
+			name:       "unknown instance",
+			url:        "/api/v1/instances/node-4",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			r := require.New(t)
+
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.url, nil))
+			r.Equal(tt.wantStatus, rec.Code, rec.Body.String())
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var response struct {
+				Results []*findings.ValidationResult `json:"results"`
+				Latest  *findings.ValidationResult   `json:"latest"`
+				History []*findings.ValidationResult `json:"history"`
+			}
+			r.NoError(json.Unmarshal(rec.Body.Bytes(), &response))
+			results := response.Results
+			if response.Latest != nil {
+				results = response.History
+				r.Equal(tt.wantLatest, response.Latest.Action)
+			}
+
+			instances := []string{}
+			for _, result := range results {
+				instances = append(instances, result.Instance)
+			}
+			r.Equal(tt.wantInstances, instances)
+		})
+	}
+}
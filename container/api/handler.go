@@ -6,60 +6,183 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"net/http"
 	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	compute "cloud.google.com/go/compute/apiv1"
 	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/castai/gcp-node-validator/container/dedup"
+	"github.com/castai/gcp-node-validator/container/enforce"
+	"github.com/castai/gcp-node-validator/container/evidence"
+	"github.com/castai/gcp-node-validator/container/exempt"
+	"github.com/castai/gcp-node-validator/container/findings"
+	"github.com/castai/gcp-node-validator/container/gcperr"
+	"github.com/castai/gcp-node-validator/container/kube"
+	"github.com/castai/gcp-node-validator/container/logging"
+	"github.com/castai/gcp-node-validator/container/metrics"
+	"github.com/castai/gcp-node-validator/container/pending"
+	"github.com/castai/gcp-node-validator/container/queue"
+	"github.com/castai/gcp-node-validator/container/tracing"
 	"github.com/castai/gcp-node-validator/container/validate"
 	"github.com/samber/lo"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
+	cloudEventIDHeader = "Ce-Id"
+
+	defaultInstanceWaitTimeout = 2 * time.Minute
+	maxInstanceWaitBackoff     = 15 * time.Second
+
+	castManagedByLabel = "cast-managed-by"
 	castClusterIDLabel = "cast-cluster-id"
 	clusterNameLabel   = "goog-k8s-cluster-name"
+	nodePoolNameLabel  = "goog-k8s-node-pool-name"
+	// castValidationApprovedLabel is added by operators to approve an instance, cancelling its enforcement.
+	castValidationApprovedLabel = "cast-validation-approved"
 )
 
-type AuditLog struct {
-	ProtoPayload struct {
-		ServiceName  string `json:"serviceName"`
-		MethodName   string `json:"methodName"`
-		ResourceName string `json:"resourceName"`
-	} `json:"protoPayload"`
-	Resource struct {
-		Type   string `json:"type"`
-		Labels struct {
-			ProjectID string `json:"project_id"`
-		} `json:"labels"`
-	} `json:"resource"`
-}
-
 type Handler struct {
-	logger        logrus.FieldLogger
+	logger        *logrus.Logger
 	projectID     string
 	computeClient *compute.InstancesClient
 
-	clusterIDs    []string
-	deleteInvalid bool
+	clusterIDs []string
+	// policy resolves the action enforced on invalid instances, per cluster.
+	policy *enforce.Policy
 
 	validator *validate.InstanceValidator
+
+	dedupStore dedup.Store
+	dedupTTL   time.Duration
+
+	queue       *queue.Queue
+	jobTimeout  time.Duration
+	maxAttempts int
+	// poolClusters maps the node pools of the CAST instances read, by name prefix, to their CAST labels. The audit
+	// logs of completed inserts carry no labels, so their cluster is only known from earlier instances of the pool.
+	poolClusters sync.Map
+
+	instanceWaitTimeout time.Duration
+	// instanceWaitBackoff is the initial backoff between reads of an inserted instance.
+	instanceWaitBackoff time.Duration
+
+	evidence *evidence.Collector
+	drainer  *kube.Drainer
+	breaker  *enforce.Breaker
+	// exceptions exempt instances from validation.
+	exceptions *exempt.Registry
+	// scheduler delays enforcement by a grace period, when set.
+	scheduler *pending.Scheduler
+	// sinks receive the validation results.
+	sinks []findings.Sink
+}
+
+type HandlerOption func(*Handler)
+
+// WithDeduplication makes the handler process each event, and each instance metadata revision, only once.
+func WithDeduplication(store dedup.Store, ttl time.Duration) HandlerOption {
+	return func(h *Handler) {
+		h.dedupStore = store
+		h.dedupTTL = ttl
+	}
+}
+
+// WithQueue makes the handler acknowledge events once they are queued and process them on the queue workers.
+// Jobs failing with transient errors are retried up to maxAttempts times, all attempts bounded by jobTimeout.
+// Queued events are processed at most once, as Eventarc does not redeliver acknowledged events; events which can
+// not be queued are not acknowledged, so that they are redelivered.
+func WithQueue(q *queue.Queue, jobTimeout time.Duration, maxAttempts int) HandlerOption {
+	return func(h *Handler) {
+		h.queue = q
+		h.jobTimeout = jobTimeout
+		h.maxAttempts = maxAttempts
+	}
+}
+
+// WithInstanceWaitTimeout sets how long to wait for an inserted instance to become readable.
+func WithInstanceWaitTimeout(timeout time.Duration) HandlerOption {
+	return func(h *Handler) {
+		h.instanceWaitTimeout = timeout
+	}
+}
+
+// WithEvidenceCollector captures evidence of invalid instances before destructive actions are enforced on them.
+func WithEvidenceCollector(collector *evidence.Collector) HandlerOption {
+	return func(h *Handler) {
+		h.evidence = collector
+	}
+}
+
+// WithNodeDrainer cordons, taints and drains the Kubernetes node of invalid instances before they are taken out of service.
+func WithNodeDrainer(drainer *kube.Drainer) HandlerOption {
+	return func(h *Handler) {
+		h.drainer = drainer
+	}
+}
+
+// WithBreaker limits disruptive actions per cluster, falling back to only reporting invalid instances when the
+// breaker trips or the rate limit is reached.
+func WithBreaker(breaker *enforce.Breaker) HandlerOption {
+	return func(h *Handler) {
+		h.breaker = breaker
+	}
+}
+
+// WithScheduler delays enforcement actions by the grace period of the scheduler, during which operators can approve
+// the instance. The scheduler has to be started with EnforcePending.
+func WithScheduler(scheduler *pending.Scheduler) HandlerOption {
+	return func(h *Handler) {
+		h.scheduler = scheduler
+	}
+}
+
+// WithSinks reports the result of validating each instance to the sinks.
+func WithSinks(sinks ...findings.Sink) HandlerOption {
+	return func(h *Handler) {
+		h.sinks = append(h.sinks, sinks...)
+	}
+}
+
+// WithExceptions exempts the instances matching an unexpired exception of the registry from validation.
+func WithExceptions(registry *exempt.Registry) HandlerOption {
+	return func(h *Handler) {
+		h.exceptions = registry
+	}
 }
 
-func NewHandler(projectID string, validator *validate.InstanceValidator, computeClient *compute.InstancesClient, clusterIDs []string, deleteInvalid bool) *Handler {
-	return &Handler{
-		logger:        logrus.New(),
+func NewHandler(projectID string, validator *validate.InstanceValidator, computeClient *compute.InstancesClient, clusterIDs []string, policy *enforce.Policy, opts ...HandlerOption) *Handler {
+	h := &Handler{
+		logger:        logrus.StandardLogger(),
 		projectID:     projectID,
 		computeClient: computeClient,
 		clusterIDs:    clusterIDs,
-		deleteInvalid: deleteInvalid,
+		policy:        policy,
 		validator:     validator,
+
+		instanceWaitTimeout: defaultInstanceWaitTimeout,
+		instanceWaitBackoff: time.Second,
+	}
+
+	for _, opt := range opts {
+		opt(h)
 	}
+
+	return h
 }
 
 func (h *Handler) HandleAuditLog(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+	ctx, span := tracing.Start(tracing.Extract(r.Context(), r.Header), "HandleAuditLog")
+	var err error
+	defer func() { tracing.End(span, err) }()
 
 	var logEntry AuditLog
 
@@ -77,105 +200,404 @@ func (h *Handler) HandleAuditLog(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request", http.StatusBadRequest)
 		return
 	}
+	logEntry.raw = payload
+	metrics.EventsReceived.WithLabelValues(logEntry.ProtoPayload.MethodName).Inc()
+	span.SetAttributes(
+		attribute.String("auditlog.method", logEntry.ProtoPayload.MethodName),
+		attribute.String("auditlog.resource", logEntry.ProtoPayload.ResourceName),
+	)
 
 	if logEntry.ProtoPayload.ServiceName != "compute.googleapis.com" || logEntry.ProtoPayload.MethodName != "v1.compute.instances.insert" {
-		w.WriteHeader(http.StatusOK)
-		if _, err := w.Write([]byte("OK")); err != nil {
-			h.logger.WithError(err).Errorf("failed to write response")
-			return
-		}
+		h.writeResponse(w, h.logger, nil)
 		return
 	}
 
-	log := h.logger.WithField("resourceName", logEntry.ProtoPayload.ResourceName)
+	log := h.logger.WithContext(ctx).WithFields(logrus.Fields{
+		"resourceName": logEntry.ProtoPayload.ResourceName,
+		"operationID":  logEntry.Operation.ID,
+		"principal":    logEntry.principal(),
+		"callerIP":     logEntry.ProtoPayload.RequestMetadata.CallerIP,
+	})
+
+	if skip, reason := logEntry.skip(); skip {
+		log.WithField("reason", reason).Info("skip audit log")
+		h.writeResponse(w, log, nil)
+		return
+	}
 	defer func() {
 		log.Infof("request processed")
 	}()
 
-	instanceReq := getInstanceRequestFromResourceName(&logEntry)
+	eventID := r.Header.Get(cloudEventIDHeader)
+	if eventID == "" {
+		eventID = logEntry.InsertID
+	}
+	log = log.WithField("eventID", eventID)
+	span.SetAttributes(attribute.String("event.id", eventID))
+
+	release := func() {}
+	if eventID != "" {
+		var claimed bool
+		release, claimed = h.claim(ctx, log, dedup.EventKey(eventID))
+		if !claimed {
+			log.Info("event already processed, skip event")
+			h.writeResponse(w, log, nil)
+			return
+		}
+		defer func() {
+			// Transient failures are redelivered, which must be processed again.
+			if isTransient(err) {
+				release()
+			}
+		}()
+	}
+
+	if h.queue == nil {
+		err = h.processAuditLog(ctx, log, &logEntry)
+		h.writeResponse(w, log, err)
+		return
+	}
+
+	err = h.enqueueAuditLog(ctx, log, &logEntry, release)
+	h.writeResponse(w, log, err)
+}
+
+// enqueueAuditLog queues the audit log. When it is dropped on shutdown, release releases the claim of its event.
+func (h *Handler) enqueueAuditLog(ctx context.Context, log *logrus.Entry, logEntry *AuditLog, release func()) error {
+	// Queued processing continues the trace of the request, which ends once the audit log is queued.
+	spanContext := trace.SpanContextFromContext(ctx)
+
+	err := h.queue.Enqueue(queue.Job{
+		Key: h.fairnessKey(logEntry),
+		Run: func(ctx context.Context) {
+			jobCtx, cancel := context.WithTimeout(trace.ContextWithSpanContext(ctx, spanContext), h.jobTimeout)
+			defer cancel()
+
+			if err := h.processAuditLogWithRetry(jobCtx, log, logEntry); err != nil {
+				// The queue cancels the running jobs when it can not complete them on shutdown.
+				if ctx.Err() != nil {
+					log.WithError(err).Error("audit log interrupted on shutdown, not processed completely")
+					release()
+					return
+				}
+				log.WithError(err).Errorf("failed to process audit log")
+			}
+		},
+		// The audit log was acknowledged, so it is only processed again when the event is delivered again.
+		Drop: func() {
+			log.Error("audit log dropped on shutdown, not processed")
+			release()
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to enqueue audit log: %w", err)
+	}
+
+	log.Debug("audit log queued")
+	return nil
+}
+
+func (h *Handler) processAuditLogWithRetry(ctx context.Context, log *logrus.Entry, logEntry *AuditLog) error {
+	backoff := time.Second
+
+	for attempt := 1; ; attempt++ {
+		err := h.processAuditLog(ctx, log, logEntry)
+		if err == nil || !isTransient(err) || attempt >= h.maxAttempts {
+			return err
+		}
+
+		log.WithError(err).WithField("attempt", attempt).Warn("failed to process audit log, retrying")
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// fairnessKey groups queued audit logs by the CAST cluster of the inserted instance. Its cluster label is only in the
+// audit log of the insert request, so the cluster of completed inserts is looked up by the node pool of the instance,
+// as learned from the instances read. The node pool is used as the key until then.
+func (h *Handler) fairnessKey(logEntry *AuditLog) string {
+	if clusterID, found := logEntry.labels()[castClusterIDLabel]; found {
+		return clusterID
+	}
+
+	pool := nodePool(logEntry.ProtoPayload.ResourceName[strings.LastIndex(logEntry.ProtoPayload.ResourceName, "/")+1:])
+	if labels, found := h.poolClusters.Load(pool); found {
+		return labels.(map[string]string)[castClusterIDLabel]
+	}
+	return pool
+}
+
+// learnCluster records the CAST cluster of the node pool of the instance, for the fairnessKey of its later instances
+// and for filtering those which can not be read.
+func (h *Handler) learnCluster(instance *computepb.Instance) {
+	labels := instance.GetLabels()
+	if _, managed := labels[castManagedByLabel]; !managed {
+		return
+	}
+	if clusterID, found := labels[castClusterIDLabel]; found {
+		h.poolClusters.Store(nodePool(instance.GetName()), map[string]string{
+			castManagedByLabel: labels[castManagedByLabel],
+			castClusterIDLabel: clusterID,
+		})
+	}
+}
+
+// unreadableInstance returns the inserted instance which could not be read, with the labels known of it: those of the
+// insert request, or, for completed inserts, those of the CAST cluster of the node pool as learned from instances read
+// before.
+func (h *Handler) unreadableInstance(logEntry *AuditLog, name string) *computepb.Instance {
+	instance := &computepb.Instance{Name: &name, Labels: logEntry.labels()}
+	if len(instance.Labels) > 0 {
+		return instance
+	}
+
+	if labels, found := h.poolClusters.Load(nodePool(name)); found {
+		instance.Labels = maps.Clone(labels.(map[string]string))
+	}
+	return instance
+}
+
+// nodePool returns the instance name without the generated suffix, which identifies the node pool.
+func nodePool(instanceName string) string {
+	parts := strings.Split(instanceName, "-")
+	if len(parts) > 2 {
+		parts = parts[:len(parts)-2]
+	}
+
+	return strings.Join(parts, "-")
+}
+
+// claim records the key in the deduplication store. It returns false when the key was already claimed,
+// otherwise a function releasing the claim. Store failures are logged and the work is processed anyway.
+func (h *Handler) claim(ctx context.Context, log *logrus.Entry, key string) (func(), bool) {
+	if h.dedupStore == nil {
+		return func() {}, true
+	}
+
+	claimed, err := h.dedupStore.Claim(ctx, key, h.dedupTTL)
+	if err != nil {
+		log.WithError(err).WithField("key", key).Warn("failed to claim key")
+		return func() {}, true
+	}
+
+	if !claimed {
+		return nil, false
+	}
+
+	return func() {
+		if err := h.dedupStore.Release(context.WithoutCancel(ctx), key); err != nil {
+			log.WithError(err).WithField("key", key).Warn("failed to release key")
+		}
+	}, true
+}
+
+func (h *Handler) processAuditLog(ctx context.Context, log *logrus.Entry, logEntry *AuditLog) (err error) {
+	ctx, span := tracing.Start(ctx, "processAuditLog")
+	defer func() { tracing.End(span, err) }()
+	log = log.WithContext(ctx)
+
+	instanceReq := getInstanceRequestFromResourceName(logEntry)
 	if instanceReq == nil {
 		log.Errorf("failed to get instance request from resource name")
-		http.Error(w, "Invalid request", http.StatusBadRequest)
-		return
+		return errInvalidRequest
 	}
 
 	log.Infof("instance request: %+v", instanceReq)
 
 	// GKE Autopilot are also present in the audit logs, but are not part of user project
 	if instanceReq.Project != h.projectID {
-		w.WriteHeader(http.StatusOK)
-		if _, err := w.Write([]byte("OK")); err != nil {
-			log.WithError(err).Errorf("failed to write response")
-			return
-		}
-		return
+		return nil
 	}
 
 	// GKE Autopilot nodes have gk3- prefix, so ignore them
 	if strings.HasPrefix(instanceReq.Instance, "gk3-") {
-		w.WriteHeader(http.StatusOK)
-		if _, err := w.Write([]byte("OK")); err != nil {
-			log.WithError(err).Errorf("failed to write response")
-			return
-		}
-		return
+		return nil
 	}
 
-	instance, err := h.computeClient.Get(ctx, instanceReq)
+	instance, err := h.waitForInstance(ctx, log, instanceReq)
 	if err != nil {
+		if errors.Is(err, errInstanceNotReadable) {
+			instance := h.unreadableInstance(logEntry, instanceReq.Instance)
+			if !h.considerInstance(ctx, instance, logEntry.principal(), log) {
+				return nil
+			}
+			log.WithError(err).WithField("verdict", validate.VerdictUnverifiable).Warn("instance is unverifiable")
+			h.report(ctx, log, findings.NewValidationResult(instanceReq.Project, instanceReq.Zone, instance, logEntry.principal(), validate.VerdictUnverifiable, err))
+			return nil
+		}
 		log.WithError(err).Errorf("failed to get instance")
-		w.WriteHeader(http.StatusOK)
-		if _, err := w.Write([]byte("OK")); err != nil {
-			log.WithError(err).Errorf("failed to write response")
-			return
+		return fmt.Errorf("failed to get instance: %w", err)
+	}
+	h.learnCluster(instance)
+
+	// The instance name could have been reused by an instance inserted after the one of the audit log.
+	if targetID := logEntry.ProtoPayload.Response.TargetID; targetID != "" && targetID != strconv.FormatUint(instance.GetId(), 10) {
+		log.WithField("instanceID", instance.GetId()).Info("instance was replaced, skip instance")
+		return nil
+	}
+
+	if !h.considerInstance(ctx, instance, logEntry.principal(), log) {
+		return nil
+	}
+
+	span.SetAttributes(
+		attribute.String("instance.name", instance.GetName()),
+		attribute.String("instance.cluster_id", instance.GetLabels()[castClusterIDLabel]),
+	)
+
+	release, claimed := h.claim(ctx, log, dedup.InstanceKey(instance.GetId(), instance.GetMetadata().GetFingerprint()))
+	if !claimed {
+		log.Info("instance metadata already processed, skip instance")
+		return nil
+	}
+	defer func() {
+		if isTransient(err) {
+			release()
 		}
-		return
+	}()
+
+	result, err := h.validateInstance(ctx, log, instance, logEntry.principal())
+	if err != nil {
+		return err
 	}
 
-	if !h.considerInstance(instance, log) {
-		w.WriteHeader(http.StatusOK)
-		if _, err := w.Write([]byte("OK")); err != nil {
-			log.WithError(err).Errorf("failed to write response")
-			return
+	log = log.WithField("verdict", result.verdict)
+	span.SetAttributes(attribute.String("verdict", string(result.verdict)))
+	h.recordVerdict(ctx, log, instance, result.verdict)
+	metrics.Verdicts.WithLabelValues(instance.GetLabels()[castClusterIDLabel], instance.GetLabels()[nodePoolNameLabel], string(result.verdict)).Inc()
+
+	record := findings.NewValidationResult(instanceReq.Project, instanceReq.Zone, instance, logEntry.principal(), result.verdict, result.err)
+	defer func() {
+		// Transient failures are redelivered, the result is reported once the redelivery is processed.
+		if !isTransient(err) {
+			h.report(ctx, log, record)
+		}
+	}()
+
+	switch result.verdict {
+	case validate.VerdictValid:
+		log.Info("instance is valid")
+		return nil
+	case validate.VerdictUnverifiable:
+		log.Warn("instance is unverifiable")
+		return nil
+	}
+
+	log.Info("instance is invalid")
+	target := &enforce.Target{
+		Project:  instanceReq.Project,
+		Zone:     instanceReq.Zone,
+		Instance: instance,
+	}
+	record.Action, err = h.handleInvalidInstance(ctx, log, target, logEntry, result.err)
+	if err != nil {
+		log.WithError(err).Errorf("failed to handle invalid instance")
+		return err
+	}
+
+	return nil
+}
+
+// report logs the result of validating an instance as an event record, which log-based metrics and alerts filter on,
+// and reports it to the sinks. Failing sinks are only logged, the instance was already handled.
+func (h *Handler) report(ctx context.Context, log *logrus.Entry, record *findings.ValidationResult) {
+	// Results of audit logs interrupted on shutdown are reported too.
+	ctx = context.WithoutCancel(ctx)
+	for _, sink := range h.sinks {
+		if err := sink.Report(ctx, record); err != nil {
+			log.WithError(err).WithField("sink", fmt.Sprintf("%T", sink)).Error("failed to report validation result")
 		}
+	}
+
+	log = log.WithFields(logrus.Fields{
+		"event": record,
+		logging.LabelsKey: logging.Labels{
+			"verdict": string(record.Verdict),
+			"cluster": record.Cluster,
+		},
+	})
+	if record.Verdict == validate.VerdictValid {
+		log.Info("validation result")
 		return
 	}
+	log.Warn("validation result")
+}
+
+// waitForInstance gets the instance, polling with backoff while it is not found. The audit log of the insert
+// can be delivered before the instance is readable.
+func (h *Handler) waitForInstance(ctx context.Context, log *logrus.Entry, req *computepb.GetInstanceRequest) (instance *computepb.Instance, err error) {
+	ctx, span := tracing.Start(ctx, "waitForInstance")
+	defer func() { tracing.End(span, err) }()
+
+	deadline := time.Now().Add(h.instanceWaitTimeout)
+	backoff := h.instanceWaitBackoff
+
+	for {
+		start := time.Now()
+		instance, err = h.computeClient.Get(ctx, req)
+		metrics.ObserveGCPCall("get_instance", start, err)
+		if err == nil || !gcperr.IsNotFound(err) {
+			return instance, err
+		}
 
-	valid := h.validateInstance(ctx, instance)
+		if time.Now().Add(backoff).After(deadline) {
+			return nil, fmt.Errorf("%w: %w", errInstanceNotReadable, err)
+		}
 
-	if valid {
-		log.Info("instance is valid")
-	} else {
-		log.Info("instance is invalid")
-		if err := h.handleInvalidInstance(ctx, log, instanceReq.Project, instanceReq.Zone, instanceReq.Instance); err != nil {
-			log.WithError(err).Errorf("failed to handle invalid instance")
-			if _, err := w.Write([]byte("OK")); err != nil {
-				log.WithError(err).Errorf("failed to write response")
-				return
-			}
+		log.WithField("backoff", backoff).Debug("instance not found, waiting")
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
 		}
+		backoff = min(2*backoff, maxInstanceWaitBackoff)
+	}
+}
+
+func (h *Handler) writeResponse(w http.ResponseWriter, log logrus.FieldLogger, err error) {
+	code := responseStatusForError(err)
+	if code != http.StatusOK {
+		log.WithError(err).WithField("status", code).Warn("request not acknowledged")
+		http.Error(w, http.StatusText(code), code)
+		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 	if _, err := w.Write([]byte("OK")); err != nil {
 		log.WithError(err).Errorf("failed to write response")
-		return
 	}
 }
 
-func (h *Handler) considerInstance(instance *computepb.Instance, log *logrus.Entry) bool {
-	if _, found := instance.Labels["cast-managed-by"]; !found {
-		log.Info("instance is not managed by CAST, skip instance")
-		return false
+// considerInstance reports whether the instance should be validated. Instances created by a principal allowed
+// for CAST clusters are validated even when they are not labelled as managed by CAST, unless they are outside the
+// monitored clusters or exempted.
+func (h *Handler) considerInstance(ctx context.Context, instance *computepb.Instance, principal string, log *logrus.Entry) bool {
+	if _, found := instance.Labels[castManagedByLabel]; !found {
+		if !h.validator.PrincipalAllowed(instance.Labels[castClusterIDLabel], principal) {
+			log.Info("instance is not managed by CAST, skip instance")
+			return false
+		}
+
+		log.Warn("instance created by CAST principal is not labelled as managed by CAST")
 	}
 
+	return h.monitored(ctx, instance, log)
+}
+
+// monitored reports whether the instance is part of the monitored clusters and not exempted from validation.
+func (h *Handler) monitored(ctx context.Context, instance *computepb.Instance, log *logrus.Entry) bool {
 	if len(h.clusterIDs) > 0 {
 		clusterID, found := instance.Labels[castClusterIDLabel]
 
 		if !found {
 			log.Info("missing CAST cluster id, skip instance")
 			return false
-
 		}
 
 		if !slices.Contains(h.clusterIDs, clusterID) {
@@ -184,55 +606,332 @@ func (h *Handler) considerInstance(instance *computepb.Instance, log *logrus.Ent
 		}
 	}
 
+	if h.exceptions != nil {
+		ctx, span := tracing.Start(ctx, "matchExceptions")
+		exception, err := h.exceptions.Match(ctx, instance)
+		tracing.End(span, err)
+		if err != nil {
+			// Instances are validated while exceptions can not be read.
+			log.WithError(err).Error("failed to read exceptions")
+		}
+		if exception != nil {
+			log.WithFields(logrus.Fields{
+				"exception": exception.Name,
+				"reason":    exception.Reason,
+				"expires":   exception.Expires,
+			}).Warn("instance exempted from validation, skip instance")
+			return false
+		}
+	}
+
 	return true
 }
 
-func (h *Handler) validateInstance(ctx context.Context, i *computepb.Instance) bool {
-	if err := h.validator.Validate(ctx, i); err != nil {
-		log := h.logger.WithError(err).WithFields(logrus.Fields{
+// validation is the verdict for an instance and the validation error it was given for.
+type validation struct {
+	verdict validate.Verdict
+	err     error
+}
+
+// validateInstance returns the verdict for the instance. Transient failures of the whitelist providers
+// are returned, so the event can be redelivered, other failures make the instance unverifiable.
+func (h *Handler) validateInstance(ctx context.Context, log *logrus.Entry, i *computepb.Instance, principal string) (*validation, error) {
+	ctx, span := tracing.Start(ctx, "validateInstance")
+	defer span.End()
+
+	if err := h.validator.Validate(ctx, i, principal); err != nil {
+		log := log.WithError(err).WithFields(logrus.Fields{
 			"instanceName":     lo.FromPtr(i.Name),
 			"instanceSelfLink": lo.FromPtr(i.SelfLink),
 			"clusterName":      i.Labels[clusterNameLabel],
 			"castClusterID":    i.Labels[castClusterIDLabel],
 		})
 
+		// Instances created by unexpected principals are validated further, both are reported.
+		principalErr := &validate.PrincipalError{}
 		valErr := &validate.ValidationError{}
+		invalid := false
+		if errors.As(err, &principalErr) {
+			log = log.WithField("unexpectedPrincipal", principalErr.Principal)
+			invalid = true
+		}
 		if errors.As(err, &valErr) {
-			log.WithField("unknownCommands", valErr.UnknownCommands).Errorf("instance validation failed")
-			return false
+			log = log.WithField("unknownCommands", valErr.UnknownCommands)
+			invalid = true
+		}
+		if invalid {
+			log.Errorf("instance validation failed")
+			return &validation{verdict: validate.VerdictInvalid, err: err}, nil
 		}
 
-		log.WithError(err).Errorf("failed to validate instance, skipping instance")
+		if isTransient(err) {
+			log.Warn("failed to validate instance, retrying later")
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return nil, err
+		}
+
+		log.Errorf("failed to validate instance")
+		return &validation{verdict: validate.VerdictUnverifiable, err: err}, nil
 	}
-	return true
+	return &validation{verdict: validate.VerdictValid}, nil
 }
 
-func (h *Handler) handleInvalidInstance(ctx context.Context, log *logrus.Entry, project, zone, name string) error {
-	if h.deleteInvalid {
-		if err := h.deleteInstance(ctx, project, zone, name); err != nil {
-			return fmt.Errorf("failed to delete instance: %w", err)
+// handleInvalidInstance enforces the action of the cluster on the invalid instance, returning the action taken.
+func (h *Handler) handleInvalidInstance(ctx context.Context, log *logrus.Entry, target *enforce.Target, logEntry *AuditLog, validationErr error) (taken *findings.Action, err error) {
+	ctx, span := tracing.Start(ctx, "handleInvalidInstance")
+	defer func() { tracing.End(span, err) }()
+
+	clusterID := target.Instance.GetLabels()[castClusterIDLabel]
+	action := h.policy.Action(clusterID)
+	if action == nil {
+		return nil, nil
+	}
+
+	log = log.WithField("action", action.Name())
+	taken = &findings.Action{Name: action.Name()}
+	defer func() {
+		if err != nil {
+			taken.Result = failedResult(err)
+			taken.Error = err.Error()
 		}
-		log.Info("instance deleted")
+	}()
+
+	if approved(target.Instance) {
+		log.Info("instance approved, enforcement skipped")
+		metrics.EnforcementActions.WithLabelValues(action.Name(), metrics.ResultCancelled).Inc()
+		taken.Result = metrics.ResultCancelled
+		return taken, nil
 	}
 
-	return nil
-}
+	if h.evidence != nil && h.policy.Mode(clusterID).Destructive() {
+		ctx, span := tracing.Start(ctx, "captureEvidence")
+		start := time.Now()
+		location, err := h.evidence.Capture(ctx, target, validationErr, logEntry.raw)
+		metrics.ObserveGCPCall("capture_evidence", start, err)
+		tracing.End(span, err)
+		if err != nil {
+			return taken, fmt.Errorf("failed to capture evidence, %s not enforced: %w", action.Name(), err)
+		}
+		taken.Evidence = location
+		log = log.WithField("evidence", location)
+		log.Info("evidence captured")
+	}
 
-func (h *Handler) deleteInstance(ctx context.Context, project, zone, name string) error {
-	req := &computepb.DeleteInstanceRequest{
-		Project:  project,
-		Zone:     zone,
-		Instance: name,
+	if h.scheduler != nil {
+		ctx, span := tracing.Start(ctx, "scheduleAction")
+		scheduled, err := h.scheduler.Schedule(ctx, &pending.Action{
+			Project:    target.Project,
+			Zone:       target.Zone,
+			Instance:   target.Instance.GetName(),
+			InstanceID: target.Instance.GetId(),
+			ClusterID:  clusterID,
+			Action:     action.Name(),
+			Principal:  logEntry.principal(),
+			Findings:   findings.FromError(validationErr),
+			Evidence:   taken.Evidence,
+		})
+		tracing.End(span, err)
+		if err != nil {
+			return taken, err
+		}
+		log.WithField("dueAt", scheduled.DueAt).Info("enforcement action scheduled")
+		metrics.EnforcementActions.WithLabelValues(action.Name(), metrics.ResultScheduled).Inc()
+		taken.Result = metrics.ResultScheduled
+		taken.DueAt = &scheduled.DueAt
+		return taken, nil
+	}
+
+	// Counted by the breaker right before enforcing, so that failed evidence captures do not use up the actions.
+	// Delayed actions are counted when they are due.
+	if !h.allow(ctx, log, clusterID, action) {
+		taken.Result = metrics.ResultWithheld
+		return taken, nil
+	}
+
+	if err := h.enforce(ctx, log, clusterID, action, target); err != nil {
+		return taken, err
 	}
+	taken.Result = metrics.ResultApplied
+	return taken, nil
+}
 
-	_, err := h.computeClient.Delete(ctx, req)
+// EnforcePending enforces an action whose grace period passed, unless the instance was approved, deleted or
+// replaced in the meantime, and reports the outcome.
+func (h *Handler) EnforcePending(ctx context.Context, p *pending.Action) (err error) {
+	ctx, span := tracing.Start(ctx, "EnforcePending", attribute.String("instance.name", p.Instance))
+	defer func() { tracing.End(span, err) }()
+
+	log := h.logger.WithContext(ctx).WithFields(logrus.Fields{
+		"project":      p.Project,
+		"zone":         p.Zone,
+		"instanceName": p.Instance,
+		"instanceID":   p.InstanceID,
+		"detectedAt":   p.DetectedAt,
+	})
+
+	start := time.Now()
+	instance, err := h.computeClient.Get(ctx, &computepb.GetInstanceRequest{
+		Project:  p.Project,
+		Zone:     p.Zone,
+		Instance: p.Instance,
+	})
+	metrics.ObserveGCPCall("get_instance", start, err)
 	if err != nil {
+		if gcperr.IsNotFound(err) {
+			log.Info("instance deleted, pending action cancelled")
+			metrics.EnforcementActions.WithLabelValues(p.Action, metrics.ResultCancelled).Inc()
+			return nil
+		}
 		return err
 	}
 
+	if instance.GetId() != p.InstanceID {
+		log.Info("instance replaced, pending action cancelled")
+		metrics.EnforcementActions.WithLabelValues(p.Action, metrics.ResultCancelled).Inc()
+		return nil
+	}
+
+	record := findings.NewValidationResult(p.Project, p.Zone, instance, p.Principal, validate.VerdictInvalid, nil)
+	record.Findings = p.Findings
+	defer func() {
+		// Transient failures are retried, the outcome is reported once the retry is enforced.
+		if !isTransient(err) {
+			h.report(ctx, log, record)
+		}
+	}()
+
+	record.Action, err = h.enforcePending(ctx, log, p, instance)
+	return err
+}
+
+// enforcePending enforces the pending action on the instance, returning the action taken.
+func (h *Handler) enforcePending(ctx context.Context, log *logrus.Entry, p *pending.Action, instance *computepb.Instance) (taken *findings.Action, err error) {
+	taken = &findings.Action{Name: p.Action, Evidence: p.Evidence}
+	defer func() {
+		if err != nil {
+			taken.Result = failedResult(err)
+			taken.Error = err.Error()
+		}
+	}()
+
+	if approved(instance) {
+		log.Info("instance approved, pending action cancelled")
+		metrics.EnforcementActions.WithLabelValues(p.Action, metrics.ResultCancelled).Inc()
+		taken.Result = metrics.ResultCancelled
+		return taken, nil
+	}
+
+	clusterID := instance.GetLabels()[castClusterIDLabel]
+	action := h.policy.Action(clusterID)
+	if action == nil {
+		log.Info("protection mode changed to log, pending action cancelled")
+		metrics.EnforcementActions.WithLabelValues(p.Action, metrics.ResultCancelled).Inc()
+		taken.Result = metrics.ResultCancelled
+		return taken, nil
+	}
+
+	log = log.WithField("action", action.Name())
+	taken.Name = action.Name()
+	if !h.allow(ctx, log, clusterID, action) {
+		taken.Result = metrics.ResultWithheld
+		return taken, nil
+	}
+
+	err = h.enforce(ctx, log, clusterID, action, &enforce.Target{
+		Project:  p.Project,
+		Zone:     p.Zone,
+		Instance: instance,
+	})
+	if err != nil {
+		log.WithError(err).Errorf("failed to handle invalid instance")
+		return taken, err
+	}
+	taken.Result = metrics.ResultApplied
+	return taken, nil
+}
+
+// allow reports whether the breaker allows the action on an instance of the cluster.
+func (h *Handler) allow(ctx context.Context, log *logrus.Entry, clusterID string, action enforce.Action) bool {
+	if h.breaker == nil || !h.policy.Mode(clusterID).Disruptive() {
+		return true
+	}
+
+	if err := h.breaker.Allow(ctx, clusterID); err != nil {
+		log.WithError(err).Error("enforcement withheld, instance only reported")
+		metrics.EnforcementActions.WithLabelValues(action.Name(), metrics.ResultWithheld).Inc()
+		return false
+	}
+	return true
+}
+
+func (h *Handler) enforce(ctx context.Context, log *logrus.Entry, clusterID string, action enforce.Action, target *enforce.Target) error {
+	if h.drainer != nil && h.policy.Mode(clusterID).Disruptive() {
+		ctx, span := tracing.Start(ctx, "drainNode")
+		err := h.drainer.Drain(ctx, log, target)
+		tracing.End(span, err)
+		// The instance is taken out of service regardless, draining only spares its workloads.
+		if err != nil {
+			log.WithError(err).Warn("failed to drain node")
+		}
+	}
+
+	enforceCtx, span := tracing.Start(ctx, "enforce", attribute.String("action", action.Name()))
+	start := time.Now()
+	outcome, err := action.Enforce(enforceCtx, target)
+	metrics.ObserveGCPCall("enforce_"+action.Name(), start, err)
+	tracing.End(span, err)
+	if outcome != nil {
+		log = log.WithField("operations", outcome.Operations)
+		if outcome.Method != "" {
+			log = log.WithField("method", outcome.Method)
+		}
+		if outcome.InstanceGroupManager != "" {
+			log = log.WithField("instanceGroupManager", outcome.InstanceGroupManager)
+		}
+	}
+	if err != nil {
+		metrics.EnforcementActions.WithLabelValues(action.Name(), failedResult(err)).Inc()
+		return fmt.Errorf("failed to %s instance: %w", action.Name(), err)
+	}
+	log.Info("enforcement action applied")
+	metrics.EnforcementActions.WithLabelValues(action.Name(), metrics.ResultApplied).Inc()
+
 	return nil
 }
 
+// failedResult returns the result of an action which failed with err.
+func failedResult(err error) string {
+	if errors.Is(err, context.Canceled) {
+		return metrics.ResultInterrupted
+	}
+	return metrics.ResultFailed
+}
+
+// approved reports whether an operator approved the instance with the approval label.
+func approved(instance *computepb.Instance) bool {
+	value, found := instance.GetLabels()[castValidationApprovedLabel]
+	return found && value != "false"
+}
+
+// recordVerdict counts the verdict in the breaker, and reports the breaker tripping with high severity.
+func (h *Handler) recordVerdict(ctx context.Context, log *logrus.Entry, instance *computepb.Instance, verdict validate.Verdict) {
+	if h.breaker == nil || verdict == validate.VerdictUnverifiable {
+		return
+	}
+
+	trip, err := h.breaker.RecordVerdict(ctx, instance.GetLabels()[castClusterIDLabel], verdict == validate.VerdictInvalid)
+	if err != nil {
+		log.WithError(err).Error("failed to record verdict")
+	}
+	if trip != nil {
+		log.WithFields(logrus.Fields{
+			"clusterID": trip.ClusterID,
+			"reason":    trip.Reason,
+		}).Error("enforcement circuit breaker tripped, invalid instances are only reported until it is reset")
+	}
+}
+
 func getInstanceRequestFromResourceName(log *AuditLog) *computepb.GetInstanceRequest {
 	parts := strings.Split(log.ProtoPayload.ResourceName, "/")
 	if len(parts) != 6 {
@@ -0,0 +1,720 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"cloud.google.com/go/storage"
+	"github.com/castai/gcp-node-validator/container/dedup"
+	"github.com/castai/gcp-node-validator/container/enforce"
+	"github.com/castai/gcp-node-validator/container/evidence"
+	"github.com/castai/gcp-node-validator/container/exempt"
+	"github.com/castai/gcp-node-validator/container/findings"
+	"github.com/castai/gcp-node-validator/container/internal/testutil"
+	"github.com/castai/gcp-node-validator/container/metrics"
+	"github.com/castai/gcp-node-validator/container/pending"
+	"github.com/castai/gcp-node-validator/container/queue"
+	"github.com/castai/gcp-node-validator/container/validate"
+	"github.com/samber/lo"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestFairnessKey(t *testing.T) {
+	t.Parallel()
+
+	// The audit log of a completed insert, which carries neither the request nor the labels of the instance.
+	lastEntry := `{
+		"protoPayload": {
+			"serviceName": "compute.googleapis.com",
+			"methodName": "v1.compute.instances.insert",
+			"resourceName": "projects/p/zones/europe-west1-b/instances/gke-cluster-pool-5678efgh-z9w8",
+			"request": {"@type": "type.googleapis.com/compute.instances.insert"}
+		},
+		"operation": {"id": "operation-1", "producer": "compute.googleapis.com", "last": true},
+		"resource": {"type": "gce_instance", "labels": {"instance_id": "43", "project_id": "p", "zone": "europe-west1-b"}}
+	}`
+
+	tests := []struct {
+		name    string
+		payload string
+		learned *computepb.Instance
+		want    string
+	}{
+		{
+			name: "cluster label",
+			payload: `{"protoPayload": {
+				"resourceName": "projects/p/zones/europe-west1-b/instances/gke-cluster-pool-1234abcd-x1y2",
+				"request": {"labels": [{"key": "cast-managed-by", "value": "cast-ai"}, {"key": "cast-cluster-id", "value": "c1"}]}
+			}}`,
+			want: "c1",
+		},
+		{
+			name:    "completed insert of a node pool whose cluster was learned",
+			payload: lastEntry,
+			learned: testutil.NewInstance(),
+			want:    "c1",
+		},
+		{
+			name:    "completed insert of a node pool whose cluster was not learned yet",
+			payload: lastEntry,
+			want:    "gke-cluster-pool",
+		},
+		{
+			name: "short name",
+			payload: `{"protoPayload": {
+				"resourceName": "projects/p/zones/europe-west1-b/instances/vm"
+			}}`,
+			want: "vm",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			r := require.New(t)
+
+			var logEntry AuditLog
+			r.NoError(json.Unmarshal([]byte(tt.payload), &logEntry))
+
+			h := &Handler{}
+			if tt.learned != nil {
+				h.learnCluster(tt.learned)
+			}
+			r.Equal(tt.want, h.fairnessKey(&logEntry))
+		})
+	}
+}
+
+func TestConsiderInstance(t *testing.T) {
+	t.Parallel()
+
+	const principal = "cast@project.iam.gserviceaccount.com"
+	validator := validate.NewInstanceValidator(validate.PrincipalAllowList{validate.AnyCluster: {principal}})
+	registry := exempt.NewRegistry(exempt.List{{
+		Name:     "exempted",
+		NodePool: "exempted-pool",
+		Reason:   "testing",
+		Expires:  time.Now().Add(time.Hour),
+	}})
+
+	tests := []struct {
+		name       string
+		labels     map[string]string
+		principal  string
+		clusterIDs []string
+		want       bool
+	}{
+		{
+			name:   "managed instance",
+			labels: map[string]string{castManagedByLabel: "cast-ai", castClusterIDLabel: "c1"},
+			want:   true,
+		},
+		{
+			name:   "unmanaged instance",
+			labels: map[string]string{castClusterIDLabel: "c1"},
+		},
+		{
+			name:      "unmanaged instance created by CAST principal",
+			labels:    map[string]string{castClusterIDLabel: "c1"},
+			principal: principal,
+			want:      true,
+		},
+		{
+			name:       "managed instance outside monitored clusters",
+			labels:     map[string]string{castManagedByLabel: "cast-ai", castClusterIDLabel: "c2"},
+			clusterIDs: []string{"c1"},
+		},
+		{
+			name:       "unmanaged instance created by CAST principal outside monitored clusters",
+			labels:     map[string]string{castClusterIDLabel: "c2"},
+			principal:  principal,
+			clusterIDs: []string{"c1"},
+		},
+		{
+			name:       "unmanaged instance created by CAST principal without cluster id",
+			principal:  principal,
+			clusterIDs: []string{"c1"},
+		},
+		{
+			name:      "exempted unmanaged instance created by CAST principal",
+			labels:    map[string]string{castClusterIDLabel: "c1", nodePoolNameLabel: "exempted-pool"},
+			principal: principal,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := NewHandler("project", validator, nil, tt.clusterIDs, nil, WithExceptions(registry))
+			instance := &computepb.Instance{Name: lo.ToPtr("instance"), Labels: tt.labels}
+
+			require.Equal(t, tt.want, h.considerInstance(context.Background(), instance, tt.principal, logrus.NewEntry(logrus.StandardLogger())))
+		})
+	}
+}
+
+func TestWaitForInstance(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		instances  *fakeInstances
+		timeout    time.Duration
+		wantErr    error
+		wantErrMsg string
+		wantReads  int
+	}{
+		{
+			name:      "readable instance",
+			instances: &fakeInstances{instance: &computepb.Instance{Name: lo.ToPtr("instance")}},
+			timeout:   time.Second,
+			wantReads: 1,
+		},
+		{
+			name:      "instance readable after not found",
+			instances: &fakeInstances{instance: &computepb.Instance{Name: lo.ToPtr("instance")}, notFound: 2},
+			timeout:   time.Second,
+			wantReads: 3,
+		},
+		{
+			name:      "instance not readable before the deadline",
+			instances: &fakeInstances{},
+			// Reads after 0, 10, 30 and 70ms, the next one would be after the deadline.
+			timeout:   120 * time.Millisecond,
+			wantErr:   errInstanceNotReadable,
+			wantReads: 4,
+		},
+		{
+			name:       "failed read",
+			instances:  &fakeInstances{status: http.StatusForbidden},
+			timeout:    time.Second,
+			wantErrMsg: "Error 403",
+			wantReads:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			r := require.New(t)
+
+			h := NewHandler("p", nil, newFakeInstances(t, tt.instances), nil, nil, WithInstanceWaitTimeout(tt.timeout))
+			h.instanceWaitBackoff = 10 * time.Millisecond
+
+			instance, err := h.waitForInstance(context.Background(), logrus.NewEntry(logrus.StandardLogger()), &computepb.GetInstanceRequest{
+				Project:  "p",
+				Zone:     "z",
+				Instance: "instance",
+			})
+			switch {
+			case tt.wantErr != nil:
+				r.ErrorIs(err, tt.wantErr)
+			case tt.wantErrMsg != "":
+				r.ErrorContains(err, tt.wantErrMsg)
+				r.NotErrorIs(err, errInstanceNotReadable)
+			default:
+				r.NoError(err)
+				r.Equal("instance", instance.GetName())
+			}
+			r.Equal(tt.wantReads, tt.instances.readCount())
+		})
+	}
+}
+
+func TestProcessAuditLogUnverifiableInstance(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name              string
+		labels            string
+		learned           bool
+		allowedPrincipals validate.PrincipalAllowList
+		clusterIDs        []string
+		wantCluster       string
+		wantReport        bool
+	}{
+		{
+			name:        "managed instance",
+			labels:      `[{"key": "cast-managed-by", "value": "cast-ai"}, {"key": "cast-cluster-id", "value": "c1"}]`,
+			clusterIDs:  []string{"c1"},
+			wantCluster: "c1",
+			wantReport:  true,
+		},
+		{
+			name:       "managed instance outside monitored clusters",
+			labels:     `[{"key": "cast-managed-by", "value": "cast-ai"}, {"key": "cast-cluster-id", "value": "c2"}]`,
+			clusterIDs: []string{"c1"},
+		},
+		{
+			name:   "unmanaged instance",
+			labels: `[{"key": "cast-cluster-id", "value": "c1"}]`,
+		},
+		{
+			name:        "completed insert of a node pool of CAST instances",
+			labels:      `[]`,
+			learned:     true,
+			clusterIDs:  []string{"c1"},
+			wantCluster: "c1",
+			wantReport:  true,
+		},
+		{
+			name:   "completed insert of an unknown node pool",
+			labels: `[]`,
+		},
+		{
+			name:              "completed insert by a CAST principal",
+			labels:            `[]`,
+			allowedPrincipals: validate.PrincipalAllowList{validate.AnyCluster: {"cast@example.com"}},
+			wantReport:        true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			r := require.New(t)
+
+			sink := &recordingSink{}
+			h := NewHandler("p", validate.NewInstanceValidator(tt.allowedPrincipals), newFakeInstances(t, &fakeInstances{}),
+				tt.clusterIDs, nil, WithInstanceWaitTimeout(10*time.Millisecond), WithSinks(sink))
+			if tt.learned {
+				// Another node of the pool was read before.
+				sibling := testutil.NewInstance()
+				sibling.Name = lo.ToPtr("gke-cluster-pool-1234abcd-a1b2")
+				h.learnCluster(sibling)
+			}
+
+			var logEntry AuditLog
+			r.NoError(json.Unmarshal([]byte(`{
+				"protoPayload": {
+					"resourceName": "projects/p/zones/z/instances/gke-cluster-pool-1234abcd-x1y2",
+					"authenticationInfo": {"principalEmail": "cast@example.com"},
+					"request": {"labels": `+tt.labels+`}
+				},
+				"resource": {"labels": {"project_id": "p"}}
+			}`), &logEntry))
+
+			r.NoError(h.processAuditLog(context.Background(), logrus.NewEntry(logrus.StandardLogger()), &logEntry))
+
+			if !tt.wantReport {
+				r.Empty(sink.reported())
+				return
+			}
+			r.Len(sink.reported(), 1)
+			result := sink.reported()[0]
+			r.Equal(validate.VerdictUnverifiable, result.Verdict)
+			r.Equal("gke-cluster-pool-1234abcd-x1y2", result.Instance)
+			r.Equal(tt.wantCluster, result.Cluster)
+		})
+	}
+}
+
+// insertAuditLog returns the audit log of inserting the instance, when the operation completed.
+func insertAuditLog(methodName string, last bool) string {
+	return fmt.Sprintf(`{
+		"insertId": "insert-1",
+		"protoPayload": {
+			"serviceName": "compute.googleapis.com",
+			"methodName": %q,
+			"resourceName": "projects/p/zones/z/instances/gke-cluster-pool-1234abcd-x1y2",
+			"request": {"@type": "type.googleapis.com/compute.instances.insert"}
+		},
+		"operation": {"id": "operation-1", "last": %t},
+		"resource": {"labels": {"project_id": "p"}}
+	}`, methodName, last)
+}
+
+func postAuditLog(h *Handler, eventID, payload string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(payload))
+	if eventID != "" {
+		req.Header.Set(cloudEventIDHeader, eventID)
+	}
+	rec := httptest.NewRecorder()
+	h.HandleAuditLog(rec, req)
+	return rec
+}
+
+func TestHandleAuditLog(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		payload     string
+		status      int
+		wantStatus  int
+		wantReads   int
+		wantVerdict validate.Verdict
+	}{
+		{
+			name:       "invalid payload",
+			payload:    `{"protoPayload": `,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "other method",
+			payload:    insertAuditLog("v1.compute.instances.delete", true),
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "operation not completed",
+			payload:    insertAuditLog("v1.compute.instances.insert", false),
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:        "valid instance",
+			payload:     insertAuditLog("v1.compute.instances.insert", true),
+			wantStatus:  http.StatusOK,
+			wantReads:   1,
+			wantVerdict: validate.VerdictValid,
+		},
+		{
+			name:       "rate limited read is redelivered",
+			payload:    insertAuditLog("v1.compute.instances.insert", true),
+			status:     http.StatusTooManyRequests,
+			wantStatus: http.StatusTooManyRequests,
+			wantReads:  1,
+		},
+		{
+			name:       "transient read failure is redelivered",
+			payload:    insertAuditLog("v1.compute.instances.insert", true),
+			status:     http.StatusInternalServerError,
+			wantStatus: http.StatusServiceUnavailable,
+			wantReads:  1,
+		},
+		{
+			name:       "permanent read failure is acknowledged",
+			payload:    insertAuditLog("v1.compute.instances.insert", true),
+			status:     http.StatusForbidden,
+			wantStatus: http.StatusOK,
+			wantReads:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			r := require.New(t)
+
+			instances := &fakeInstances{instance: testutil.NewInstance(), status: tt.status}
+			sink := &recordingSink{}
+			h := NewHandler("p", validate.NewInstanceValidator(nil), newFakeInstances(t, instances), nil, nil, WithSinks(sink))
+
+			rec := postAuditLog(h, "event-1", tt.payload)
+
+			r.Equal(tt.wantStatus, rec.Code)
+			r.Equal(tt.wantReads, instances.readCount())
+			if tt.wantVerdict == "" {
+				r.Empty(sink.reported())
+				return
+			}
+			r.Len(sink.reported(), 1)
+			r.Equal(tt.wantVerdict, sink.reported()[0].Verdict)
+		})
+	}
+}
+
+func TestHandleAuditLogQueue(t *testing.T) {
+	t.Parallel()
+	payload := insertAuditLog("v1.compute.instances.insert", true)
+
+	t.Run("queued audit log is acknowledged and processed", func(t *testing.T) {
+		t.Parallel()
+		r := require.New(t)
+
+		q := queue.New(1, 10, 10)
+		q.Start(context.Background())
+		instances := &fakeInstances{instance: testutil.NewInstance()}
+		sink := &recordingSink{}
+		h := NewHandler("p", validate.NewInstanceValidator(nil), newFakeInstances(t, instances), nil, nil,
+			WithQueue(q, time.Second, 1), WithSinks(sink))
+
+		r.Equal(http.StatusOK, postAuditLog(h, "event-1", payload).Code)
+		r.NoError(q.Shutdown(context.Background()))
+		r.Len(sink.reported(), 1)
+	})
+
+	t.Run("audit log is acknowledged before it is processed", func(t *testing.T) {
+		t.Parallel()
+		r := require.New(t)
+
+		// Processing is at most once: a queued audit log is acknowledged, and not redelivered when it is not processed,
+		// e.g. when the instance stops before a worker runs it.
+		q := queue.New(1, 10, 10)
+		instances := &fakeInstances{instance: testutil.NewInstance()}
+		h := NewHandler("p", validate.NewInstanceValidator(nil), newFakeInstances(t, instances), nil, nil, WithQueue(q, time.Second, 1))
+
+		r.Equal(http.StatusOK, postAuditLog(h, "event-1", payload).Code)
+		r.Equal(1, q.Len())
+		r.Zero(instances.readCount())
+	})
+
+	t.Run("dropped audit log releases its event", func(t *testing.T) {
+		t.Parallel()
+		r := require.New(t)
+
+		// The worker is busy until the queue is shut down.
+		q := queue.New(1, 10, 10)
+		q.Start(context.Background())
+		r.NoError(q.Enqueue(queue.Job{Key: "busy", Run: func(ctx context.Context) { <-ctx.Done() }}))
+		r.Eventually(func() bool { return q.Len() == 0 }, time.Second, time.Millisecond)
+
+		store := dedup.NewMemoryStore(10)
+		h := NewHandler("p", validate.NewInstanceValidator(nil), nil, nil, nil,
+			WithQueue(q, time.Second, 1), WithDeduplication(store, time.Hour))
+
+		r.Equal(http.StatusOK, postAuditLog(h, "event-1", payload).Code)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		r.ErrorIs(q.Shutdown(ctx), context.Canceled)
+
+		claimed, err := store.Claim(context.Background(), dedup.EventKey("event-1"), time.Hour)
+		r.NoError(err)
+		r.True(claimed)
+	})
+
+	t.Run("audit log interrupted on shutdown releases its event", func(t *testing.T) {
+		t.Parallel()
+		r := require.New(t)
+
+		// The instance is not readable yet when the queue is shut down.
+		q := queue.New(1, 10, 10)
+		q.Start(context.Background())
+		instances := &fakeInstances{}
+		store := dedup.NewMemoryStore(10)
+		sink := &recordingSink{}
+		h := NewHandler("p", validate.NewInstanceValidator(nil), newFakeInstances(t, instances), nil, nil,
+			WithQueue(q, time.Minute, 1), WithInstanceWaitTimeout(time.Minute), WithDeduplication(store, time.Hour), WithSinks(sink))
+
+		r.Equal(http.StatusOK, postAuditLog(h, "event-1", payload).Code)
+		r.Eventually(func() bool { return instances.readCount() > 0 }, time.Second, time.Millisecond)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		r.ErrorIs(q.Shutdown(ctx), context.Canceled)
+
+		r.Empty(sink.reported())
+		claimed, err := store.Claim(context.Background(), dedup.EventKey("event-1"), time.Hour)
+		r.NoError(err)
+		r.True(claimed)
+	})
+
+	t.Run("full queue", func(t *testing.T) {
+		t.Parallel()
+		r := require.New(t)
+
+		q := queue.New(1, 0, 0)
+		store := dedup.NewMemoryStore(10)
+		h := NewHandler("p", validate.NewInstanceValidator(nil), nil, nil, nil,
+			WithQueue(q, time.Second, 1), WithDeduplication(store, time.Hour))
+
+		r.Equal(http.StatusTooManyRequests, postAuditLog(h, "event-1", payload).Code)
+		// The event is redelivered, and must not be skipped as a duplicate.
+		claimed, err := store.Claim(context.Background(), dedup.EventKey("event-1"), time.Hour)
+		r.NoError(err)
+		r.True(claimed)
+	})
+
+	t.Run("closed queue", func(t *testing.T) {
+		t.Parallel()
+		r := require.New(t)
+
+		q := queue.New(1, 10, 10)
+		r.NoError(q.Shutdown(context.Background()))
+		h := NewHandler("p", validate.NewInstanceValidator(nil), nil, nil, nil, WithQueue(q, time.Second, 1))
+
+		r.Equal(http.StatusServiceUnavailable, postAuditLog(h, "event-1", payload).Code)
+	})
+}
+
+func TestHandleAuditLogDeduplication(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+	payload := insertAuditLog("v1.compute.instances.insert", true)
+
+	instances := &fakeInstances{instance: testutil.NewInstance(), status: http.StatusInternalServerError}
+	sink := &recordingSink{}
+	h := NewHandler("p", validate.NewInstanceValidator(nil), newFakeInstances(t, instances), nil, nil,
+		WithDeduplication(dedup.NewMemoryStore(10), time.Hour), WithSinks(sink))
+
+	// A transient failure releases the event, so its redelivery is processed.
+	r.Equal(http.StatusServiceUnavailable, postAuditLog(h, "event-1", payload).Code)
+	instances.mu.Lock()
+	instances.status = 0
+	instances.mu.Unlock()
+	r.Equal(http.StatusOK, postAuditLog(h, "event-1", payload).Code)
+	r.Equal(2, instances.readCount())
+	r.Len(sink.reported(), 1)
+
+	// Processed events are skipped.
+	r.Equal(http.StatusOK, postAuditLog(h, "event-1", payload).Code)
+	r.Equal(2, instances.readCount())
+
+	// Another event of the same instance metadata revision is read, but not validated again.
+	r.Equal(http.StatusOK, postAuditLog(h, "event-2", payload).Code)
+	r.Equal(3, instances.readCount())
+	r.Len(sink.reported(), 1)
+}
+
+// fakeAction records the instances it is enforced on, failing with err.
+type fakeAction struct {
+	err      error
+	enforced []string
+}
+
+func (a *fakeAction) Name() string {
+	return string(enforce.ModeStop)
+}
+
+func (a *fakeAction) Enforce(_ context.Context, target *enforce.Target) (*enforce.Outcome, error) {
+	a.enforced = append(a.enforced, target.Instance.GetName())
+	return &enforce.Outcome{}, a.err
+}
+
+func TestEnforcePending(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		instance     func(instance *computepb.Instance) *computepb.Instance
+		actionErr    error
+		rateLimited  bool
+		wantErr      bool
+		wantEnforced bool
+		wantResult   string
+	}{
+		{
+			name:         "applied",
+			wantEnforced: true,
+			wantResult:   metrics.ResultApplied,
+		},
+		{
+			name: "approved instance",
+			instance: func(instance *computepb.Instance) *computepb.Instance {
+				instance.Labels[castValidationApprovedLabel] = "true"
+				return instance
+			},
+			wantResult: metrics.ResultCancelled,
+		},
+		{
+			name:        "withheld by the breaker",
+			rateLimited: true,
+			wantResult:  metrics.ResultWithheld,
+		},
+		{
+			name:         "failed",
+			actionErr:    errors.New("permission denied"),
+			wantErr:      true,
+			wantEnforced: true,
+			wantResult:   metrics.ResultFailed,
+		},
+		{
+			name:         "transient failure is not reported until retried",
+			actionErr:    status.Error(codes.Unavailable, "unavailable"),
+			wantErr:      true,
+			wantEnforced: true,
+		},
+		{
+			name:     "deleted instance is not reported",
+			instance: func(*computepb.Instance) *computepb.Instance { return nil },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			r := require.New(t)
+			ctx := context.Background()
+
+			instance := testutil.NewInstance()
+			if tt.instance != nil {
+				instance = tt.instance(instance)
+			}
+
+			action := &fakeAction{err: tt.actionErr}
+			policy, err := enforce.NewPolicy(enforce.ModeStop, nil, action)
+			r.NoError(err)
+			breaker := enforce.NewBreaker(enforce.BreakerLimits{MaxActions: 1, Window: time.Minute}, nil)
+			if tt.rateLimited {
+				r.NoError(breaker.Allow(ctx, "c1"))
+			}
+
+			sink := &recordingSink{}
+			h := NewHandler("p", nil, newFakeInstances(t, &fakeInstances{instance: instance}), nil, policy,
+				WithBreaker(breaker), WithSinks(sink))
+
+			err = h.EnforcePending(ctx, &pending.Action{
+				Project:    "p",
+				Zone:       "z",
+				Instance:   "gke-cluster-pool-1234abcd-x1y2",
+				InstanceID: 42,
+				ClusterID:  "c1",
+				Action:     string(enforce.ModeStop),
+				Principal:  "cast@example.com",
+				Findings:   []findings.Finding{{Type: findings.TypeUnknownCommands, MetadataKey: validate.MetadataConfigureShKey}},
+				Evidence:   "gs://evidence/p/z/gke-cluster-pool-1234abcd-x1y2",
+			})
+			if tt.wantErr {
+				r.Error(err)
+			} else {
+				r.NoError(err)
+			}
+			r.Equal(tt.wantEnforced, len(action.enforced) == 1)
+
+			if tt.wantResult == "" {
+				r.Empty(sink.reported())
+				return
+			}
+			r.Len(sink.reported(), 1)
+			result := sink.reported()[0]
+			r.Equal(validate.VerdictInvalid, result.Verdict)
+			r.Equal("cast@example.com", result.Principal)
+			r.Equal([]findings.Finding{{Type: findings.TypeUnknownCommands, MetadataKey: validate.MetadataConfigureShKey}}, result.Findings)
+			r.Equal(string(enforce.ModeStop), result.Action.Name)
+			r.Equal(tt.wantResult, result.Action.Result)
+			r.Equal("gs://evidence/p/z/gke-cluster-pool-1234abcd-x1y2", result.Action.Evidence)
+		})
+	}
+}
+
+func TestHandleInvalidInstanceEvidenceFailure(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+	ctx := context.Background()
+
+	// The evidence bucket rejects every write.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+	}))
+	t.Cleanup(srv.Close)
+	storageClient, err := storage.NewClient(ctx, option.WithEndpoint(srv.URL), option.WithoutAuthentication())
+	r.NoError(err)
+
+	instance := testutil.NewInstance()
+	instances := newFakeInstances(t, &fakeInstances{instance: instance})
+	action := &fakeAction{}
+	policy, err := enforce.NewPolicy(enforce.ModeStop, nil, action)
+	r.NoError(err)
+	breaker := enforce.NewBreaker(enforce.BreakerLimits{MaxActions: 1, Window: time.Minute}, nil)
+
+	h := NewHandler("p", nil, instances, nil, policy,
+		WithBreaker(breaker), WithEvidenceCollector(evidence.NewCollector(instances, nil, storageClient, "evidence")))
+
+	target := &enforce.Target{Project: "p", Zone: "z", Instance: instance}
+	taken, err := h.handleInvalidInstance(ctx, logrus.NewEntry(logrus.StandardLogger()), target, &AuditLog{}, errors.New("invalid"))
+	r.Error(err)
+	r.Equal(metrics.ResultFailed, taken.Result)
+	r.Empty(action.enforced)
+	r.NoError(breaker.Allow(ctx, "c1"), "actions not enforced are not counted by the breaker")
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/castai/gcp-node-validator/container/pending"
+	"github.com/sirupsen/logrus"
+)
+
+// PendingHandler lists and approves instances with pending enforcement actions.
+type PendingHandler struct {
+	logger    logrus.FieldLogger
+	scheduler *pending.Scheduler
+}
+
+func NewPendingHandler(scheduler *pending.Scheduler) *PendingHandler {
+	return &PendingHandler{
+		logger:    logrus.StandardLogger(),
+		scheduler: scheduler,
+	}
+}
+
+type pendingResponse struct {
+	Actions []*pending.Action `json:"actions"`
+}
+
+// HandleList responds with the pending actions, ordered by due time.
+func (h *PendingHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	actions, err := h.scheduler.List(r.Context())
+	if err != nil {
+		h.logger.WithError(err).Error("failed to list pending actions")
+		http.Error(w, "failed to list pending actions", http.StatusInternalServerError)
+		return
+	}
+
+	slices.SortFunc(actions, func(a, b *pending.Action) int {
+		if c := a.DueAt.Compare(b.DueAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Key(), b.Key())
+	})
+	if actions == nil {
+		actions = []*pending.Action{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(pendingResponse{Actions: actions}); err != nil {
+		h.logger.WithError(err).Errorf("failed to write response")
+	}
+}
+
+// HandleApprove cancels the pending action of the instance identified by the project, zone and instance path values.
+func (h *PendingHandler) HandleApprove(w http.ResponseWriter, r *http.Request) {
+	project, zone, name := r.PathValue("project"), r.PathValue("zone"), r.PathValue("instance")
+	log := h.logger.WithFields(logrus.Fields{
+		"project":      project,
+		"zone":         zone,
+		"instanceName": name,
+	})
+
+	if err := h.scheduler.Approve(r.Context(), project, zone, name); err != nil {
+		if errors.Is(err, pending.ErrNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		log.WithError(err).Error("failed to approve instance")
+		http.Error(w, "failed to approve instance", http.StatusInternalServerError)
+		return
+	}
+
+	log.Info("instance approved, pending action cancelled")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write([]byte("OK")); err != nil {
+		log.WithError(err).Errorf("failed to write response")
+	}
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/castai/gcp-node-validator/container/enforce"
+	"github.com/castai/gcp-node-validator/container/gcperr"
+	"github.com/sirupsen/logrus"
+)
+
+// QuarantineHandler releases quarantined instances.
+type QuarantineHandler struct {
+	logger      logrus.FieldLogger
+	quarantiner *enforce.Quarantiner
+}
+
+func NewQuarantineHandler(quarantiner *enforce.Quarantiner) *QuarantineHandler {
+	return &QuarantineHandler{
+		logger:      logrus.StandardLogger(),
+		quarantiner: quarantiner,
+	}
+}
+
+// HandleRelease restores the network tags of the instance identified by the project, zone and instance path values.
+func (h *QuarantineHandler) HandleRelease(w http.ResponseWriter, r *http.Request) {
+	project, zone, name := r.PathValue("project"), r.PathValue("zone"), r.PathValue("instance")
+	log := h.logger.WithFields(logrus.Fields{
+		"project":      project,
+		"zone":         zone,
+		"instanceName": name,
+	})
+
+	if err := h.quarantiner.Release(r.Context(), project, zone, name); err != nil {
+		log.WithError(err).Error("failed to release instance")
+
+		switch {
+		case errors.Is(err, enforce.ErrNotQuarantined):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case gcperr.IsNotFound(err):
+			http.Error(w, "instance not found", http.StatusNotFound)
+		default:
+			http.Error(w, "failed to release instance", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	log.Info("instance released from quarantine")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write([]byte("OK")); err != nil {
+		log.WithError(err).Errorf("failed to write response")
+	}
+}
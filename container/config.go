@@ -0,0 +1,215 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/castai/gcp-node-validator/container/configfile"
+	"github.com/castai/gcp-node-validator/container/enforce"
+	"github.com/castai/gcp-node-validator/container/exempt"
+	"github.com/castai/gcp-node-validator/container/findings"
+	"github.com/castai/gcp-node-validator/container/validate"
+	"github.com/kelseyhightower/envconfig"
+	log "github.com/sirupsen/logrus"
+)
+
+const envPrefix = "APP"
+
+// ConfigFileConfig locates the optional YAML config file. It is only read from the APP_CONFIGFILE_ environment
+// variables, as it can not be part of the file.
+type ConfigFileConfig struct {
+	// Path of the config file, e.g. a mounted Secret, or a gs://bucket/object URL.
+	Path string `required:"false"`
+	// ReloadInterval in seconds at which the file is read again, to apply changes. Not reloaded when 0.
+	ReloadInterval int `default:"30"`
+}
+
+// reloadableFields are the fields of the config applied when the config file is reloaded, by their path in the file.
+// Changes of other fields are applied on restart.
+var reloadableFields = map[string]bool{
+	"logLevel":               true,
+	"allowedPrincipals":      true,
+	"protectionMode":         true,
+	"clusterProtectionModes": true,
+	"deleteInvalid":          true,
+	"quarantineInvalid":      true,
+	"exceptions.list":        true,
+}
+
+// loadConfig processes the config from the config file data, which may be empty, and from the environment variables
+// overriding its fields, and validates it.
+func loadConfig(data []byte) (*Config, error) {
+	cfg := &Config{}
+	if err := envconfig.Process(envPrefix, cfg); err != nil {
+		return nil, fmt.Errorf("failed to process config: %w", err)
+	}
+	if err := configfile.Overlay(data, envPrefix, cfg); err != nil {
+		return nil, fmt.Errorf("invalid config file:\n%w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config:\n%w", err)
+	}
+	return cfg, nil
+}
+
+// Validate checks the fields which can not be checked by their type, reporting every invalid field.
+func (c *Config) Validate() error {
+	var errs []error
+	invalid := func(path, format string, args ...any) {
+		errs = append(errs, fieldError(path, format, args...))
+	}
+
+	if c.ProjectID == "" {
+		invalid("projectID", "is required")
+	}
+	if c.WhitelistBucket.Name == "" {
+		invalid("whitelistBucket.name", "is required")
+	}
+	if c.Port < 1 || c.Port > 65535 {
+		invalid("port", "must be between 1 and 65535, got %d", c.Port)
+	}
+	if _, _, err := parseProtectionModes(c); err != nil {
+		invalid("protectionMode", "%v", err)
+	}
+	if _, err := enforce.ParseGroupMethod(c.InstanceGroupMethod); err != nil {
+		invalid("instanceGroupMethod", "%v", err)
+	}
+	for i := range c.Exceptions.List {
+		if err := c.Exceptions.List[i].Validate(); err != nil {
+			invalid("exceptions.list", "%v", err)
+		}
+	}
+	if time.Duration(c.ShutdownTimeout)*time.Second <= flushTimeout {
+		invalid("shutdownTimeout", "must exceed the %v reserved for flushing results, got %d", flushTimeout, c.ShutdownTimeout)
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		invalid("tracing.sampleRatio", "must be between 0 and 1, got %v", c.Tracing.SampleRatio)
+	}
+	if c.Breaker.MaxInvalidRatio < 0 || c.Breaker.MaxInvalidRatio > 1 {
+		invalid("breaker.maxInvalidRatio", "must be between 0 and 1, got %v", c.Breaker.MaxInvalidRatio)
+	}
+	if c.Pending.Delay > 0 && c.Pending.Bucket == "" && c.Pending.Path == "" {
+		invalid("pending.bucket", "a bucket or path is required with an enforcement delay")
+	}
+	if c.Notify.WebhookURL != "" && c.Notify.WebhookSecret == "" {
+		invalid("notify.webhookSecret", "is required with a webhook URL")
+	}
+	if c.FindingsStore.DSN != "" && c.FindingsStore.Driver != findings.DriverSQLite && c.FindingsStore.Driver != findings.DriverPostgres {
+		invalid("findingsStore.driver", "must be %s or %s, got %q", findings.DriverSQLite, findings.DriverPostgres, c.FindingsStore.Driver)
+	}
+
+	for path, value := range map[string]int{
+		"instanceWaitTimeout": c.InstanceWaitTimeout,
+		"readinessTimeout":    c.ReadinessTimeout,
+		"server.readTimeout":  c.Server.ReadTimeout,
+		"server.writeTimeout": c.Server.WriteTimeout,
+		"server.idleTimeout":  c.Server.IdleTimeout,
+		"queue.workers":       c.Queue.Workers,
+		"queue.jobTimeout":    c.Queue.JobTimeout,
+		"pending.delay":       c.Pending.Delay,
+		"breaker.maxActions":  c.Breaker.MaxActions,
+	} {
+		if value < 0 {
+			invalid(path, "must not be negative, got %d", value)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// fieldError names the field by its path in the config file and its environment variable.
+func fieldError(path, format string, args ...any) error {
+	envKey := envPrefix + "_" + strings.ToUpper(strings.ReplaceAll(path, ".", "_"))
+	return fmt.Errorf("%s (%s): %s", path, envKey, fmt.Sprintf(format, args...))
+}
+
+// parseProtectionModes returns the default protection mode and the modes of clusters.
+func parseProtectionModes(cfg *Config) (enforce.Mode, map[string]enforce.Mode, error) {
+	defaultMode := enforce.ModeLog
+	switch {
+	case cfg.ProtectionMode != "":
+		mode, err := enforce.ParseMode(cfg.ProtectionMode)
+		if err != nil {
+			return "", nil, err
+		}
+		defaultMode = mode
+	case cfg.DeleteInvalid && cfg.QuarantineInvalid:
+		return "", nil, fmt.Errorf("only one of delete and quarantine of invalid instances can be enabled")
+	case cfg.DeleteInvalid:
+		defaultMode = enforce.ModeDelete
+	case cfg.QuarantineInvalid:
+		defaultMode = enforce.ModeQuarantine
+	}
+
+	clusterModes := make(map[string]enforce.Mode, len(cfg.ClusterProtectionModes))
+	for clusterID, value := range cfg.ClusterProtectionModes {
+		mode, err := enforce.ParseMode(value)
+		if err != nil {
+			return "", nil, fmt.Errorf("cluster %s: %w", clusterID, err)
+		}
+		clusterModes[clusterID] = mode
+	}
+
+	return defaultMode, clusterModes, nil
+}
+
+func setLogLevel(level string) {
+	logLevel, err := log.ParseLevel(level)
+	if err != nil {
+		log.Warnf("invalid log level %s, defaulting to info", level)
+		logLevel = log.InfoLevel
+	}
+	log.SetLevel(logLevel)
+}
+
+// configReloader applies the reloadable fields of a reloaded config file to the components using them.
+type configReloader struct {
+	current    *Config
+	validator  *validate.InstanceValidator
+	policy     *enforce.Policy
+	exceptions *exempt.DynamicList
+}
+
+func (r *configReloader) reload(data []byte) {
+	next, err := loadConfig(data)
+	if err != nil {
+		log.WithError(err).Error("failed to reload config, keeping the current config")
+		return
+	}
+
+	changed := configfile.Diff(r.current, next)
+	if len(changed) == 0 {
+		return
+	}
+
+	defaultMode, clusterModes, err := parseProtectionModes(next)
+	if err == nil {
+		err = r.policy.SetModes(defaultMode, clusterModes)
+	}
+	if err != nil {
+		log.WithError(err).Error("failed to reload protection modes, keeping the current config")
+		return
+	}
+	setLogLevel(next.LogLevel)
+	r.validator.SetAllowedPrincipals(next.AllowedPrincipals)
+	r.exceptions.Set(next.Exceptions.List)
+
+	var applied, restart []string
+	for _, path := range changed {
+		if reloadableFields[path] {
+			applied = append(applied, path)
+		} else {
+			restart = append(restart, path)
+		}
+	}
+	if len(applied) > 0 {
+		log.WithField("fields", applied).Info("reloaded config")
+	}
+	if len(restart) > 0 {
+		log.WithField("fields", restart).Warn("config changes are applied on restart")
+	}
+
+	r.current = next
+}
@@ -0,0 +1,185 @@
+// Package configfile overlays a YAML configuration file on a configuration processed from environment variables by
+// envconfig, so that the file holds the configuration and environment variables override individual fields.
+package configfile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Error is an invalid field of the file.
+type Error struct {
+	Line   int
+	Column int
+	// Path of the field, as the keys of the file separated by dots.
+	Path string
+	Err  error
+}
+
+func (e *Error) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("line %d, column %d: %v", e.Line, e.Column, e.Err)
+	}
+	return fmt.Sprintf("line %d, column %d: %s: %v", e.Line, e.Column, e.Path, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Overlay sets the fields of cfg, a pointer to a struct, to the values of the YAML document, except the fields set by
+// non-empty environment variables with the envconfig prefix, which keep their value. Keys match the field names
+// case-insensitively, like the environment variables, and nested structs are mappings. Other values are decoded as
+// JSON, so types decode as in JSON, e.g. by their json tags. Fields tagged `yaml:"-"` cannot be set by the file.
+//
+// All invalid fields are reported, as errors joining an *Error per field.
+func Overlay(data []byte, prefix string, cfg any) error {
+	v := reflect.ValueOf(cfg)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("config must be a pointer to a struct, got %T", cfg)
+	}
+
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return fmt.Errorf("failed to parse config file: %w", err)
+	}
+	// An empty file has no content.
+	if len(document.Content) == 0 {
+		return nil
+	}
+
+	var errs []error
+	overlay(document.Content[0], v.Elem(), "", strings.ToUpper(prefix), &errs)
+	return errors.Join(errs...)
+}
+
+func overlay(node *yaml.Node, v reflect.Value, path, envKey string, errs *[]error) {
+	if node.Kind != yaml.MappingNode {
+		*errs = append(*errs, nodeError(node, path, "expected a mapping of fields"))
+		return
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode, valueNode := node.Content[i], node.Content[i+1]
+		fieldPath := joinPath(path, keyNode.Value)
+
+		field, found := fieldByKey(v.Type(), keyNode.Value)
+		if !found {
+			*errs = append(*errs, nodeError(keyNode, fieldPath, "unknown field"))
+			continue
+		}
+		if field.Tag.Get("yaml") == "-" {
+			*errs = append(*errs, nodeError(keyNode, fieldPath, "can only be set by the %s environment variable", fieldEnvKey(envKey, field)))
+			continue
+		}
+
+		value := v.FieldByIndex(field.Index)
+		if field.Type.Kind() == reflect.Struct {
+			overlay(valueNode, value, fieldPath, fieldEnvKey(envKey, field), errs)
+			continue
+		}
+
+		// Deployments commonly set every variable, empty ones do not override the file.
+		if os.Getenv(fieldEnvKey(envKey, field)) != "" {
+			continue
+		}
+		if err := decode(valueNode, value); err != nil {
+			*errs = append(*errs, nodeError(valueNode, fieldPath, "expected %s: %v", field.Type, err))
+		}
+	}
+}
+
+// decode decodes the node into the value through JSON, leaving the value unchanged when decoding fails.
+func decode(node *yaml.Node, value reflect.Value) error {
+	var raw any
+	if err := node.Decode(&raw); err != nil {
+		return err
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+
+	decoded := reflect.New(value.Type())
+	if err := json.Unmarshal(data, decoded.Interface()); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return fmt.Errorf("got %s", typeErr.Value)
+		}
+		return err
+	}
+	value.Set(decoded.Elem())
+	return nil
+}
+
+// Diff returns the paths of the fields whose values differ between a and b, pointers to structs of the same type.
+func Diff(a, b any) []string {
+	var paths []string
+	diff(reflect.ValueOf(a).Elem(), reflect.ValueOf(b).Elem(), "", &paths)
+	return paths
+}
+
+func diff(a, b reflect.Value, path string, paths *[]string) {
+	for _, field := range reflect.VisibleFields(a.Type()) {
+		if !field.IsExported() || field.Anonymous {
+			continue
+		}
+
+		fieldPath := joinPath(path, Key(field.Name))
+		if field.Type.Kind() == reflect.Struct {
+			diff(a.FieldByIndex(field.Index), b.FieldByIndex(field.Index), fieldPath, paths)
+			continue
+		}
+		if !reflect.DeepEqual(a.FieldByIndex(field.Index).Interface(), b.FieldByIndex(field.Index).Interface()) {
+			*paths = append(*paths, fieldPath)
+		}
+	}
+}
+
+// Key returns the key of the field in the file, the field name in lower camel case, e.g. webhookURL for WebhookURL.
+func Key(fieldName string) string {
+	runes := []rune(fieldName)
+	for i := range runes {
+		// The last upper case letter of an initialism followed by a word starts the word.
+		if !unicode.IsUpper(runes[i]) || (i > 0 && i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+			break
+		}
+		runes[i] = unicode.ToLower(runes[i])
+	}
+	return string(runes)
+}
+
+func fieldByKey(t reflect.Type, key string) (reflect.StructField, bool) {
+	for _, field := range reflect.VisibleFields(t) {
+		if field.IsExported() && !field.Anonymous && strings.EqualFold(field.Name, key) {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// fieldEnvKey returns the environment variable of the field, as named by envconfig without split_words.
+func fieldEnvKey(envKey string, field reflect.StructField) string {
+	if envKey == "" {
+		return strings.ToUpper(field.Name)
+	}
+	return envKey + "_" + strings.ToUpper(field.Name)
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func nodeError(node *yaml.Node, path, format string, args ...any) *Error {
+	return &Error{Line: node.Line, Column: node.Column, Path: path, Err: fmt.Errorf(format, args...)}
+}
@@ -0,0 +1,215 @@
+package configfile
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testSection struct {
+	Topic      string
+	WebhookURL string
+	TTL        int
+}
+
+type testRule struct {
+	Name    string `json:"name"`
+	Cluster string `json:"cluster,omitempty"`
+}
+
+type testConfig struct {
+	ProjectID    string
+	Port         int
+	ClusterIDs   []string
+	ClusterModes map[string]string
+	Rules        []testRule
+	Section      testSection
+	Location     string `yaml:"-"`
+}
+
+func TestOverlay(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		data    string
+		want    *testConfig
+		wantErr []string
+	}{
+		{
+			name: "empty file",
+			data: "",
+			want: &testConfig{Port: 8080},
+		},
+		{
+			name: "all fields",
+			data: `
+projectID: project
+port: 9090
+clusterIDs: [cluster-1, cluster-2]
+clusterModes:
+  cluster-1: delete
+rules:
+  - name: rule
+    cluster: cluster-1
+section:
+  topic: findings
+  webhookURL: https://example.com
+`,
+			want: &testConfig{
+				ProjectID:    "project",
+				Port:         9090,
+				ClusterIDs:   []string{"cluster-1", "cluster-2"},
+				ClusterModes: map[string]string{"cluster-1": "delete"},
+				Rules:        []testRule{{Name: "rule", Cluster: "cluster-1"}},
+				Section:      testSection{Topic: "findings", WebhookURL: "https://example.com"},
+			},
+		},
+		{
+			name: "keys match field names case-insensitively",
+			data: "ProjectId: project\nSECTION:\n  ttl: 60\n",
+			want: &testConfig{ProjectID: "project", Port: 8080, Section: testSection{TTL: 60}},
+		},
+		{
+			name: "invalid fields",
+			data: `
+projectIDs: project
+port: eighty
+location: /etc/config.yaml
+section:
+  topic: [findings]
+  ttl: 60
+`,
+			want: &testConfig{Port: 8080, Section: testSection{TTL: 60}},
+			wantErr: []string{
+				`line 2, column 1: projectIDs: unknown field`,
+				`line 3, column 7: port: expected int: got string`,
+				`line 4, column 1: location: can only be set by the APP_LOCATION environment variable`,
+				`line 6, column 10: section.topic: expected string: got array`,
+			},
+		},
+		{
+			name:    "section is not a mapping",
+			data:    "section: findings\n",
+			want:    &testConfig{Port: 8080},
+			wantErr: []string{`line 1, column 10: section: expected a mapping of fields`},
+		},
+		{
+			name:    "document is not a mapping",
+			data:    "- project\n",
+			want:    &testConfig{Port: 8080},
+			wantErr: []string{`line 1, column 1: expected a mapping of fields`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			r := require.New(t)
+
+			cfg := &testConfig{Port: 8080}
+			err := Overlay([]byte(tt.data), "APP", cfg)
+			if len(tt.wantErr) > 0 {
+				r.Error(err)
+				for _, want := range tt.wantErr {
+					r.ErrorContains(err, want)
+				}
+			} else {
+				r.NoError(err)
+			}
+			r.Equal(tt.want, cfg)
+		})
+	}
+}
+
+func TestOverlayEnvironmentOverrides(t *testing.T) {
+	r := require.New(t)
+	t.Setenv("TEST_PORT", "9090")
+	t.Setenv("TEST_SECTION_TOPIC", "from-env")
+	t.Setenv("TEST_PROJECTID", "")
+
+	// The config processed from the environment variables.
+	cfg := &testConfig{Port: 9090, Section: testSection{Topic: "from-env"}}
+	r.NoError(Overlay([]byte("port: 8081\nprojectID: project\nsection:\n  topic: from-file\n  ttl: 60\n"), "test", cfg))
+	r.Equal(&testConfig{ProjectID: "project", Port: 9090, Section: testSection{Topic: "from-env", TTL: 60}}, cfg)
+}
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+
+	a := &testConfig{ProjectID: "project", ClusterModes: map[string]string{"cluster-1": "log"}}
+	b := &testConfig{ProjectID: "project", ClusterModes: map[string]string{"cluster-1": "delete"}, Section: testSection{WebhookURL: "https://example.com"}}
+
+	require.Empty(t, Diff(a, a))
+	require.Equal(t, []string{"clusterModes", "section.webhookURL"}, Diff(a, b))
+}
+
+func TestKey(t *testing.T) {
+	t.Parallel()
+
+	for fieldName, want := range map[string]string{
+		"ProjectID":  "projectID",
+		"PubSub":     "pubSub",
+		"SCC":        "scc",
+		"TTL":        "ttl",
+		"WebhookURL": "webhookURL",
+		"URLPath":    "urlPath",
+	} {
+		require.Equal(t, want, Key(fieldName))
+	}
+}
+
+func TestWatch(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	r.NoError(os.WriteFile(path, []byte("port: 8080\n"), 0o600))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mu sync.Mutex
+	var reloaded []string
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		Watch(ctx, FileSource(path), 10*time.Millisecond, []byte("port: 8080\n"), func(data []byte) {
+			mu.Lock()
+			defer mu.Unlock()
+			reloaded = append(reloaded, string(data))
+		})
+	}()
+
+	// Unchanged content is not reloaded.
+	time.Sleep(50 * time.Millisecond)
+	r.NoError(os.WriteFile(path, []byte("port: 9090\n"), 0o600))
+	r.Eventually(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(reloaded) > 0
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	<-done
+	r.Equal([]string{"port: 9090\n"}, reloaded)
+}
+
+func TestNewSource(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	source, err := NewSource(ctx, "/etc/node-validator/config.yaml")
+	require.NoError(t, err)
+	require.Equal(t, FileSource("/etc/node-validator/config.yaml"), source)
+	require.NoError(t, source.Close())
+
+	_, err = NewSource(ctx, "gs://bucket")
+	require.EqualError(t, err, `invalid config file location "gs://bucket", expected gs://bucket/object`)
+}
@@ -0,0 +1,118 @@
+package configfile
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// maxSize of the config file.
+const maxSize = 1024 * 1024
+
+// Source reads the config file.
+type Source interface {
+	Read(ctx context.Context) ([]byte, error)
+	// Close releases the clients of the source.
+	Close() error
+}
+
+// NewSource returns the source of the location, a gs://bucket/object URL of an object in GCS, or the path of a local
+// file, e.g. a mounted Secret.
+func NewSource(ctx context.Context, location string) (Source, error) {
+	if !strings.HasPrefix(location, "gs://") {
+		return FileSource(location), nil
+	}
+
+	bucket, object, found := strings.Cut(strings.TrimPrefix(location, "gs://"), "/")
+	if !found || bucket == "" || object == "" {
+		return nil, fmt.Errorf("invalid config file location %q, expected gs://bucket/object", location)
+	}
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cloud storage client: %w", err)
+	}
+	return &ObjectSource{client: client, bucket: bucket, object: object}, nil
+}
+
+// FileSource is the path of a local config file.
+type FileSource string
+
+func (s FileSource) Read(context.Context) ([]byte, error) {
+	data, err := os.ReadFile(string(s))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	if len(data) > maxSize {
+		return nil, fmt.Errorf("config file of %d bytes is too large", len(data))
+	}
+	return data, nil
+}
+
+func (s FileSource) Close() error {
+	return nil
+}
+
+// ObjectSource is a config file in GCS.
+type ObjectSource struct {
+	client *storage.Client
+	bucket string
+	object string
+}
+
+func (s *ObjectSource) Read(ctx context.Context) ([]byte, error) {
+	reader, err := s.client.Bucket(s.bucket).Object(s.object).NewReader(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config object: %w", err)
+	}
+	defer reader.Close()
+
+	if reader.Attrs.Size > maxSize {
+		return nil, fmt.Errorf("config object of %d bytes is too large", reader.Attrs.Size)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config object: %w", err)
+	}
+	return data, nil
+}
+
+func (s *ObjectSource) Close() error {
+	return s.client.Close()
+}
+
+// Watch reads the source every interval and calls reload with the content when it differs from the last content,
+// initially data, until ctx is done. Read failures are logged and retried at the next interval.
+func Watch(ctx context.Context, source Source, interval time.Duration, data []byte, reload func(data []byte)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		next, err := source.Read(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			logrus.WithError(err).Warn("failed to read config file, keeping the current config")
+			continue
+		}
+		if bytes.Equal(next, data) {
+			continue
+		}
+
+		data = next
+		reload(data)
+	}
+}
@@ -0,0 +1,251 @@
+// Package dashboard serves a read-only HTML dashboard of the validation results in the findings store.
+package dashboard
+
+import (
+	"cmp"
+	"context"
+	"embed"
+	"html/template"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/castai/gcp-node-validator/container/findings"
+	"github.com/castai/gcp-node-validator/container/metrics"
+	"github.com/castai/gcp-node-validator/container/validate"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultWindow = 24 * time.Hour
+	maxWindow     = 30 * 24 * time.Hour
+	// topFragments is the number of most common unknown command fragments shown.
+	topFragments = 10
+)
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+var templates = template.Must(template.New("").Funcs(template.FuncMap{
+	"diffLines": func(findingList []findings.Finding) []string {
+		return strings.Split(strings.TrimSuffix(findings.Diff(findingList), "\n"), "\n")
+	},
+	"hasPrefix": strings.HasPrefix,
+	"time": func(t time.Time) string {
+		return t.UTC().Format("2006-01-02 15:04:05 UTC")
+	},
+}).ParseFS(templateFS, "templates/*.html"))
+
+// WhitelistVersions lists the versions of a whitelist source.
+type WhitelistVersions interface {
+	Versions(ctx context.Context) ([]validate.SourceVersion, error)
+}
+
+// Option configures a Handler.
+type Option func(*Handler)
+
+// WithWhitelistVersions shows the versions of the whitelist source on the summary page.
+func WithWhitelistVersions(versions WhitelistVersions) Option {
+	return func(h *Handler) {
+		h.whitelistVersions = versions
+	}
+}
+
+// Handler serves the dashboard pages.
+type Handler struct {
+	logger            logrus.FieldLogger
+	store             findings.Store
+	whitelistVersions WhitelistVersions
+	now               func() time.Time
+}
+
+func NewHandler(store findings.Store, opts ...Option) *Handler {
+	h := &Handler{
+		logger: logrus.StandardLogger(),
+		store:  store,
+		now:    time.Now,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// ClusterSummary counts the latest verdicts of the instances of a cluster.
+type ClusterSummary struct {
+	Cluster      string
+	ClusterName  string
+	Valid        int
+	Invalid      int
+	Unverifiable int
+	// Instances which are invalid or unverifiable, by their latest result.
+	Instances []*findings.ValidationResult
+	// LastEnforcement is the latest result with an applied enforcement action.
+	LastEnforcement *findings.ValidationResult
+}
+
+// Fragment is an unknown command line and the number of instances running it.
+type Fragment struct {
+	Line      string
+	Instances int
+}
+
+type summaryPage struct {
+	Window    time.Duration
+	Since     time.Time
+	Results   int
+	Truncated bool
+	Clusters  []*ClusterSummary
+	Fragments []Fragment
+	Versions  []validate.SourceVersion
+	// VersionsErr is shown instead of the versions when they could not be listed.
+	VersionsErr string
+}
+
+type instancePage struct {
+	Name    string
+	Latest  *findings.ValidationResult
+	History []*findings.ValidationResult
+}
+
+// HandleSummary renders the per-cluster summaries of the results in the window query parameter, a duration
+// defaulting to 24h.
+func (h *Handler) HandleSummary(w http.ResponseWriter, r *http.Request) {
+	window := defaultWindow
+	if value := r.URL.Query().Get("window"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 || parsed > maxWindow {
+			http.Error(w, "invalid window, expected a duration up to "+maxWindow.String(), http.StatusBadRequest)
+			return
+		}
+		window = parsed
+	}
+
+	since := h.now().Add(-window)
+	results, err := h.store.Query(r.Context(), &findings.Query{Since: since, Limit: findings.MaxLimit})
+	if err != nil {
+		h.logger.WithError(err).Error("failed to query findings")
+		http.Error(w, "failed to query findings", http.StatusInternalServerError)
+		return
+	}
+
+	clusters, fragments := summarize(results)
+	page := &summaryPage{
+		Window:    window,
+		Since:     since,
+		Results:   len(results),
+		Truncated: len(results) == findings.MaxLimit,
+		Clusters:  clusters,
+		Fragments: fragments,
+	}
+	if h.whitelistVersions != nil {
+		page.Versions, err = h.whitelistVersions.Versions(r.Context())
+		if err != nil {
+			h.logger.WithError(err).Warn("failed to list whitelist versions")
+			page.VersionsErr = "failed to list whitelist versions"
+		}
+	}
+
+	h.render(w, "summary.html", page)
+}
+
+// HandleInstance renders the results of the instance identified by the name path value, with the diffs of its
+// scripts against the whitelist.
+func (h *Handler) HandleInstance(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	history, err := h.store.Query(r.Context(), &findings.Query{Instance: name})
+	if err != nil {
+		h.logger.WithError(err).WithField("instanceName", name).Error("failed to query findings")
+		http.Error(w, "failed to query findings", http.StatusInternalServerError)
+		return
+	}
+	if len(history) == 0 {
+		http.Error(w, "no results of instance "+name, http.StatusNotFound)
+		return
+	}
+
+	h.render(w, "instance.html", &instancePage{Name: name, Latest: history[0], History: history})
+}
+
+func (h *Handler) render(w http.ResponseWriter, name string, page any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := templates.ExecuteTemplate(w, name, page); err != nil {
+		h.logger.WithError(err).WithField("template", name).Error("failed to render dashboard")
+	}
+}
+
+// summarize returns the summaries of the clusters by the latest result of each instance, and the most common unknown
+// command lines. Results are ordered newest first.
+func summarize(results []*findings.ValidationResult) ([]*ClusterSummary, []Fragment) {
+	clusters := map[string]*ClusterSummary{}
+	seen := map[string]bool{}
+	fragments := map[string]int{}
+
+	for _, result := range results {
+		summary, found := clusters[result.Cluster]
+		if !found {
+			summary = &ClusterSummary{Cluster: result.Cluster, ClusterName: result.ClusterName}
+			clusters[result.Cluster] = summary
+		}
+		if summary.ClusterName == "" {
+			summary.ClusterName = result.ClusterName
+		}
+		if summary.LastEnforcement == nil && result.Action != nil && result.Action.Result == metrics.ResultApplied {
+			summary.LastEnforcement = result
+		}
+
+		key := result.Project + "/" + result.Zone + "/" + result.Instance + "/" + result.InstanceID
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		switch result.Verdict {
+		case validate.VerdictValid:
+			summary.Valid++
+			continue
+		case validate.VerdictInvalid:
+			summary.Invalid++
+		case validate.VerdictUnverifiable:
+			summary.Unverifiable++
+		}
+		summary.Instances = append(summary.Instances, result)
+
+		lines := map[string]bool{}
+		for _, finding := range result.Findings {
+			for _, line := range finding.Lines {
+				lines[line] = true
+			}
+		}
+		for line := range lines {
+			fragments[line]++
+		}
+	}
+
+	// Clusters with the most invalid instances come first.
+	summaries := make([]*ClusterSummary, 0, len(clusters))
+	for _, summary := range clusters {
+		summaries = append(summaries, summary)
+	}
+	slices.SortFunc(summaries, func(a, b *ClusterSummary) int {
+		return cmp.Or(
+			cmp.Compare(b.Invalid, a.Invalid),
+			cmp.Compare(b.Unverifiable, a.Unverifiable),
+			strings.Compare(a.Cluster, b.Cluster),
+		)
+	})
+
+	top := make([]Fragment, 0, len(fragments))
+	for line, instances := range fragments {
+		top = append(top, Fragment{Line: line, Instances: instances})
+	}
+	slices.SortFunc(top, func(a, b Fragment) int {
+		return cmp.Or(cmp.Compare(b.Instances, a.Instances), strings.Compare(a.Line, b.Line))
+	})
+	if len(top) > topFragments {
+		top = top[:topFragments]
+	}
+
+	return summaries, top
+}
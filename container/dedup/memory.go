@@ -0,0 +1,75 @@
+package dedup
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryEntry struct {
+	key       string
+	expiresAt time.Time
+}
+
+// MemoryStore is a Store bounded to a fixed number of keys, evicting the least recently claimed key first.
+type MemoryStore struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	order   *list.List
+
+	now func() time.Time
+}
+
+func NewMemoryStore(size int) *MemoryStore {
+	return &MemoryStore{
+		size:    size,
+		entries: make(map[string]*list.Element, size),
+		order:   list.New(),
+		now:     time.Now,
+	}
+}
+
+func (s *MemoryStore) Claim(_ context.Context, key string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+
+	if el, found := s.entries[key]; found {
+		entry := el.Value.(*memoryEntry)
+		if now.Before(entry.expiresAt) {
+			return false, nil
+		}
+
+		entry.expiresAt = now.Add(ttl)
+		s.order.MoveToFront(el)
+		return true, nil
+	}
+
+	s.entries[key] = s.order.PushFront(&memoryEntry{
+		key:       key,
+		expiresAt: now.Add(ttl),
+	})
+
+	for s.order.Len() > s.size {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(*memoryEntry).key)
+	}
+
+	return true, nil
+}
+
+func (s *MemoryStore) Release(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if el, found := s.entries[key]; found {
+		s.order.Remove(el)
+		delete(s.entries, key)
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package dedup
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Store records the keys of work that has already been claimed, so that redelivered or overlapping
+// events are processed only once.
+type Store interface {
+	// Claim records the key for the ttl duration. It returns false when the key is already claimed.
+	Claim(ctx context.Context, key string, ttl time.Duration) (bool, error)
+	// Release removes the claim, so that the key can be claimed again.
+	Release(ctx context.Context, key string) error
+}
+
+// EventKey returns the key of a delivered event.
+func EventKey(eventID string) string {
+	return fmt.Sprintf("event:%s", eventID)
+}
+
+// InstanceKey returns the key of an instance at the given metadata revision.
+func InstanceKey(instanceID uint64, metadataFingerprint string) string {
+	return fmt.Sprintf("instance:%d:%s", instanceID, metadataFingerprint)
+}
+
+// TieredStore checks a fast store before a persistent one, so that most duplicates never reach the
+// persistent backend.
+type TieredStore struct {
+	front Store
+	back  Store
+}
+
+func NewTieredStore(front, back Store) *TieredStore {
+	return &TieredStore{
+		front: front,
+		back:  back,
+	}
+}
+
+func (s *TieredStore) Claim(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	claimed, err := s.front.Claim(ctx, key, ttl)
+	if err != nil || !claimed {
+		return claimed, err
+	}
+
+	claimed, err = s.back.Claim(ctx, key, ttl)
+	if err != nil {
+		if releaseErr := s.front.Release(ctx, key); releaseErr != nil {
+			return false, fmt.Errorf("failed to release key after claim error %v: %w", err, releaseErr)
+		}
+		return false, err
+	}
+
+	return claimed, nil
+}
+
+func (s *TieredStore) Release(ctx context.Context, key string) error {
+	if err := s.back.Release(ctx, key); err != nil {
+		return err
+	}
+
+	return s.front.Release(ctx, key)
+}
@@ -0,0 +1,128 @@
+package dedup
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/castai/gcp-node-validator/container/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStores(t *testing.T, clock *testutil.Clock) map[string]Store {
+	t.Helper()
+
+	memory := NewMemoryStore(10)
+	memory.now = clock.Now
+
+	file, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+	file.now = clock.Now
+
+	tieredFront := NewMemoryStore(10)
+	tieredFront.now = clock.Now
+	tieredBack, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+	tieredBack.now = clock.Now
+
+	return map[string]Store{
+		"memory": memory,
+		"file":   file,
+		"tiered": NewTieredStore(tieredFront, tieredBack),
+	}
+}
+
+func TestStoreClaim(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	clock := testutil.NewClock(time.Now())
+
+	for name, store := range newTestStores(t, clock) {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			key := EventKey("1")
+
+			claimed, err := store.Claim(ctx, key, time.Minute)
+			r.NoError(err)
+			r.True(claimed)
+
+			claimed, err = store.Claim(ctx, key, time.Minute)
+			r.NoError(err)
+			r.False(claimed, "key must not be claimed twice")
+
+			r.NoError(store.Release(ctx, key))
+
+			claimed, err = store.Claim(ctx, key, time.Minute)
+			r.NoError(err)
+			r.True(claimed, "released key must be claimable")
+
+			claimed, err = store.Claim(ctx, InstanceKey(1, "abc"), time.Minute)
+			r.NoError(err)
+			r.True(claimed, "other keys must be claimable")
+		})
+	}
+}
+
+func TestStoreClaimExpired(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	clock := testutil.NewClock(time.Now())
+	stores := newTestStores(t, clock)
+
+	for _, store := range stores {
+		claimed, err := store.Claim(ctx, EventKey("1"), time.Minute)
+		require.NoError(t, err)
+		require.True(t, claimed)
+	}
+
+	clock.Add(2 * time.Minute)
+
+	for name, store := range stores {
+		claimed, err := store.Claim(ctx, EventKey("1"), time.Minute)
+		require.NoError(t, err, name)
+		require.True(t, claimed, "%s: expired key must be claimable", name)
+	}
+}
+
+func TestMemoryStoreEvictsLeastRecentlyClaimed(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+	ctx := context.Background()
+
+	store := NewMemoryStore(2)
+
+	for _, key := range []string{"a", "b", "c"} {
+		claimed, err := store.Claim(ctx, key, time.Hour)
+		r.NoError(err)
+		r.True(claimed)
+	}
+
+	claimed, err := store.Claim(ctx, "a", time.Hour)
+	r.NoError(err)
+	r.True(claimed, "evicted key must be claimable")
+
+	claimed, err = store.Claim(ctx, "c", time.Hour)
+	r.NoError(err)
+	r.False(claimed)
+}
+
+func TestFileStorePersistsClaims(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	store, err := NewFileStore(dir)
+	r.NoError(err)
+
+	claimed, err := store.Claim(ctx, EventKey("1"), time.Hour)
+	r.NoError(err)
+	r.True(claimed)
+
+	reopened, err := NewFileStore(dir)
+	r.NoError(err)
+
+	claimed, err = reopened.Claim(ctx, EventKey("1"), time.Hour)
+	r.NoError(err)
+	r.False(claimed, "claim must survive reopening the store")
+}
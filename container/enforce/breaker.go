@@ -0,0 +1,262 @@
+package enforce
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrBreakerTripped is returned while the circuit breaker of a cluster is tripped.
+	ErrBreakerTripped = errors.New("enforcement circuit breaker tripped")
+	// ErrRateLimited is returned when the disruptive actions of a cluster reached the limit of the window.
+	ErrRateLimited = errors.New("enforcement rate limit reached")
+)
+
+// BreakerLimits configures the Breaker. Zero values disable the respective limit.
+type BreakerLimits struct {
+	// MaxActions is the number of disruptive actions allowed per cluster within the Window.
+	MaxActions int
+	// Window over which actions and verdicts are counted.
+	Window time.Duration
+	// MaxInvalidRatio of invalid to validated instances of a cluster within the Window, above which the breaker trips.
+	MaxInvalidRatio float64
+	// MinVerdicts within the Window before the invalid ratio is considered.
+	MinVerdicts int
+}
+
+// Trip records why the breaker of a cluster tripped.
+type Trip struct {
+	ClusterID string    `json:"clusterId"`
+	At        time.Time `json:"at"`
+	Reason    string    `json:"reason"`
+}
+
+type verdict struct {
+	at      time.Time
+	invalid bool
+}
+
+// Breaker limits disruptive actions per cluster. It trips when the ratio of invalid instances of a cluster passes
+// a threshold, as when a bad whitelist makes every new node invalid, and stays tripped until it is reset.
+// Trips and actions are persisted in the state, when set, to survive restarts and to be shared by the instances of the
+// service, so the limit of actions applies to the service as a whole. Verdicts are counted per instance.
+type Breaker struct {
+	limits BreakerLimits
+	state  BreakerState
+
+	mu       sync.Mutex
+	verdicts map[string][]verdict
+	// seen are the clusters whose trip was loaded, to notice when it is reset by another instance.
+	seen map[string]struct{}
+
+	now func() time.Time
+}
+
+// NewBreaker creates a Breaker persisting trips and actions in the state. They are only kept in memory when it is nil.
+func NewBreaker(limits BreakerLimits, state BreakerState) *Breaker {
+	if state == nil {
+		state = &memoryBreakerState{record: newBreakerRecord()}
+	}
+
+	return &Breaker{
+		limits:   limits,
+		state:    state,
+		verdicts: map[string][]verdict{},
+		seen:     map[string]struct{}{},
+		now:      time.Now,
+	}
+}
+
+// RecordVerdict counts a validated instance of the cluster and returns the Trip when it tripped the breaker.
+func (b *Breaker) RecordVerdict(ctx context.Context, clusterID string, invalid bool) (*Trip, error) {
+	if b.limits.MaxInvalidRatio <= 0 {
+		return nil, nil
+	}
+
+	trip := b.countVerdict(clusterID, invalid)
+	if trip == nil {
+		return nil, nil
+	}
+
+	tripped := false
+	record, err := b.state.Update(ctx, func(record *BreakerRecord) bool {
+		tripped = false
+		// Not tripped again on the verdicts counted before another instance reset the breaker.
+		if _, found := record.Trips[clusterID]; found || b.wasReset(record.Trips, clusterID) {
+			return false
+		}
+		record.Trips[clusterID] = trip
+		tripped = true
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to persist breaker trip: %w", err)
+	}
+	b.observe(record.Trips)
+
+	if !tripped {
+		return nil, nil
+	}
+	return trip, nil
+}
+
+// countVerdict counts the verdict, and returns the Trip to persist when the invalid ratio is above the limit.
+func (b *Breaker) countVerdict(clusterID string, invalid bool) *Trip {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	verdicts := inWindow(b.verdicts[clusterID], b.limits.Window, now, func(v verdict) time.Time { return v.at })
+	verdicts = append(verdicts, verdict{at: now, invalid: invalid})
+	b.verdicts[clusterID] = verdicts
+
+	if len(verdicts) < max(b.limits.MinVerdicts, 1) {
+		return nil
+	}
+
+	invalidCount := 0
+	for _, v := range verdicts {
+		if v.invalid {
+			invalidCount++
+		}
+	}
+
+	ratio := float64(invalidCount) / float64(len(verdicts))
+	if ratio <= b.limits.MaxInvalidRatio {
+		return nil
+	}
+
+	return &Trip{
+		ClusterID: clusterID,
+		At:        now,
+		Reason:    fmt.Sprintf("%d of %d instances invalid within %s", invalidCount, len(verdicts), b.limits.Window),
+	}
+}
+
+// Allow reports whether a disruptive action can be enforced on an instance of the cluster, and counts it when it can.
+// Actions are not allowed when the state cannot be read or updated.
+func (b *Breaker) Allow(ctx context.Context, clusterID string) error {
+	if b.limits.MaxActions <= 0 {
+		record, err := b.state.Load(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to load breaker state: %w", err)
+		}
+		b.observe(record.Trips)
+		return tripError(record, clusterID)
+	}
+
+	now := b.now()
+	var denied error
+	record, err := b.state.Update(ctx, func(record *BreakerRecord) bool {
+		denied = tripError(record, clusterID)
+		if denied != nil {
+			return false
+		}
+
+		for id, actions := range record.Actions {
+			record.Actions[id] = inWindow(actions, b.limits.Window, now, func(at time.Time) time.Time { return at })
+			if len(record.Actions[id]) == 0 {
+				delete(record.Actions, id)
+			}
+		}
+
+		if actions := record.Actions[clusterID]; len(actions) >= b.limits.MaxActions {
+			denied = fmt.Errorf("%w: %d actions within %s", ErrRateLimited, len(actions), b.limits.Window)
+			return false
+		}
+		record.Actions[clusterID] = append(record.Actions[clusterID], now)
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("failed to count breaker action: %w", err)
+	}
+	b.observe(record.Trips)
+
+	return denied
+}
+
+// tripError returns ErrBreakerTripped when the cluster is tripped in the record.
+func tripError(record *BreakerRecord, clusterID string) error {
+	if trip, found := record.Trips[clusterID]; found {
+		return fmt.Errorf("%w at %s: %s", ErrBreakerTripped, trip.At.Format(time.RFC3339), trip.Reason)
+	}
+	return nil
+}
+
+// Reset resets the breaker of the cluster, or of all clusters when clusterID is empty, and returns the reset trips.
+// The verdicts counted so far are discarded, so the breaker does not trip again on them.
+func (b *Breaker) Reset(ctx context.Context, clusterID string) ([]Trip, error) {
+	var reset []Trip
+	record, err := b.state.Update(ctx, func(record *BreakerRecord) bool {
+		reset = nil
+		for id, trip := range record.Trips {
+			if clusterID == "" || id == clusterID {
+				reset = append(reset, *trip)
+				delete(record.Trips, id)
+			}
+		}
+		return len(reset) > 0
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to persist breaker reset: %w", err)
+	}
+	b.observe(record.Trips)
+
+	return reset, nil
+}
+
+// Trips returns the tripped clusters, ordered by cluster ID.
+func (b *Breaker) Trips(ctx context.Context) ([]Trip, error) {
+	record, err := b.state.Load(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load breaker state: %w", err)
+	}
+	b.observe(record.Trips)
+
+	result := make([]Trip, 0, len(record.Trips))
+	for _, id := range slices.Sorted(maps.Keys(record.Trips)) {
+		result = append(result, *record.Trips[id])
+	}
+	return result, nil
+}
+
+// observe records the tripped clusters of a committed record, and discards the verdicts of those whose trip was reset
+// since it was last seen, possibly by another instance.
+func (b *Breaker) observe(trips map[string]*Trip) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for id := range b.seen {
+		if _, tripped := trips[id]; !tripped {
+			delete(b.seen, id)
+			delete(b.verdicts, id)
+		}
+	}
+	for id := range trips {
+		b.seen[id] = struct{}{}
+	}
+}
+
+// wasReset reports whether the trip of the cluster was seen but is not in trips anymore, without recording it.
+func (b *Breaker) wasReset(trips map[string]*Trip, clusterID string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	_, seen := b.seen[clusterID]
+	_, tripped := trips[clusterID]
+	return seen && !tripped
+}
+
+// inWindow returns the items, timed by at, which have not left the window ending now. Items never leave a zero window.
+func inWindow[T any](items []T, window time.Duration, now time.Time, at func(T) time.Time) []T {
+	if window <= 0 {
+		return items
+	}
+	since := now.Add(-window)
+	return slices.DeleteFunc(items, func(item T) bool { return !at(item).After(since) })
+}
@@ -0,0 +1,109 @@
+package enforce
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"cloud.google.com/go/storage"
+	"github.com/castai/gcp-node-validator/container/gcperr"
+)
+
+const (
+	breakerObject = "breaker/state.json"
+	// breakerUpdateAttempts bounds the retries of updates racing with other instances.
+	breakerUpdateAttempts = 5
+)
+
+// BucketBreakerState is a BreakerState keeping the record in a JSON object of a GCS bucket, shared by the instances
+// of the service. Updates are conditional on the generation of the object read, and are retried when another
+// instance updated it in between.
+type BucketBreakerState struct {
+	client *storage.Client
+	bucket string
+}
+
+func NewBucketBreakerState(client *storage.Client, bucket string) *BucketBreakerState {
+	return &BucketBreakerState{
+		client: client,
+		bucket: bucket,
+	}
+}
+
+func (s *BucketBreakerState) Load(ctx context.Context) (*BreakerRecord, error) {
+	record, _, err := s.read(ctx)
+	return record, err
+}
+
+func (s *BucketBreakerState) Update(ctx context.Context, update func(record *BreakerRecord) bool) (*BreakerRecord, error) {
+	for range breakerUpdateAttempts {
+		record, generation, err := s.read(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if !update(record) {
+			return record, nil
+		}
+
+		err = s.write(ctx, record, generation)
+		if gcperr.IsPreconditionFailed(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return record, nil
+	}
+
+	return nil, fmt.Errorf("failed to write breaker state: updated concurrently %d times", breakerUpdateAttempts)
+}
+
+// read returns the record and the generation of the object, which is 0 when it does not exist.
+func (s *BucketBreakerState) read(ctx context.Context) (*BreakerRecord, int64, error) {
+	r, err := s.client.Bucket(s.bucket).Object(breakerObject).NewReader(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return newBreakerRecord(), 0, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read breaker state: %w", err)
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read breaker state: %w", err)
+	}
+
+	record, err := parseBreakerRecord(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	return record, r.Attrs.Generation, nil
+}
+
+// write stores the record, unless the object changed since the generation was read.
+func (s *BucketBreakerState) write(ctx context.Context, record *BreakerRecord, generation int64) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal breaker state: %w", err)
+	}
+
+	conds := storage.Conditions{GenerationMatch: generation}
+	if generation == 0 {
+		conds = storage.Conditions{DoesNotExist: true}
+	}
+
+	w := s.client.Bucket(s.bucket).Object(breakerObject).If(conds).NewWriter(ctx)
+	w.ContentType = "application/json"
+	if _, err := w.Write(data); err != nil {
+		_ = w.Close()
+		return fmt.Errorf("failed to write breaker state: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to write breaker state: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,150 @@
+package enforce
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// BreakerRecord is the state of the Breaker shared by the instances of the service.
+type BreakerRecord struct {
+	// Trips by cluster ID.
+	Trips map[string]*Trip `json:"trips"`
+	// Actions are the times of the disruptive actions enforced within the window, by cluster ID.
+	Actions map[string][]time.Time `json:"actions"`
+}
+
+func newBreakerRecord() *BreakerRecord {
+	return &BreakerRecord{
+		Trips:   map[string]*Trip{},
+		Actions: map[string][]time.Time{},
+	}
+}
+
+// parseBreakerRecord parses a record stored as JSON.
+func parseBreakerRecord(data []byte) (*BreakerRecord, error) {
+	record := newBreakerRecord()
+	if err := json.Unmarshal(data, record); err != nil {
+		return nil, fmt.Errorf("failed to parse breaker state: %w", err)
+	}
+	if record.Trips == nil {
+		record.Trips = map[string]*Trip{}
+	}
+	if record.Actions == nil {
+		record.Actions = map[string][]time.Time{}
+	}
+	return record, nil
+}
+
+// clone returns a copy of the record which can be updated without changing it. Trips are replaced, never changed.
+func (r *BreakerRecord) clone() *BreakerRecord {
+	actions := make(map[string][]time.Time, len(r.Actions))
+	for id, times := range r.Actions {
+		actions[id] = slices.Clone(times)
+	}
+	return &BreakerRecord{
+		Trips:   maps.Clone(r.Trips),
+		Actions: actions,
+	}
+}
+
+// BreakerState persists the record of the Breaker, so that it survives restarts and is shared by the instances of
+// the service.
+type BreakerState interface {
+	// Load returns the persisted record.
+	Load(ctx context.Context) (*BreakerRecord, error)
+	// Update applies update to the persisted record, and stores it when update reports a change. Updates of other
+	// instances are not lost: update is applied again to the record they stored, so it must not have side effects
+	// beyond the record. It returns the updated record.
+	Update(ctx context.Context, update func(record *BreakerRecord) bool) (*BreakerRecord, error)
+}
+
+// memoryBreakerState keeps the record in memory only.
+type memoryBreakerState struct {
+	mu     sync.Mutex
+	record *BreakerRecord
+}
+
+func (s *memoryBreakerState) Load(_ context.Context) (*BreakerRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.record.clone(), nil
+}
+
+func (s *memoryBreakerState) Update(_ context.Context, update func(record *BreakerRecord) bool) (*BreakerRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record := s.record.clone()
+	if update(record) {
+		s.record = record
+	}
+	return record.clone(), nil
+}
+
+// FileBreakerState is a BreakerState keeping the record in a JSON file, for deployments with a persistent disk.
+type FileBreakerState struct {
+	path string
+
+	mu sync.Mutex
+}
+
+func NewFileBreakerState(path string) *FileBreakerState {
+	return &FileBreakerState{
+		path: path,
+	}
+}
+
+func (s *FileBreakerState) Load(_ context.Context) (*BreakerRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.read()
+}
+
+func (s *FileBreakerState) Update(_ context.Context, update func(record *BreakerRecord) bool) (*BreakerRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	if !update(record) {
+		return record, nil
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal breaker state: %w", err)
+	}
+
+	// Written to a temporary file first, so a crash does not leave a partial state behind.
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return nil, fmt.Errorf("failed to write breaker state: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return nil, fmt.Errorf("failed to write breaker state: %w", err)
+	}
+
+	return record, nil
+}
+
+func (s *FileBreakerState) read() (*BreakerRecord, error) {
+	data, err := os.ReadFile(s.path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return newBreakerRecord(), nil
+	case err != nil:
+		return nil, fmt.Errorf("failed to read breaker state: %w", err)
+	}
+
+	return parseBreakerRecord(data)
+}
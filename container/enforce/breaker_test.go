@@ -0,0 +1,189 @@
+package enforce
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/castai/gcp-node-validator/container/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestBreaker(limits BreakerLimits, state BreakerState, clock *testutil.Clock) *Breaker {
+	b := NewBreaker(limits, state)
+	b.now = clock.Now
+	return b
+}
+
+func TestBreakerRateLimit(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+	ctx := context.Background()
+
+	clock := testutil.NewClock(time.Now())
+	b := newTestBreaker(BreakerLimits{MaxActions: 2, Window: time.Minute}, nil, clock)
+
+	r.NoError(b.Allow(ctx, "a"))
+	r.NoError(b.Allow(ctx, "a"))
+	r.ErrorIs(b.Allow(ctx, "a"), ErrRateLimited)
+	r.NoError(b.Allow(ctx, "b"), "clusters are limited separately")
+
+	clock.Add(time.Minute)
+	r.NoError(b.Allow(ctx, "a"), "actions leave the window")
+}
+
+func TestBreakerTrip(t *testing.T) {
+	t.Parallel()
+
+	limits := BreakerLimits{Window: time.Minute, MaxInvalidRatio: 0.5, MinVerdicts: 4}
+
+	tests := []struct {
+		name     string
+		verdicts []bool
+		wantTrip bool
+	}{
+		{
+			name:     "trips above invalid ratio",
+			verdicts: []bool{false, true, true, true},
+			wantTrip: true,
+		},
+		{
+			name:     "does not trip at invalid ratio",
+			verdicts: []bool{false, false, true, true},
+		},
+		{
+			name:     "does not trip before min verdicts",
+			verdicts: []bool{true, true, true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			r := require.New(t)
+			ctx := context.Background()
+
+			b := newTestBreaker(limits, nil, testutil.NewClock(time.Now()))
+
+			var trip *Trip
+			for _, invalid := range tt.verdicts {
+				var err error
+				trip, err = b.RecordVerdict(ctx, "a", invalid)
+				r.NoError(err)
+			}
+
+			if !tt.wantTrip {
+				r.Nil(trip)
+				r.NoError(b.Allow(ctx, "a"))
+				return
+			}
+
+			r.NotNil(trip)
+			r.Equal("a", trip.ClusterID)
+			r.Equal("3 of 4 instances invalid within 1m0s", trip.Reason)
+			r.ErrorIs(b.Allow(ctx, "a"), ErrBreakerTripped)
+			r.NoError(b.Allow(ctx, "b"), "other clusters are not affected")
+		})
+	}
+}
+
+func TestBreakerStaysTrippedUntilReset(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+	ctx := context.Background()
+
+	// Without monotonic clock reading and location, which are not persisted.
+	clock := testutil.NewClock(time.Now().UTC().Truncate(time.Second))
+	statePath := filepath.Join(t.TempDir(), "breaker.json")
+	limits := BreakerLimits{Window: time.Minute, MaxInvalidRatio: 0.5, MinVerdicts: 1}
+	b := newTestBreaker(limits, NewFileBreakerState(statePath), clock)
+
+	trip, err := b.RecordVerdict(ctx, "a", true)
+	r.NoError(err)
+	r.NotNil(trip)
+
+	clock.Add(time.Hour)
+	r.ErrorIs(b.Allow(ctx, "a"), ErrBreakerTripped, "trips do not expire")
+
+	restarted := newTestBreaker(limits, NewFileBreakerState(statePath), clock)
+	r.Equal([]Trip{*trip}, trips(t, restarted), "trips survive restarts")
+	r.ErrorIs(restarted.Allow(ctx, "a"), ErrBreakerTripped)
+
+	reset, err := restarted.Reset(ctx, "")
+	r.NoError(err)
+	r.Len(reset, 1)
+	r.Empty(trips(t, restarted))
+	r.NoError(restarted.Allow(ctx, "a"))
+
+	restarted = newTestBreaker(limits, NewFileBreakerState(statePath), clock)
+	r.Empty(trips(t, restarted), "resets survive restarts")
+}
+
+func TestBreakerSharesTrips(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+	ctx := context.Background()
+
+	clock := testutil.NewClock(time.Now())
+	state := NewFileBreakerState(filepath.Join(t.TempDir(), "breaker.json"))
+	limits := BreakerLimits{Window: time.Minute, MaxInvalidRatio: 0.5, MinVerdicts: 2}
+	a := newTestBreaker(limits, state, clock)
+	b := newTestBreaker(limits, state, clock)
+
+	for _, breaker := range []*Breaker{a, b} {
+		_, err := breaker.RecordVerdict(ctx, "c1", true)
+		r.NoError(err)
+	}
+	trip, err := a.RecordVerdict(ctx, "c1", true)
+	r.NoError(err)
+	r.NotNil(trip)
+	r.ErrorIs(b.Allow(ctx, "c1"), ErrBreakerTripped, "trips are shared by the instances")
+
+	trip, err = b.RecordVerdict(ctx, "c1", true)
+	r.NoError(err)
+	r.Nil(trip, "the cluster is only tripped once")
+
+	reset, err := a.Reset(ctx, "c1")
+	r.NoError(err)
+	r.Len(reset, 1)
+
+	trip, err = b.RecordVerdict(ctx, "c1", true)
+	r.NoError(err)
+	r.Nil(trip, "verdicts counted before the reset of another instance are discarded")
+	r.NoError(b.Allow(ctx, "c1"))
+}
+
+func TestBreakerSharesActions(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+	ctx := context.Background()
+
+	clock := testutil.NewClock(time.Now())
+	state := NewFileBreakerState(filepath.Join(t.TempDir(), "breaker.json"))
+	limits := BreakerLimits{MaxActions: 3, Window: time.Minute}
+	a := newTestBreaker(limits, state, clock)
+	b := newTestBreaker(limits, state, clock)
+
+	r.NoError(a.Allow(ctx, "c1"))
+	r.NoError(b.Allow(ctx, "c1"))
+	r.NoError(a.Allow(ctx, "c1"))
+	r.ErrorIs(b.Allow(ctx, "c1"), ErrRateLimited, "actions are counted across the instances")
+	r.NoError(b.Allow(ctx, "c2"))
+
+	clock.Add(time.Minute)
+	r.NoError(b.Allow(ctx, "c1"), "actions leave the window")
+
+	record, err := state.Load(ctx)
+	r.NoError(err)
+	r.Len(record.Actions["c1"], 1)
+	r.NotContains(record.Actions, "c2", "clusters without actions within the window are dropped")
+}
+
+func trips(t *testing.T, b *Breaker) []Trip {
+	t.Helper()
+
+	trips, err := b.Trips(context.Background())
+	require.NoError(t, err)
+	return trips
+}
@@ -0,0 +1,182 @@
+package enforce_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/option"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// fakeCompute serves the subset of the Compute Engine REST API used by the enforcement actions.
+// All operations complete immediately.
+type fakeCompute struct {
+	t *testing.T
+
+	srv *httptest.Server
+
+	mu        sync.Mutex
+	instances map[string]*computepb.Instance
+	// abandoned instances, by instance group manager.
+	abandoned map[string][]string
+	calls     []string
+}
+
+func newFakeCompute(t *testing.T, instances ...*computepb.Instance) (*fakeCompute, *compute.InstancesClient) {
+	t.Helper()
+
+	f := &fakeCompute{
+		t:         t,
+		instances: map[string]*computepb.Instance{},
+		abandoned: map[string][]string{},
+	}
+	for _, i := range instances {
+		f.instances[i.GetName()] = i
+	}
+
+	f.srv = httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	t.Cleanup(f.srv.Close)
+
+	client, err := compute.NewInstancesRESTClient(context.Background(), option.WithEndpoint(f.srv.URL), option.WithoutAuthentication())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+
+	return f, client
+}
+
+func (f *fakeCompute) instanceGroupManagersClient() *compute.InstanceGroupManagersClient {
+	client, err := compute.NewInstanceGroupManagersRESTClient(context.Background(), option.WithEndpoint(f.srv.URL), option.WithoutAuthentication())
+	require.NoError(f.t, err)
+	f.t.Cleanup(func() { _ = client.Close() })
+
+	return client
+}
+
+func (f *fakeCompute) instance(name string) *computepb.Instance {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return proto.Clone(f.instances[name]).(*computepb.Instance)
+}
+
+func (f *fakeCompute) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// /compute/v1/projects/{project}/zones/{zone}/{collection}/{name}[/{method}]
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/compute/v1/"), "/")
+	if len(parts) < 6 {
+		http.NotFound(w, r)
+		return
+	}
+	collection, name := parts[4], parts[5]
+	method := ""
+	if len(parts) > 6 {
+		method = parts[6]
+	}
+
+	if collection == "operations" {
+		f.writeOperation(w)
+		return
+	}
+
+	f.calls = append(f.calls, strings.TrimSpace(r.Method+" "+method))
+
+	if collection == "instanceGroupManagers" {
+		f.serveInstanceGroupManager(w, r, name, method)
+		return
+	}
+
+	instance, found := f.instances[name]
+	if !found {
+		http.Error(w, `{"error": {"code": 404, "message": "not found"}}`, http.StatusNotFound)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	require.NoError(f.t, err)
+
+	switch {
+	case r.Method == http.MethodGet && method == "":
+		data, err := protojson.Marshal(instance)
+		require.NoError(f.t, err)
+		_, _ = w.Write(data)
+		return
+	case r.Method == http.MethodDelete && method == "":
+		delete(f.instances, name)
+	case method == "setMetadata":
+		metadata := &computepb.Metadata{}
+		require.NoError(f.t, protojson.Unmarshal(body, metadata))
+		metadata.Fingerprint = lo.ToPtr(instance.GetMetadata().GetFingerprint() + "+")
+		instance.Metadata = metadata
+	case method == "setTags":
+		tags := &computepb.Tags{}
+		require.NoError(f.t, protojson.Unmarshal(body, tags))
+		tags.Fingerprint = lo.ToPtr(instance.GetTags().GetFingerprint() + "+")
+		instance.Tags = tags
+	case method == "setLabels":
+		req := &computepb.InstancesSetLabelsRequest{}
+		require.NoError(f.t, protojson.Unmarshal(body, req))
+		instance.Labels = req.GetLabels()
+		instance.LabelFingerprint = lo.ToPtr(instance.GetLabelFingerprint() + "+")
+	case method == "stop":
+		instance.Status = lo.ToPtr("TERMINATED")
+	case method == "suspend":
+		instance.Status = lo.ToPtr("SUSPENDED")
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	f.writeOperation(w)
+}
+
+func (f *fakeCompute) serveInstanceGroupManager(w http.ResponseWriter, r *http.Request, name, method string) {
+	body, err := io.ReadAll(r.Body)
+	require.NoError(f.t, err)
+
+	var instances []string
+	switch method {
+	case "deleteInstances":
+		req := &computepb.InstanceGroupManagersDeleteInstancesRequest{}
+		require.NoError(f.t, protojson.Unmarshal(body, req))
+		instances = req.GetInstances()
+	case "abandonInstances":
+		req := &computepb.InstanceGroupManagersAbandonInstancesRequest{}
+		require.NoError(f.t, protojson.Unmarshal(body, req))
+		instances = req.GetInstances()
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	for _, selfLink := range instances {
+		instanceName := selfLink[strings.LastIndex(selfLink, "/")+1:]
+		if method == "deleteInstances" {
+			delete(f.instances, instanceName)
+		} else {
+			f.abandoned[name] = append(f.abandoned[name], instanceName)
+		}
+	}
+
+	f.writeOperation(w)
+}
+
+func (f *fakeCompute) writeOperation(w http.ResponseWriter) {
+	data, err := protojson.Marshal(&computepb.Operation{
+		Name:   lo.ToPtr("operation-1"),
+		Status: computepb.Operation_DONE.Enum(),
+	})
+	require.NoError(f.t, err)
+	_, _ = w.Write(data)
+}
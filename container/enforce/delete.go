@@ -0,0 +1,174 @@
+package enforce
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/castai/gcp-node-validator/container/gcperr"
+	"github.com/castai/gcp-node-validator/container/validate"
+	"github.com/samber/lo"
+)
+
+// GroupMethod is how instances managed by an instance group are deleted.
+type GroupMethod string
+
+const (
+	// GroupMethodDelete deletes the instance with instanceGroupManagers.deleteInstances, reducing the target size
+	// of the group.
+	GroupMethodDelete GroupMethod = "delete"
+	// GroupMethodAbandon removes the instance from the group with instanceGroupManagers.abandonInstances, reducing
+	// the target size of the group, and then deletes the instance.
+	GroupMethodAbandon GroupMethod = "abandon"
+)
+
+// Deletion methods recorded in the Outcome.
+const (
+	methodInstancesDelete      = "instances.delete"
+	methodGroupDeleteInstances = "instanceGroupManagers.deleteInstances"
+	methodGroupAbandon         = "instanceGroupManagers.abandonInstances"
+)
+
+// GroupManagerResolver resolves the instance group manager of an instance.
+type GroupManagerResolver interface {
+	// GetInstanceGroupManager returns validate.ErrNotManaged for instances not managed by a group.
+	GetInstanceGroupManager(ctx context.Context, instance *computepb.Instance) (*computepb.InstanceGroupManager, error)
+}
+
+// Deleter deletes the instance.
+type Deleter struct {
+	computeClient *compute.InstancesClient
+
+	groupManagersClient *compute.InstanceGroupManagersClient
+	groups              GroupManagerResolver
+	groupMethod         GroupMethod
+}
+
+type DeleterOption func(*Deleter)
+
+// WithInstanceGroups deletes instances managed by an instance group through the group, so that it does not
+// recreate them. Other instances are deleted directly.
+func WithInstanceGroups(client *compute.InstanceGroupManagersClient, groups GroupManagerResolver, method GroupMethod) DeleterOption {
+	return func(d *Deleter) {
+		d.groupManagersClient = client
+		d.groups = groups
+		d.groupMethod = method
+	}
+}
+
+func NewDeleter(computeClient *compute.InstancesClient, opts ...DeleterOption) *Deleter {
+	d := &Deleter{
+		computeClient: computeClient,
+	}
+
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	return d
+}
+
+func ParseGroupMethod(s string) (GroupMethod, error) {
+	switch m := GroupMethod(s); m {
+	case GroupMethodDelete, GroupMethodAbandon:
+		return m, nil
+	default:
+		return "", fmt.Errorf("unknown instance group method %q, expected one of %v", s, []GroupMethod{GroupMethodDelete, GroupMethodAbandon})
+	}
+}
+
+func (d *Deleter) Name() string {
+	return string(ModeDelete)
+}
+
+func (d *Deleter) Enforce(ctx context.Context, target *Target) (*Outcome, error) {
+	outcome := &Outcome{Action: d.Name()}
+
+	if d.groups != nil {
+		img, err := d.groups.GetInstanceGroupManager(ctx, target.Instance)
+		switch {
+		case err == nil:
+			return d.enforceInGroup(ctx, target, img, outcome)
+		case !errors.Is(err, validate.ErrNotManaged):
+			// Deleting a managed instance directly would make its group recreate it.
+			return outcome, fmt.Errorf("failed to get instance group manager: %w", err)
+		}
+	}
+
+	return outcome, d.deleteInstance(ctx, target, outcome)
+}
+
+func (d *Deleter) enforceInGroup(ctx context.Context, target *Target, img *computepb.InstanceGroupManager, outcome *Outcome) (*Outcome, error) {
+	outcome.InstanceGroupManager = img.GetName()
+	instances := []string{target.Instance.GetSelfLink()}
+
+	var (
+		op  *compute.Operation
+		err error
+	)
+	switch d.groupMethod {
+	case GroupMethodAbandon:
+		outcome.Method = methodGroupAbandon
+		op, err = d.groupManagersClient.AbandonInstances(ctx, &computepb.AbandonInstancesInstanceGroupManagerRequest{
+			Project:              target.Project,
+			Zone:                 target.Zone,
+			InstanceGroupManager: img.GetName(),
+			InstanceGroupManagersAbandonInstancesRequestResource: &computepb.InstanceGroupManagersAbandonInstancesRequest{
+				Instances: instances,
+			},
+		})
+	default:
+		outcome.Method = methodGroupDeleteInstances
+		op, err = d.groupManagersClient.DeleteInstances(ctx, &computepb.DeleteInstancesInstanceGroupManagerRequest{
+			Project:              target.Project,
+			Zone:                 target.Zone,
+			InstanceGroupManager: img.GetName(),
+			InstanceGroupManagersDeleteInstancesRequestResource: &computepb.InstanceGroupManagersDeleteInstancesRequest{
+				Instances: instances,
+				// Instances already deleted or abandoned are skipped instead of failing the request.
+				SkipInstancesOnValidationError: lo.ToPtr(true),
+			},
+		})
+	}
+	if err != nil {
+		if gcperr.IsNotFound(err) {
+			return outcome, nil
+		}
+		return outcome, err
+	}
+
+	name, err := WaitOperation(ctx, op)
+	outcome.Operations = append(outcome.Operations, name)
+	if err != nil || d.groupMethod != GroupMethodAbandon {
+		return outcome, err
+	}
+
+	// Abandoned instances keep running outside of the group.
+	return outcome, d.deleteInstance(ctx, target, outcome)
+}
+
+func (d *Deleter) deleteInstance(ctx context.Context, target *Target, outcome *Outcome) error {
+	if outcome.Method == "" {
+		outcome.Method = methodInstancesDelete
+	}
+
+	op, err := d.computeClient.Delete(ctx, &computepb.DeleteInstanceRequest{
+		Project:  target.Project,
+		Zone:     target.Zone,
+		Instance: target.Instance.GetName(),
+	})
+	if err != nil {
+		// Already deleted while handling a previous delivery of the same event.
+		if gcperr.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	name, err := WaitOperation(ctx, op)
+	outcome.Operations = append(outcome.Operations, name)
+
+	return err
+}
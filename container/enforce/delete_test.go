@@ -0,0 +1,151 @@
+package enforce_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/castai/gcp-node-validator/container/enforce"
+	"github.com/castai/gcp-node-validator/container/internal/testutil"
+	"github.com/castai/gcp-node-validator/container/validate"
+	"github.com/googleapis/gax-go/v2/apierror"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type fakeGroupResolver struct {
+	img *computepb.InstanceGroupManager
+	err error
+}
+
+func (f *fakeGroupResolver) GetInstanceGroupManager(context.Context, *computepb.Instance) (*computepb.InstanceGroupManager, error) {
+	return f.img, f.err
+}
+
+func TestDeleterEnforceInstanceGroups(t *testing.T) {
+	t.Parallel()
+
+	unavailable, _ := apierror.FromError(status.Error(codes.Unavailable, "unavailable"))
+	group := &computepb.InstanceGroupManager{Name: lo.ToPtr("gke-cluster-pool-1234abcd-grp")}
+	// Without clients, reading a node pool or group would panic.
+	provider, err := validate.NewInstanceTemplateWhitelistProvider(nil, nil, nil)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		resolver      enforce.GroupManagerResolver
+		method        enforce.GroupMethod
+		wantErr       bool
+		wantOutcome   *enforce.Outcome
+		wantCalls     []string
+		wantAbandoned []string
+		wantDeleted   bool
+	}{
+		{
+			name:     "managed instance is deleted through its group",
+			resolver: &fakeGroupResolver{img: group},
+			method:   enforce.GroupMethodDelete,
+			wantOutcome: &enforce.Outcome{
+				Action:               "delete",
+				Method:               "instanceGroupManagers.deleteInstances",
+				InstanceGroupManager: "gke-cluster-pool-1234abcd-grp",
+				Operations:           []string{"operation-1"},
+			},
+			wantCalls:   []string{"POST deleteInstances"},
+			wantDeleted: true,
+		},
+		{
+			name:     "managed instance is abandoned and deleted",
+			resolver: &fakeGroupResolver{img: group},
+			method:   enforce.GroupMethodAbandon,
+			wantOutcome: &enforce.Outcome{
+				Action:               "delete",
+				Method:               "instanceGroupManagers.abandonInstances",
+				InstanceGroupManager: "gke-cluster-pool-1234abcd-grp",
+				Operations:           []string{"operation-1", "operation-1"},
+			},
+			wantCalls:     []string{"POST abandonInstances", "DELETE"},
+			wantAbandoned: []string{"gke-cluster-pool-1234abcd-x1y2"},
+			wantDeleted:   true,
+		},
+		{
+			name:     "unmanaged instance is deleted directly",
+			resolver: &fakeGroupResolver{err: validate.ErrNotManaged},
+			method:   enforce.GroupMethodDelete,
+			wantOutcome: &enforce.Outcome{
+				Action:     "delete",
+				Method:     "instances.delete",
+				Operations: []string{"operation-1"},
+			},
+			wantCalls:   []string{"DELETE"},
+			wantDeleted: true,
+		},
+		{
+			name:     "CAST instance of a node pool is deleted directly without resolving its group",
+			resolver: provider,
+			method:   enforce.GroupMethodDelete,
+			wantOutcome: &enforce.Outcome{
+				Action:     "delete",
+				Method:     "instances.delete",
+				Operations: []string{"operation-1"},
+			},
+			wantCalls:   []string{"DELETE"},
+			wantDeleted: true,
+		},
+		{
+			name:        "resolution error is returned",
+			resolver:    &fakeGroupResolver{err: errors.New("failed to get node pool")},
+			method:      enforce.GroupMethodDelete,
+			wantErr:     true,
+			wantOutcome: &enforce.Outcome{Action: "delete"},
+		},
+		{
+			name:        "transient resolution error is returned",
+			resolver:    &fakeGroupResolver{err: unavailable},
+			method:      enforce.GroupMethodDelete,
+			wantErr:     true,
+			wantOutcome: &enforce.Outcome{Action: "delete"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			r := require.New(t)
+
+			instance := testutil.NewInstance()
+			instance.SelfLink = lo.ToPtr("https://www.googleapis.com/compute/v1/projects/p/zones/z/instances/" + instance.GetName())
+
+			fake, client := newFakeCompute(t, instance)
+			deleter := enforce.NewDeleter(client, enforce.WithInstanceGroups(fake.instanceGroupManagersClient(), tt.resolver, tt.method))
+
+			outcome, err := deleter.Enforce(context.Background(), &enforce.Target{Project: "p", Zone: "z", Instance: instance})
+			if tt.wantErr {
+				r.Error(err)
+			} else {
+				r.NoError(err)
+			}
+			r.Equal(tt.wantOutcome, outcome)
+			r.Equal(tt.wantCalls, fake.calls)
+			r.Equal(tt.wantAbandoned, fake.abandoned[group.GetName()])
+			if tt.wantDeleted {
+				r.Empty(fake.instances)
+			}
+		})
+	}
+}
+
+func TestParseGroupMethod(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+
+	method, err := enforce.ParseGroupMethod("abandon")
+	r.NoError(err)
+	r.Equal(enforce.GroupMethodAbandon, method)
+
+	_, err = enforce.ParseGroupMethod("recreate")
+	r.Error(err)
+}
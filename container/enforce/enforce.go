@@ -0,0 +1,51 @@
+package enforce
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	"cloud.google.com/go/compute/apiv1/computepb"
+)
+
+// Target is the instance an action is enforced on.
+type Target struct {
+	Project  string
+	Zone     string
+	Instance *computepb.Instance
+}
+
+// Outcome reports the enforced action and the long-running operations it completed.
+type Outcome struct {
+	Action string
+	// Method is the API method the action was enforced with, when the action has more than one.
+	Method string
+	// InstanceGroupManager the action was enforced through, if any.
+	InstanceGroupManager string
+	Operations           []string
+}
+
+// Action is enforced on instances which failed validation.
+type Action interface {
+	Name() string
+	Enforce(ctx context.Context, target *Target) (*Outcome, error)
+}
+
+// WaitOperation waits for the operation to complete and returns its name, and the errors it completed with. Waiting
+// alone does not report the errors of a completed operation.
+func WaitOperation(ctx context.Context, op *compute.Operation) (string, error) {
+	if err := op.Wait(ctx); err != nil {
+		return op.Name(), fmt.Errorf("failed to wait for operation %s: %w", op.Name(), err)
+	}
+
+	if opErrors := op.Proto().GetError().GetErrors(); len(opErrors) > 0 {
+		messages := make([]string, 0, len(opErrors))
+		for _, e := range opErrors {
+			messages = append(messages, fmt.Sprintf("%s: %s", e.GetCode(), e.GetMessage()))
+		}
+		return op.Name(), fmt.Errorf("operation %s failed: %s", op.Name(), strings.Join(messages, "; "))
+	}
+
+	return op.Name(), nil
+}
@@ -0,0 +1,132 @@
+package enforce
+
+import (
+	"context"
+	"maps"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	"cloud.google.com/go/compute/apiv1/computepb"
+)
+
+const (
+	// ValidationLabel is set on instances which failed validation by the label action.
+	ValidationLabel       = "cast-validation"
+	ValidationFailedValue = "failed"
+)
+
+// Stopper stops the instance. The instance and its disks are kept, but it stops running.
+type Stopper struct {
+	computeClient *compute.InstancesClient
+}
+
+func NewStopper(computeClient *compute.InstancesClient) *Stopper {
+	return &Stopper{
+		computeClient: computeClient,
+	}
+}
+
+func (s *Stopper) Name() string {
+	return string(ModeStop)
+}
+
+func (s *Stopper) Enforce(ctx context.Context, target *Target) (*Outcome, error) {
+	outcome := &Outcome{Action: s.Name()}
+
+	op, err := s.computeClient.Stop(ctx, &computepb.StopInstanceRequest{
+		Project:  target.Project,
+		Zone:     target.Zone,
+		Instance: target.Instance.GetName(),
+	})
+	if err != nil {
+		return outcome, err
+	}
+
+	name, err := WaitOperation(ctx, op)
+	outcome.Operations = append(outcome.Operations, name)
+
+	return outcome, err
+}
+
+// Suspender suspends the instance, preserving its memory for investigation.
+type Suspender struct {
+	computeClient *compute.InstancesClient
+}
+
+func NewSuspender(computeClient *compute.InstancesClient) *Suspender {
+	return &Suspender{
+		computeClient: computeClient,
+	}
+}
+
+func (s *Suspender) Name() string {
+	return string(ModeSuspend)
+}
+
+func (s *Suspender) Enforce(ctx context.Context, target *Target) (*Outcome, error) {
+	outcome := &Outcome{Action: s.Name()}
+
+	op, err := s.computeClient.Suspend(ctx, &computepb.SuspendInstanceRequest{
+		Project:  target.Project,
+		Zone:     target.Zone,
+		Instance: target.Instance.GetName(),
+	})
+	if err != nil {
+		return outcome, err
+	}
+
+	name, err := WaitOperation(ctx, op)
+	outcome.Operations = append(outcome.Operations, name)
+
+	return outcome, err
+}
+
+// Labeler marks the instance with the validation label, leaving it running.
+type Labeler struct {
+	computeClient *compute.InstancesClient
+}
+
+func NewLabeler(computeClient *compute.InstancesClient) *Labeler {
+	return &Labeler{
+		computeClient: computeClient,
+	}
+}
+
+func (l *Labeler) Name() string {
+	return string(ModeLabel)
+}
+
+func (l *Labeler) Enforce(ctx context.Context, target *Target) (*Outcome, error) {
+	outcome := &Outcome{Action: l.Name()}
+
+	if target.Instance.GetLabels()[ValidationLabel] == ValidationFailedValue {
+		return outcome, nil
+	}
+
+	labels := maps.Clone(target.Instance.GetLabels())
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[ValidationLabel] = ValidationFailedValue
+
+	name, err := setLabels(ctx, l.computeClient, target, labels)
+	outcome.Operations = append(outcome.Operations, name)
+
+	return outcome, err
+}
+
+func setLabels(ctx context.Context, computeClient *compute.InstancesClient, target *Target, labels map[string]string) (string, error) {
+	op, err := computeClient.SetLabels(ctx, &computepb.SetLabelsInstanceRequest{
+		Project:  target.Project,
+		Zone:     target.Zone,
+		Instance: target.Instance.GetName(),
+		InstancesSetLabelsRequestResource: &computepb.InstancesSetLabelsRequest{
+			LabelFingerprint: target.Instance.LabelFingerprint,
+			Labels:           labels,
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return WaitOperation(ctx, op)
+}
@@ -0,0 +1,78 @@
+package enforce_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/castai/gcp-node-validator/container/enforce"
+	"github.com/castai/gcp-node-validator/container/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstanceActions(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		action func(f *fakeCompute) enforce.Action
+		check  func(r *require.Assertions, f *fakeCompute)
+	}{
+		{
+			name: "delete",
+			check: func(r *require.Assertions, f *fakeCompute) {
+				r.Empty(f.instances)
+			},
+		},
+		{
+			name: "stop",
+			check: func(r *require.Assertions, f *fakeCompute) {
+				r.Equal("TERMINATED", f.instance("gke-cluster-pool-1234abcd-x1y2").GetStatus())
+			},
+		},
+		{
+			name: "suspend",
+			check: func(r *require.Assertions, f *fakeCompute) {
+				r.Equal("SUSPENDED", f.instance("gke-cluster-pool-1234abcd-x1y2").GetStatus())
+			},
+		},
+		{
+			name: "label",
+			check: func(r *require.Assertions, f *fakeCompute) {
+				labels := testutil.NewInstance().GetLabels()
+				labels["cast-validation"] = "failed"
+				r.Equal(labels, f.instance("gke-cluster-pool-1234abcd-x1y2").GetLabels())
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := require.New(t)
+
+			fake, client := newFakeCompute(t, testutil.NewInstance())
+			action := map[string]enforce.Action{
+				"delete":  enforce.NewDeleter(client),
+				"stop":    enforce.NewStopper(client),
+				"suspend": enforce.NewSuspender(client),
+				"label":   enforce.NewLabeler(client),
+			}[tt.name]
+
+			outcome, err := action.Enforce(context.Background(), &enforce.Target{Project: "p", Zone: "z", Instance: testutil.NewInstance()})
+			r.NoError(err)
+			r.Equal(tt.name, outcome.Action)
+			r.Equal([]string{"operation-1"}, outcome.Operations)
+			tt.check(r, fake)
+		})
+	}
+}
+
+func TestDeleterEnforceDeletedInstance(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+
+	_, client := newFakeCompute(t)
+
+	outcome, err := enforce.NewDeleter(client).Enforce(context.Background(), &enforce.Target{Project: "p", Zone: "z", Instance: testutil.NewInstance()})
+	r.NoError(err, "deleting a deleted instance must succeed")
+	r.Empty(outcome.Operations)
+}
@@ -0,0 +1,102 @@
+package enforce
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// Mode is the protection applied to invalid instances.
+type Mode string
+
+const (
+	// ModeLog only reports invalid instances.
+	ModeLog        Mode = "log"
+	ModeLabel      Mode = "label"
+	ModeStop       Mode = "stop"
+	ModeSuspend    Mode = "suspend"
+	ModeQuarantine Mode = "quarantine"
+	ModeDelete     Mode = "delete"
+)
+
+var modes = []Mode{ModeLog, ModeLabel, ModeStop, ModeSuspend, ModeQuarantine, ModeDelete}
+
+// Destructive reports whether the mode destroys the state of the instance, which has to be captured beforehand
+// for investigation.
+func (m Mode) Destructive() bool {
+	return m == ModeStop || m == ModeSuspend || m == ModeDelete
+}
+
+// Disruptive reports whether the mode takes the instance out of service, so its node has to be drained first.
+func (m Mode) Disruptive() bool {
+	return m.Destructive() || m == ModeQuarantine
+}
+
+func ParseMode(s string) (Mode, error) {
+	if !slices.Contains(modes, Mode(s)) {
+		return "", fmt.Errorf("unknown protection mode %q, expected one of %v", s, modes)
+	}
+
+	return Mode(s), nil
+}
+
+// Policy resolves the action enforced on invalid instances of a cluster.
+type Policy struct {
+	actions map[Mode]Action
+
+	mu           sync.RWMutex
+	defaultMode  Mode
+	clusterModes map[string]Mode
+}
+
+// NewPolicy returns a policy enforcing the cluster mode on instances of clusters in clusterModes, and the
+// default mode on others. Every mode other than ModeLog needs an action.
+func NewPolicy(defaultMode Mode, clusterModes map[string]Mode, actions ...Action) (*Policy, error) {
+	p := &Policy{
+		actions: map[Mode]Action{},
+	}
+
+	for _, action := range actions {
+		p.actions[Mode(action.Name())] = action
+	}
+
+	if err := p.SetModes(defaultMode, clusterModes); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// SetModes replaces the default mode and the modes of clusters, e.g. when the configuration is reloaded.
+func (p *Policy) SetModes(defaultMode Mode, clusterModes map[string]Mode) error {
+	for _, mode := range append([]Mode{defaultMode}, slices.Collect(maps.Values(clusterModes))...) {
+		if _, found := p.actions[mode]; !found && mode != ModeLog {
+			return fmt.Errorf("no action for protection mode %q", mode)
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.defaultMode = defaultMode
+	p.clusterModes = clusterModes
+
+	return nil
+}
+
+// Mode returns the protection mode of the cluster.
+func (p *Policy) Mode(clusterID string) Mode {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if mode, found := p.clusterModes[clusterID]; found {
+		return mode
+	}
+
+	return p.defaultMode
+}
+
+// Action returns the action enforced on invalid instances of the cluster, or nil when they are only reported.
+func (p *Policy) Action(clusterID string) Action {
+	return p.actions[p.Mode(clusterID)]
+}
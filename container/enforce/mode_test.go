@@ -0,0 +1,41 @@
+package enforce_test
+
+import (
+	"testing"
+
+	"github.com/castai/gcp-node-validator/container/enforce"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicy(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+
+	_, client := newFakeCompute(t)
+	deleter := enforce.NewDeleter(client)
+	stopper := enforce.NewStopper(client)
+
+	p, err := enforce.NewPolicy(enforce.ModeLog, map[string]enforce.Mode{
+		"c1": enforce.ModeDelete,
+		"c2": enforce.ModeStop,
+	}, deleter, stopper)
+	r.NoError(err)
+
+	r.Equal(deleter, p.Action("c1"))
+	r.Equal(stopper, p.Action("c2"))
+	r.Nil(p.Action("c3"))
+	r.Equal(enforce.ModeLog, p.Mode("c3"))
+
+	_, err = enforce.NewPolicy(enforce.ModeSuspend, nil, deleter)
+	r.Error(err, "modes without actions must be rejected")
+
+	r.NoError(p.SetModes(enforce.ModeStop, map[string]enforce.Mode{"c1": enforce.ModeLog}))
+	r.Nil(p.Action("c1"))
+	r.Equal(stopper, p.Action("c3"))
+
+	r.Error(p.SetModes(enforce.ModeSuspend, nil), "modes without actions must be rejected")
+	r.Equal(stopper, p.Action("c3"), "rejected modes are not set")
+
+	_, err = enforce.ParseMode("destroy")
+	r.Error(err)
+}
@@ -0,0 +1,192 @@
+package enforce
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/samber/lo"
+)
+
+const (
+	// QuarantinedLabel is set on quarantined instances.
+	QuarantinedLabel = "cast-quarantined"
+
+	// quarantineStateMetadataKey holds the state of the instance before the quarantine, to restore it on release.
+	quarantineStateMetadataKey = "cast-quarantine-state"
+)
+
+var ErrNotQuarantined = errors.New("instance is not quarantined")
+
+type quarantineState struct {
+	Tags          []string  `json:"tags"`
+	QuarantinedAt time.Time `json:"quarantinedAt"`
+}
+
+// Quarantiner isolates the instance from the network by replacing its network tags with the quarantine tag.
+// Firewall rules denying all traffic of the quarantine tag keep the instance from joining the cluster,
+// while the instance and its disks are kept for investigation.
+type Quarantiner struct {
+	computeClient *compute.InstancesClient
+	tag           string
+}
+
+func NewQuarantiner(computeClient *compute.InstancesClient, tag string) *Quarantiner {
+	return &Quarantiner{
+		computeClient: computeClient,
+		tag:           tag,
+	}
+}
+
+func (q *Quarantiner) Name() string {
+	return string(ModeQuarantine)
+}
+
+// Enforce quarantines the instance, applying only the steps missing when a previous attempt partially failed.
+func (q *Quarantiner) Enforce(ctx context.Context, target *Target) (*Outcome, error) {
+	instance := target.Instance
+	outcome := &Outcome{Action: q.Name()}
+
+	// The original state is recorded first, so the instance can be released if any later step fails. A recorded
+	// state is kept, as the current tags may already be the quarantine tag.
+	if _, found := findMetadataItem(instance.GetMetadata(), quarantineStateMetadataKey); !found {
+		state, err := json.Marshal(quarantineState{
+			Tags:          instance.GetTags().GetItems(),
+			QuarantinedAt: time.Now().UTC(),
+		})
+		if err != nil {
+			return outcome, fmt.Errorf("failed to marshal quarantine state: %w", err)
+		}
+
+		items := append(withoutMetadataItem(instance.GetMetadata(), quarantineStateMetadataKey), &computepb.Items{
+			Key:   lo.ToPtr(quarantineStateMetadataKey),
+			Value: lo.ToPtr(string(state)),
+		})
+		name, err := q.setMetadata(ctx, target, items)
+		if err != nil {
+			return outcome, err
+		}
+		outcome.Operations = append(outcome.Operations, name)
+	}
+
+	if !slices.Equal(instance.GetTags().GetItems(), []string{q.tag}) {
+		name, err := q.setTags(ctx, target, []string{q.tag})
+		if err != nil {
+			return outcome, err
+		}
+		outcome.Operations = append(outcome.Operations, name)
+	}
+
+	if instance.GetLabels()[QuarantinedLabel] != "true" {
+		labels := maps.Clone(instance.GetLabels())
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels[QuarantinedLabel] = "true"
+
+		name, err := setLabels(ctx, q.computeClient, target, labels)
+		if err != nil {
+			return outcome, fmt.Errorf("failed to set labels: %w", err)
+		}
+		outcome.Operations = append(outcome.Operations, name)
+	}
+
+	return outcome, nil
+}
+
+// Release restores the network tags the instance had before the quarantine.
+func (q *Quarantiner) Release(ctx context.Context, project, zone, name string) error {
+	instance, err := q.computeClient.Get(ctx, &computepb.GetInstanceRequest{
+		Project:  project,
+		Zone:     zone,
+		Instance: name,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get instance: %w", err)
+	}
+
+	rawState, found := findMetadataItem(instance.GetMetadata(), quarantineStateMetadataKey)
+	if !found {
+		return ErrNotQuarantined
+	}
+
+	var state quarantineState
+	if err := json.Unmarshal([]byte(rawState), &state); err != nil {
+		return fmt.Errorf("failed to unmarshal quarantine state: %w", err)
+	}
+
+	target := &Target{
+		Project:  project,
+		Zone:     zone,
+		Instance: instance,
+	}
+
+	if _, err := q.setTags(ctx, target, state.Tags); err != nil {
+		return err
+	}
+
+	labels := maps.Clone(instance.GetLabels())
+	delete(labels, QuarantinedLabel)
+	if _, err := setLabels(ctx, q.computeClient, target, labels); err != nil {
+		return fmt.Errorf("failed to set labels: %w", err)
+	}
+
+	_, err = q.setMetadata(ctx, target, withoutMetadataItem(instance.GetMetadata(), quarantineStateMetadataKey))
+	return err
+}
+
+func (q *Quarantiner) setMetadata(ctx context.Context, target *Target, items []*computepb.Items) (string, error) {
+	op, err := q.computeClient.SetMetadata(ctx, &computepb.SetMetadataInstanceRequest{
+		Project:  target.Project,
+		Zone:     target.Zone,
+		Instance: target.Instance.GetName(),
+		MetadataResource: &computepb.Metadata{
+			Fingerprint: target.Instance.GetMetadata().Fingerprint,
+			Items:       items,
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to set metadata: %w", err)
+	}
+
+	return WaitOperation(ctx, op)
+}
+
+func (q *Quarantiner) setTags(ctx context.Context, target *Target, tags []string) (string, error) {
+	op, err := q.computeClient.SetTags(ctx, &computepb.SetTagsInstanceRequest{
+		Project:  target.Project,
+		Zone:     target.Zone,
+		Instance: target.Instance.GetName(),
+		TagsResource: &computepb.Tags{
+			Fingerprint: target.Instance.GetTags().Fingerprint,
+			Items:       tags,
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to set tags: %w", err)
+	}
+
+	return WaitOperation(ctx, op)
+}
+
+func findMetadataItem(m *computepb.Metadata, key string) (string, bool) {
+	for _, item := range m.GetItems() {
+		if item.GetKey() == key {
+			return item.GetValue(), true
+		}
+	}
+
+	return "", false
+}
+
+func withoutMetadataItem(m *computepb.Metadata, key string) []*computepb.Items {
+	return lo.Filter(m.GetItems(), func(item *computepb.Items, _ int) bool {
+		return item.GetKey() != key
+	})
+}
@@ -0,0 +1,76 @@
+package enforce_test
+
+import (
+	"context"
+	"testing"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/castai/gcp-node-validator/container/enforce"
+	"github.com/castai/gcp-node-validator/container/internal/testutil"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuarantinerEnforceAndRelease(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+	ctx := context.Background()
+
+	instance := testutil.NewInstance()
+	instance.Metadata.Items[1].Value = lo.ToPtr("echo 'strange code'")
+	fake, client := newFakeCompute(t, instance)
+	q := enforce.NewQuarantiner(client, "cast-quarantine")
+	name := instance.GetName()
+
+	outcome, err := q.Enforce(ctx, &enforce.Target{Project: "p", Zone: "z", Instance: fake.instance(name)})
+	r.NoError(err)
+	r.Equal("quarantine", outcome.Action)
+	r.Len(outcome.Operations, 3)
+
+	quarantined := fake.instance(name)
+	r.Equal([]string{"cast-quarantine"}, quarantined.GetTags().GetItems())
+	r.Equal("true", quarantined.GetLabels()[enforce.QuarantinedLabel])
+	r.Len(quarantined.GetMetadata().GetItems(), 3, "original state must be recorded")
+	r.Equal("echo 'strange code'", quarantined.GetMetadata().GetItems()[1].GetValue(), "metadata must be kept")
+
+	// Enforcing again must not overwrite the recorded state.
+	outcome, err = q.Enforce(ctx, &enforce.Target{Project: "p", Zone: "z", Instance: quarantined})
+	r.NoError(err)
+	r.Empty(outcome.Operations)
+
+	r.NoError(q.Release(ctx, "p", "z", name))
+
+	released := fake.instance(name)
+	r.Equal([]string{"gke-cluster-node"}, released.GetTags().GetItems())
+	r.Equal(testutil.NewInstance().GetLabels(), released.GetLabels())
+	r.Len(released.GetMetadata().GetItems(), 2)
+
+	r.ErrorIs(q.Release(ctx, "p", "z", name), enforce.ErrNotQuarantined)
+}
+
+func TestQuarantinerEnforceCompletesPartialQuarantine(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+	ctx := context.Background()
+
+	// A previous attempt recorded the state and failed to set the tags.
+	instance := testutil.NewInstance()
+	instance.Metadata.Items = append(instance.Metadata.Items, &computepb.Items{
+		Key:   lo.ToPtr("cast-quarantine-state"),
+		Value: lo.ToPtr(`{"tags":["gke-cluster-node"],"quarantinedAt":"2024-01-01T00:00:00Z"}`),
+	})
+	fake, client := newFakeCompute(t, instance)
+	q := enforce.NewQuarantiner(client, "cast-quarantine")
+	name := instance.GetName()
+
+	outcome, err := q.Enforce(ctx, &enforce.Target{Project: "p", Zone: "z", Instance: fake.instance(name)})
+	r.NoError(err)
+	r.Len(outcome.Operations, 2, "only the tags and labels must be set")
+
+	quarantined := fake.instance(name)
+	r.Equal([]string{"cast-quarantine"}, quarantined.GetTags().GetItems())
+	r.Equal("true", quarantined.GetLabels()[enforce.QuarantinedLabel])
+
+	r.NoError(q.Release(ctx, "p", "z", name))
+	r.Equal([]string{"gke-cluster-node"}, fake.instance(name).GetTags().GetItems(), "recorded state must be restored")
+}
@@ -0,0 +1,240 @@
+package evidence
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"cloud.google.com/go/storage"
+	"github.com/castai/gcp-node-validator/container/enforce"
+	"github.com/castai/gcp-node-validator/container/validate"
+	"github.com/samber/lo"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const bundleVersion = 1
+
+var invalidSnapshotNameChars = regexp.MustCompile(`[^a-z0-9-]`)
+
+// credentialReplacements redact the API keys and tokens of scripts. Unlike validate.Redact, they keep the hosts and
+// identifiers of CAST, which are evidence too.
+var credentialReplacements = []*validate.RegexpReplacement{
+	validate.NewRegexReplacement(`\b([A-Z][A-Z0-9_]*(?:API_KEY|TOKEN|SECRET|PASSWORD))=("[^"]*"|'[^']*'|[^\s"']+)`, `$1=****`),
+	validate.NewRegexReplacement(`(?i)\b(X-Api-Key:\s*)[^\s"']+`, `${1}****`),
+	validate.NewRegexReplacement(`(?i)\b(Authorization:\s*Bearer\s+)[^\s"']+`, `${1}****`),
+}
+
+// credentialKeys match the keys of JSON fields holding credentials, whose values are redacted as a whole.
+var credentialKeys = regexp.MustCompile(`(?i)(api_?key|token|secret|password)$`)
+
+// Bundle is the evidence of an invalid instance, captured before a destructive action is enforced on it.
+type Bundle struct {
+	Version    int       `json:"version"`
+	CapturedAt time.Time `json:"capturedAt"`
+
+	Instance         json.RawMessage   `json:"instance"`
+	Metadata         map[string]string `json:"metadata"`
+	SerialPortOutput string            `json:"serialPortOutput,omitempty"`
+	// SerialPortError is set when the serial port output could not be read, e.g. for instances which are not running.
+	SerialPortError string `json:"serialPortError,omitempty"`
+
+	MetadataKey         string   `json:"metadataKey,omitempty"`
+	UnknownCommands     string   `json:"unknownCommands,omitempty"`
+	MatchedWhitelist    []string `json:"matchedWhitelist,omitempty"`
+	UnmatchedWhitelist  []string `json:"unmatchedWhitelist,omitempty"`
+	UnexpectedPrincipal *string  `json:"unexpectedPrincipal,omitempty"`
+
+	AuditLog         json.RawMessage `json:"auditLog,omitempty"`
+	BootDiskSnapshot string          `json:"bootDiskSnapshot,omitempty"`
+}
+
+// Collector captures evidence bundles into a Cloud Storage bucket, and optionally snapshots the boot disk.
+type Collector struct {
+	computeClient *compute.InstancesClient
+	disksClient   *compute.DisksClient
+	bucket        string
+
+	writeObject func(ctx context.Context, name string, data []byte) error
+	now         func() time.Time
+}
+
+// NewCollector returns a collector writing bundles to the bucket. Boot disks are snapshotted when disksClient is not nil.
+func NewCollector(computeClient *compute.InstancesClient, disksClient *compute.DisksClient, storageClient *storage.Client, bucket string) *Collector {
+	return &Collector{
+		computeClient: computeClient,
+		disksClient:   disksClient,
+		bucket:        bucket,
+		writeObject: func(ctx context.Context, name string, data []byte) error {
+			w := storageClient.Bucket(bucket).Object(name).NewWriter(ctx)
+			w.ContentType = "application/json"
+			if _, err := w.Write(data); err != nil {
+				_ = w.Close()
+				return err
+			}
+			return w.Close()
+		},
+		now: time.Now,
+	}
+}
+
+// Capture writes the evidence bundle of the target, which failed validation with validationErr, and returns its location.
+func (c *Collector) Capture(ctx context.Context, target *enforce.Target, validationErr error, auditLog []byte) (string, error) {
+	instance := target.Instance
+
+	instanceJSON, err := protojson.Marshal(instance)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal instance: %w", err)
+	}
+	instanceJSON, err = redactJSON(instanceJSON)
+	if err != nil {
+		return "", fmt.Errorf("failed to redact instance: %w", err)
+	}
+
+	bundle := &Bundle{
+		Version:    bundleVersion,
+		CapturedAt: c.now().UTC(),
+		Instance:   instanceJSON,
+		Metadata: lo.SliceToMap(instance.GetMetadata().GetItems(), func(item *computepb.Items) (string, string) {
+			return item.GetKey(), redactCredentials(item.GetValue())
+		}),
+	}
+
+	// The audit log of the insert request holds the metadata of the instance too.
+	if redacted, err := redactJSON(auditLog); err == nil {
+		bundle.AuditLog = redacted
+	}
+
+	valErr := &validate.ValidationError{}
+	if errors.As(validationErr, &valErr) {
+		bundle.MetadataKey = valErr.MetadataKey
+		bundle.UnknownCommands = valErr.UnknownCommands
+		bundle.MatchedWhitelist = valErr.MatchedWhitelist
+		bundle.UnmatchedWhitelist = valErr.UnmatchedWhitelist
+	}
+
+	principalErr := &validate.PrincipalError{}
+	if errors.As(validationErr, &principalErr) {
+		bundle.UnexpectedPrincipal = lo.ToPtr(principalErr.Principal)
+	}
+
+	serialPortOutput, err := c.computeClient.GetSerialPortOutput(ctx, &computepb.GetSerialPortOutputInstanceRequest{
+		Project:  target.Project,
+		Zone:     target.Zone,
+		Instance: instance.GetName(),
+		Port:     lo.ToPtr[int32](1),
+	})
+	if err != nil {
+		bundle.SerialPortError = err.Error()
+	} else {
+		bundle.SerialPortOutput = serialPortOutput.GetContents()
+	}
+
+	if c.disksClient != nil {
+		snapshot, err := c.snapshotBootDisk(ctx, target)
+		if err != nil {
+			return "", fmt.Errorf("failed to snapshot boot disk: %w", err)
+		}
+		bundle.BootDiskSnapshot = snapshot
+	}
+
+	data, err := json.Marshal(bundle)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal evidence bundle: %w", err)
+	}
+
+	name := path.Join(target.Project, target.Zone, instance.GetName(), fmt.Sprintf("%d-%s.json", instance.GetId(), bundle.CapturedAt.Format("20060102T150405Z")))
+	if err := c.writeObject(ctx, name, data); err != nil {
+		return "", fmt.Errorf("failed to write evidence bundle: %w", err)
+	}
+
+	return fmt.Sprintf("gs://%s/%s", c.bucket, name), nil
+}
+
+func (c *Collector) snapshotBootDisk(ctx context.Context, target *enforce.Target) (string, error) {
+	bootDisk, found := lo.Find(target.Instance.GetDisks(), func(d *computepb.AttachedDisk) bool {
+		return d.GetBoot()
+	})
+	if !found {
+		return "", fmt.Errorf("boot disk not found")
+	}
+
+	diskName := path.Base(bootDisk.GetSource())
+	snapshotName := snapshotName(target.Instance.GetName(), c.now())
+
+	op, err := c.disksClient.CreateSnapshot(ctx, &computepb.CreateSnapshotDiskRequest{
+		Project: target.Project,
+		Zone:    target.Zone,
+		Disk:    diskName,
+		SnapshotResource: &computepb.Snapshot{
+			Name:        lo.ToPtr(snapshotName),
+			Description: lo.ToPtr(fmt.Sprintf("Evidence of invalid instance %s", target.Instance.GetSelfLink())),
+			Labels:      map[string]string{"cast-evidence": "true"},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// The disk is deleted with the instance, so the snapshot must succeed before any action is enforced.
+	if _, err := enforce.WaitOperation(ctx, op); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("projects/%s/global/snapshots/%s", target.Project, snapshotName), nil
+}
+
+// redactCredentials replaces the API keys and tokens of the script with ****, keeping the rest verbatim.
+func redactCredentials(script string) string {
+	for _, replacement := range credentialReplacements {
+		script = replacement.Apply(script)
+	}
+	return script
+}
+
+// redactJSON redacts the credentials of the JSON document, as the bundle is readable by anyone investigating invalid
+// instances. Credentials are the values of credential fields, and the API keys and tokens within strings.
+func redactJSON(data []byte) (json.RawMessage, error) {
+	var document any
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	return json.Marshal(redactValue(document))
+}
+
+func redactValue(value any) any {
+	switch value := value.(type) {
+	case string:
+		return redactCredentials(value)
+	case []any:
+		for i := range value {
+			value[i] = redactValue(value[i])
+		}
+	case map[string]any:
+		for key := range value {
+			if _, isString := value[key].(string); isString && credentialKeys.MatchString(key) {
+				value[key] = "****"
+				continue
+			}
+			value[key] = redactValue(value[key])
+		}
+	}
+	return value
+}
+
+// snapshotName returns a valid snapshot name, at most 63 lowercase letters, digits and dashes.
+func snapshotName(instanceName string, t time.Time) string {
+	suffix := fmt.Sprintf("-evidence-%d", t.Unix())
+	name := invalidSnapshotNameChars.ReplaceAllString(strings.ToLower(instanceName), "-")
+	if len(name)+len(suffix) > 63 {
+		name = name[:63-len(suffix)]
+	}
+
+	return name + suffix
+}
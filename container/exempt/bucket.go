@@ -0,0 +1,112 @@
+package exempt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// maxObjectSize of the exceptions object.
+const maxObjectSize = 1024 * 1024
+
+// ObjectSource reads exceptions from a JSON object in GCS, caching them for the TTL. A missing object holds no
+// exceptions. The last exceptions read are kept while the object can not be read or is invalid, and reading it is
+// retried after the TTL.
+type ObjectSource struct {
+	client *storage.Client
+	bucket string
+	object string
+	ttl    time.Duration
+
+	mu         sync.Mutex
+	exceptions List
+	generation int64
+	// loaded is set once the object was read, err is the error of the last read when it failed.
+	loaded bool
+	err    error
+	// fetchedAt is the time of the last read, successful or not.
+	fetchedAt time.Time
+
+	now func() time.Time
+}
+
+func NewObjectSource(client *storage.Client, bucket, object string, ttl time.Duration) *ObjectSource {
+	return &ObjectSource{
+		client: client,
+		bucket: bucket,
+		object: object,
+		ttl:    ttl,
+		now:    time.Now,
+	}
+}
+
+func (s *ObjectSource) Exceptions(ctx context.Context) ([]Exception, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.fetchedAt.IsZero() || s.now().Sub(s.fetchedAt) >= s.ttl {
+		s.err = s.refresh(ctx)
+		s.fetchedAt = s.now()
+		if s.err != nil && s.loaded {
+			logrus.WithError(s.err).WithField("object", s.object).Warn("failed to refresh exceptions, using last exceptions read")
+		}
+	}
+
+	if s.err != nil && !s.loaded {
+		return nil, s.err
+	}
+	return s.exceptions, nil
+}
+
+func (s *ObjectSource) refresh(ctx context.Context) error {
+	obj := s.client.Bucket(s.bucket).Object(s.object)
+
+	attrs, err := obj.Attrs(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		s.exceptions, s.generation, s.loaded = nil, 0, true
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get exceptions object: %w", err)
+	}
+
+	if attrs.Generation == s.generation {
+		return nil
+	}
+	if attrs.Size > maxObjectSize {
+		return fmt.Errorf("exceptions object of %d bytes is too large", attrs.Size)
+	}
+
+	reader, err := obj.Generation(attrs.Generation).NewReader(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		// Deleted after its attributes were read.
+		s.exceptions, s.generation, s.loaded = nil, 0, true
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read exceptions object: %w", err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return fmt.Errorf("failed to read exceptions object: %w", err)
+	}
+
+	exceptions, err := Parse(data)
+	if err != nil {
+		return err
+	}
+
+	s.exceptions = exceptions
+	s.generation = attrs.Generation
+	s.loaded = true
+
+	return nil
+}
@@ -0,0 +1,130 @@
+package exempt
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/castai/gcp-node-validator/container/internal/testutil"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/option"
+)
+
+// fakeBucket serves the exceptions object of the exceptions bucket over the GCS JSON API, and counts the requests.
+type fakeBucket struct {
+	mu       sync.Mutex
+	status   int
+	content  string
+	requests int
+}
+
+func (f *fakeBucket) set(status int, content string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.status, f.content = status, content
+}
+
+func (f *fakeBucket) requestCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests
+}
+
+func (f *fakeBucket) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests++
+
+	if f.status != http.StatusOK {
+		http.Error(w, http.StatusText(f.status), f.status)
+		return
+	}
+	// The generation changes with the content.
+	generation := len(f.content)
+	if strings.HasPrefix(r.URL.Path, "/storage/v1/b/exceptions/o/") && r.URL.Query().Get("alt") != "media" {
+		_, _ = fmt.Fprintf(w, `{"bucket":"exceptions","name":"exceptions.json","generation":"%d","size":"%d"}`, generation, len(f.content))
+		return
+	}
+	w.Header().Set("X-Goog-Generation", fmt.Sprint(generation))
+	_, _ = w.Write([]byte(f.content))
+}
+
+func TestObjectSource(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+	ctx := context.Background()
+
+	bucket := &fakeBucket{status: http.StatusNotFound}
+	srv := httptest.NewServer(http.HandlerFunc(bucket.serveHTTP))
+	t.Cleanup(srv.Close)
+	client, err := storage.NewClient(ctx, option.WithEndpoint(srv.URL+"/storage/v1/"), option.WithoutAuthentication())
+	r.NoError(err)
+
+	clock := testutil.NewClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	source := NewObjectSource(client, "exceptions", "exceptions.json", time.Minute)
+	source.now = clock.Now
+
+	exceptions, err := source.Exceptions(ctx)
+	r.NoError(err, "a missing object holds no exceptions")
+	r.Empty(exceptions)
+
+	clock.Add(time.Minute)
+	bucket.set(http.StatusOK, `[{"name":"debug","nodePool":"debug","reason":"INC-1234","expires":"2025-02-01T00:00:00Z"}]`)
+	exceptions, err = source.Exceptions(ctx)
+	r.NoError(err)
+	r.Len(exceptions, 1)
+
+	clock.Add(time.Minute)
+	bucket.set(http.StatusForbidden, "")
+	exceptions, err = source.Exceptions(ctx)
+	r.NoError(err)
+	r.Len(exceptions, 1, "the last exceptions read are kept while the object can not be read")
+
+	requests := bucket.requestCount()
+	exceptions, err = source.Exceptions(ctx)
+	r.NoError(err)
+	r.Len(exceptions, 1)
+	r.Equal(requests, bucket.requestCount(), "failed reads are retried after the TTL")
+
+	clock.Add(time.Minute)
+	bucket.set(http.StatusNotFound, "")
+	exceptions, err = source.Exceptions(ctx)
+	r.NoError(err)
+	r.Empty(exceptions, "a deleted object holds no exceptions")
+}
+
+func TestObjectSourceUnreadableAtStartup(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+	ctx := context.Background()
+
+	bucket := &fakeBucket{status: http.StatusForbidden}
+	srv := httptest.NewServer(http.HandlerFunc(bucket.serveHTTP))
+	t.Cleanup(srv.Close)
+	client, err := storage.NewClient(ctx, option.WithEndpoint(srv.URL+"/storage/v1/"), option.WithoutAuthentication())
+	r.NoError(err)
+
+	clock := testutil.NewClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	source := NewObjectSource(client, "exceptions", "exceptions.json", time.Minute)
+	source.now = clock.Now
+
+	_, err = source.Exceptions(ctx)
+	r.Error(err)
+
+	requests := bucket.requestCount()
+	_, err = source.Exceptions(ctx)
+	r.Error(err, "exceptions are not assumed empty before the object was read")
+	r.Equal(requests, bucket.requestCount(), "failed reads are retried after the TTL")
+
+	clock.Add(time.Minute)
+	bucket.set(http.StatusOK, `[]`)
+	exceptions, err := source.Exceptions(ctx)
+	r.NoError(err)
+	r.Empty(exceptions)
+}
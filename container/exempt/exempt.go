@@ -0,0 +1,184 @@
+package exempt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+)
+
+const (
+	castClusterIDLabel = "cast-cluster-id"
+	clusterNameLabel   = "goog-k8s-cluster-name"
+	nodePoolNameLabel  = "goog-k8s-node-pool-name"
+)
+
+// Exception exempts the instances it matches from validation until it expires. Every criterion which is set has
+// to match, and at least one has to be set.
+type Exception struct {
+	// Name identifies the exception in logs.
+	Name string `json:"name"`
+	// InstanceName is a path.Match pattern of the instance name.
+	InstanceName string            `json:"instanceName,omitempty"`
+	Labels       map[string]string `json:"labels,omitempty"`
+	// Cluster is the CAST cluster ID or the GKE cluster name.
+	Cluster  string `json:"cluster,omitempty"`
+	NodePool string `json:"nodePool,omitempty"`
+
+	Reason  string    `json:"reason"`
+	Expires time.Time `json:"expires"`
+}
+
+// Validate checks that the exception has a reason, an expiry and matching criteria.
+func (e *Exception) Validate() error {
+	var errs []error
+	if e.Reason == "" {
+		errs = append(errs, errors.New("reason is required"))
+	}
+	if e.Expires.IsZero() {
+		errs = append(errs, errors.New("expires is required"))
+	}
+	if e.InstanceName == "" && len(e.Labels) == 0 && e.Cluster == "" && e.NodePool == "" {
+		errs = append(errs, errors.New("at least one of instanceName, labels, cluster or nodePool is required"))
+	}
+	if _, err := path.Match(e.InstanceName, ""); err != nil {
+		errs = append(errs, fmt.Errorf("invalid instanceName pattern: %w", err))
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("exception %q: %w", e.Name, err)
+	}
+	return nil
+}
+
+// Matches reports whether the exception matches the instance, regardless of its expiry.
+func (e *Exception) Matches(instance *computepb.Instance) bool {
+	labels := instance.GetLabels()
+
+	if e.InstanceName != "" {
+		if matched, _ := path.Match(e.InstanceName, instance.GetName()); !matched {
+			return false
+		}
+	}
+
+	for key, value := range e.Labels {
+		if v, found := labels[key]; !found || v != value {
+			return false
+		}
+	}
+
+	if e.Cluster != "" && labels[castClusterIDLabel] != e.Cluster && labels[clusterNameLabel] != e.Cluster {
+		return false
+	}
+
+	if e.NodePool != "" && labels[nodePoolNameLabel] != e.NodePool {
+		return false
+	}
+
+	return true
+}
+
+// List is a static list of exceptions.
+type List []Exception
+
+// Decode parses the list from JSON, the format of the configuration.
+func (l *List) Decode(value string) error {
+	list, err := Parse([]byte(value))
+	if err != nil {
+		return err
+	}
+
+	*l = list
+	return nil
+}
+
+func (l List) Exceptions(context.Context) ([]Exception, error) {
+	return l, nil
+}
+
+// DynamicList is a list of exceptions which can be replaced, e.g. when the configuration is reloaded.
+type DynamicList struct {
+	mu   sync.RWMutex
+	list List
+}
+
+func NewDynamicList(list List) *DynamicList {
+	return &DynamicList{list: list}
+}
+
+// Set replaces the exceptions.
+func (l *DynamicList) Set(list List) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.list = list
+}
+
+func (l *DynamicList) Exceptions(context.Context) ([]Exception, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.list, nil
+}
+
+// Parse parses and validates a JSON list of exceptions.
+func Parse(data []byte) (List, error) {
+	var list List
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse exceptions: %w", err)
+	}
+
+	var errs []error
+	for i := range list {
+		errs = append(errs, list[i].Validate())
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// Source provides exceptions.
+type Source interface {
+	Exceptions(ctx context.Context) ([]Exception, error)
+}
+
+// Registry finds the exception of an instance in its sources.
+type Registry struct {
+	sources []Source
+
+	now func() time.Time
+}
+
+func NewRegistry(sources ...Source) *Registry {
+	return &Registry{
+		sources: sources,
+		now:     time.Now,
+	}
+}
+
+// Match returns the first unexpired exception matching the instance, or nil when there is none.
+func (r *Registry) Match(ctx context.Context, instance *computepb.Instance) (*Exception, error) {
+	now := r.now()
+
+	var errs []error
+	for _, source := range r.sources {
+		exceptions, err := source.Exceptions(ctx)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		for _, e := range exceptions {
+			if now.Before(e.Expires) && e.Matches(instance) {
+				return &e, nil
+			}
+		}
+	}
+
+	return nil, errors.Join(errs...)
+}
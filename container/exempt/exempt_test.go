@@ -0,0 +1,150 @@
+package exempt
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/castai/gcp-node-validator/container/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExceptionMatches(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		exception Exception
+		want      bool
+	}{
+		{
+			name:      "instance name pattern",
+			exception: Exception{InstanceName: "gke-cluster-pool-*"},
+			want:      true,
+		},
+		{
+			name:      "instance name pattern mismatch",
+			exception: Exception{InstanceName: "gke-cluster-default-*"},
+		},
+		{
+			name:      "labels",
+			exception: Exception{Labels: map[string]string{"cast-managed-by": "cast-ai"}},
+			want:      true,
+		},
+		{
+			name:      "labels mismatch",
+			exception: Exception{Labels: map[string]string{"cast-managed-by": "cast-ai", "env": "dev"}},
+		},
+		{
+			name:      "CAST cluster ID",
+			exception: Exception{Cluster: "c1"},
+			want:      true,
+		},
+		{
+			name:      "GKE cluster name",
+			exception: Exception{Cluster: "cluster"},
+			want:      true,
+		},
+		{
+			name:      "node pool",
+			exception: Exception{Cluster: "cluster", NodePool: "pool"},
+			want:      true,
+		},
+		{
+			name:      "node pool of other cluster",
+			exception: Exception{Cluster: "other", NodePool: "pool"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tt.want, tt.exception.Matches(testutil.NewInstance()))
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{
+			name: "valid",
+			data: `[{"name": "debug", "nodePool": "debug", "reason": "debugging kubelet", "expires": "2030-01-01T00:00:00Z"}]`,
+		},
+		{
+			name:    "reason and expiry are mandatory",
+			data:    `[{"name": "debug", "nodePool": "debug"}]`,
+			wantErr: "exception \"debug\": reason is required\nexpires is required",
+		},
+		{
+			name:    "criteria are mandatory",
+			data:    `[{"name": "all", "reason": "everything", "expires": "2030-01-01T00:00:00Z"}]`,
+			wantErr: "at least one of instanceName, labels, cluster or nodePool is required",
+		},
+		{
+			name:    "invalid pattern",
+			data:    `[{"name": "debug", "instanceName": "[", "reason": "debugging", "expires": "2030-01-01T00:00:00Z"}]`,
+			wantErr: "invalid instanceName pattern",
+		},
+		{
+			name:    "invalid JSON",
+			data:    `{`,
+			wantErr: "failed to parse exceptions",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			r := require.New(t)
+
+			var list List
+			err := list.Decode(tt.data)
+			if tt.wantErr != "" {
+				r.ErrorContains(err, tt.wantErr)
+				return
+			}
+			r.NoError(err)
+			r.Len(list, 1)
+		})
+	}
+}
+
+type failingSource struct{}
+
+func (failingSource) Exceptions(context.Context) ([]Exception, error) {
+	return nil, errors.New("object not readable")
+}
+
+func TestRegistryMatch(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+	ctx := context.Background()
+
+	now := time.Now()
+	registry := NewRegistry(
+		failingSource{},
+		List{
+			{Name: "expired", NodePool: "pool", Reason: "old debugging", Expires: now.Add(-time.Hour)},
+			{Name: "other", NodePool: "default", Reason: "other pool", Expires: now.Add(time.Hour)},
+			{Name: "debug", NodePool: "pool", Reason: "debugging", Expires: now.Add(time.Hour)},
+		},
+	)
+	registry.now = func() time.Time { return now }
+
+	exception, err := registry.Match(ctx, testutil.NewInstance())
+	r.NoError(err, "errors of other sources are not returned once an exception matched")
+	r.Equal("debug", exception.Name)
+
+	registry.now = func() time.Time { return now.Add(time.Hour) }
+	exception, err = registry.Match(ctx, testutil.NewInstance())
+	r.Error(err)
+	r.Nil(exception, "exceptions expire")
+}
@@ -0,0 +1,167 @@
+// Package findings describes the outcome of validating an instance as a stable, versioned record, which is logged and
+// reported to the sinks of the validator.
+package findings
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/castai/gcp-node-validator/container/validate"
+)
+
+// SchemaVersion of ValidationResult. It is incremented on changes breaking consumers filtering on its fields.
+const SchemaVersion = 1
+
+// Types of findings.
+const (
+	// TypeUnknownCommands is found for metadata scripts running commands missing from the whitelist.
+	TypeUnknownCommands = "unknown_commands"
+	// TypeUnexpectedPrincipal is found for instances created by a principal which is not allowed.
+	TypeUnexpectedPrincipal = "unexpected_principal"
+	// TypeValidationFailed is found for instances which could not be validated.
+	TypeValidationFailed = "validation_failed"
+)
+
+// Labels of instances read into ValidationResult.
+const (
+	castClusterIDLabel = "cast-cluster-id"
+	clusterNameLabel   = "goog-k8s-cluster-name"
+	nodePoolNameLabel  = "goog-k8s-node-pool-name"
+)
+
+// Sink receives the results of validating instances, e.g. to notify other systems of invalid instances.
+type Sink interface {
+	Report(ctx context.Context, result *ValidationResult) error
+}
+
+// Flusher is a Sink which must be flushed before the validator exits, e.g. to send buffered results.
+type Flusher interface {
+	// Flush sends the pending results and releases the resources of the sink, which receives no results afterwards.
+	Flush(ctx context.Context) error
+}
+
+// ValidationResult is the record of validating an instance.
+type ValidationResult struct {
+	SchemaVersion int              `json:"schemaVersion"`
+	Verdict       validate.Verdict `json:"verdict"`
+	Project       string           `json:"project"`
+	Zone          string           `json:"zone"`
+	Instance      string           `json:"instance"`
+	InstanceID    string           `json:"instanceID,omitempty"`
+	SelfLink      string           `json:"selfLink,omitempty"`
+	// Cluster is the CAST cluster ID of the instance.
+	Cluster     string    `json:"cluster"`
+	ClusterName string    `json:"clusterName,omitempty"`
+	NodePool    string    `json:"nodePool,omitempty"`
+	Principal   string    `json:"principal,omitempty"`
+	Findings    []Finding `json:"findings,omitempty"`
+	// Action taken on the invalid instance, nil when none was taken.
+	Action     *Action   `json:"action,omitempty"`
+	ObservedAt time.Time `json:"observedAt"`
+}
+
+// Finding is a reason for the verdict of an instance.
+type Finding struct {
+	Type string `json:"type"`
+	// MetadataKey of the script with unknown commands.
+	MetadataKey string `json:"metadataKey,omitempty"`
+	// Lines of the script which are not whitelisted.
+	Lines []string `json:"lines,omitempty"`
+	// Principal which created the instance, when it is unexpected.
+	Principal string `json:"principal,omitempty"`
+	Detail    string `json:"detail,omitempty"`
+}
+
+// Action is the enforcement action taken on an invalid instance.
+type Action struct {
+	Name string `json:"name"`
+	// Result of the action, one of the metrics results.
+	Result string `json:"result"`
+	// DueAt is the time a scheduled action is enforced.
+	DueAt *time.Time `json:"dueAt,omitempty"`
+	// Evidence is the location of the evidence captured before the action.
+	Evidence string `json:"evidence,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// NewValidationResult returns the record of validating the instance, with the findings of the validation error.
+func NewValidationResult(project, zone string, instance *computepb.Instance, principal string, verdict validate.Verdict, err error) *ValidationResult {
+	result := &ValidationResult{
+		SchemaVersion: SchemaVersion,
+		Verdict:       verdict,
+		Project:       project,
+		Zone:          zone,
+		Instance:      instance.GetName(),
+		SelfLink:      instance.GetSelfLink(),
+		Cluster:       instance.GetLabels()[castClusterIDLabel],
+		ClusterName:   instance.GetLabels()[clusterNameLabel],
+		NodePool:      instance.GetLabels()[nodePoolNameLabel],
+		Principal:     principal,
+		Findings:      FromError(err),
+		ObservedAt:    time.Now().UTC(),
+	}
+	if instance.Id != nil {
+		result.InstanceID = strconv.FormatUint(instance.GetId(), 10)
+	}
+	return result
+}
+
+// FromError returns the findings of a validation error, one for each PrincipalError and ValidationError it joins.
+func FromError(err error) []Finding {
+	if err == nil {
+		return nil
+	}
+
+	var result []Finding
+	collectFindings(err, &result)
+	if len(result) > 0 {
+		return result
+	}
+
+	return []Finding{{
+		Type:   TypeValidationFailed,
+		Detail: err.Error(),
+	}}
+}
+
+// collectFindings walks the tree of wrapped and joined errors, as errors.As does, collecting every finding.
+func collectFindings(err error, result *[]Finding) {
+	switch err := err.(type) {
+	case *validate.PrincipalError:
+		*result = append(*result, Finding{
+			Type:      TypeUnexpectedPrincipal,
+			Principal: err.Principal,
+			Detail:    err.Error(),
+		})
+		return
+	case *validate.ValidationError:
+		*result = append(*result, Finding{
+			Type:        TypeUnknownCommands,
+			MetadataKey: err.MetadataKey,
+			Lines:       scriptLines(err.UnknownCommands),
+		})
+		return
+	case interface{ Unwrap() []error }:
+		for _, err := range err.Unwrap() {
+			collectFindings(err, result)
+		}
+	case interface{ Unwrap() error }:
+		if err := err.Unwrap(); err != nil {
+			collectFindings(err, result)
+		}
+	}
+}
+
+// scriptLines returns the non-blank lines of the script.
+func scriptLines(script string) []string {
+	var lines []string
+	for _, line := range strings.Split(script, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
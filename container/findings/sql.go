@@ -0,0 +1,149 @@
+package findings
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	// Drivers of the supported databases, SQLite for local use and PostgreSQL, e.g. Cloud SQL, in production.
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+)
+
+// Supported drivers of SQLStore.
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "pgx"
+)
+
+// The schema is portable between SQLite and PostgreSQL. The result column holds the JSON record, the other columns
+// are only used to filter.
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS validation_results (
+		observed_at BIGINT NOT NULL,
+		instance    TEXT NOT NULL,
+		cluster     TEXT NOT NULL,
+		verdict     TEXT NOT NULL,
+		result      TEXT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS validation_results_observed_at ON validation_results (observed_at)`,
+	`CREATE INDEX IF NOT EXISTS validation_results_instance ON validation_results (instance, observed_at)`,
+	`CREATE INDEX IF NOT EXISTS validation_results_cluster ON validation_results (cluster, observed_at)`,
+}
+
+// SQLStore is a Store keeping results in a SQL database.
+type SQLStore struct {
+	db *sql.DB
+}
+
+var _ Store = (*SQLStore)(nil)
+
+// OpenSQLStore opens the database with the driver, one of DriverSQLite or DriverPostgres, and creates the schema.
+func OpenSQLStore(ctx context.Context, driver, dsn string) (*SQLStore, error) {
+	if driver != DriverSQLite && driver != DriverPostgres {
+		return nil, fmt.Errorf("unsupported findings store driver %q, expected %s or %s", driver, DriverSQLite, DriverPostgres)
+	}
+
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open findings store: %w", err)
+	}
+	if driver == DriverSQLite {
+		// SQLite allows a single writer, concurrent writes would fail with SQLITE_BUSY.
+		db.SetMaxOpenConns(1)
+	}
+
+	for _, statement := range schema {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			_ = db.Close()
+			return nil, fmt.Errorf("failed to create findings store schema: %w", err)
+		}
+	}
+
+	return &SQLStore{db: db}, nil
+}
+
+// Report saves the result.
+func (s *SQLStore) Report(ctx context.Context, result *ValidationResult) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to marshal validation result: %w", err)
+	}
+
+	_, err = s.db.ExecContext(ctx,
+		`INSERT INTO validation_results (observed_at, instance, cluster, verdict, result) VALUES ($1, $2, $3, $4, $5)`,
+		result.ObservedAt.UnixNano(), result.Instance, result.Cluster, string(result.Verdict), string(data),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save validation result: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLStore) Query(ctx context.Context, query *Query) ([]*ValidationResult, error) {
+	var conditions []string
+	var args []any
+	where := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if query.Cluster != "" {
+		where("cluster = $%d", query.Cluster)
+	}
+	if query.Verdict != "" {
+		where("verdict = $%d", string(query.Verdict))
+	}
+	if query.Instance != "" {
+		where("instance = $%d", query.Instance)
+	}
+	if !query.Since.IsZero() {
+		where("observed_at >= $%d", query.Since.UnixNano())
+	}
+	if !query.Until.IsZero() {
+		where("observed_at < $%d", query.Until.UnixNano())
+	}
+
+	statement := "SELECT result FROM validation_results"
+	if len(conditions) > 0 {
+		statement += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, query.limit())
+	statement += fmt.Sprintf(" ORDER BY observed_at DESC LIMIT $%d", len(args))
+
+	rows, err := s.db.QueryContext(ctx, statement, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query validation results: %w", err)
+	}
+	defer rows.Close()
+
+	results := []*ValidationResult{}
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("failed to read validation result: %w", err)
+		}
+		result := &ValidationResult{}
+		if err := json.Unmarshal([]byte(data), result); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal validation result: %w", err)
+		}
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query validation results: %w", err)
+	}
+
+	return results, nil
+}
+
+// Ping checks that the database is reachable.
+func (s *SQLStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// Close closes the database.
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
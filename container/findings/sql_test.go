@@ -0,0 +1,95 @@
+package findings_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/castai/gcp-node-validator/container/findings"
+	"github.com/castai/gcp-node-validator/container/validate"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLStore(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	store, err := findings.OpenSQLStore(ctx, findings.DriverSQLite, filepath.Join(t.TempDir(), "findings.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+
+	saved := []*findings.ValidationResult{
+		{SchemaVersion: 1, Verdict: validate.VerdictValid, Instance: "node-1", Cluster: "cluster-1", ObservedAt: start},
+		{SchemaVersion: 1, Verdict: validate.VerdictInvalid, Instance: "node-2", Cluster: "cluster-1", ObservedAt: start.Add(time.Minute)},
+		{SchemaVersion: 1, Verdict: validate.VerdictUnverifiable, Instance: "node-3", Cluster: "cluster-2", ObservedAt: start.Add(2 * time.Minute)},
+		{
+			SchemaVersion: 1,
+			Verdict:       validate.VerdictInvalid,
+			Instance:      "node-2",
+			Cluster:       "cluster-1",
+			Findings:      []findings.Finding{{Type: findings.TypeUnknownCommands, Lines: []string{"curl"}}},
+			Action:        &findings.Action{Name: "delete", Result: "applied"},
+			ObservedAt:    start.Add(3 * time.Minute),
+		},
+	}
+	for _, result := range saved {
+		require.NoError(t, store.Report(ctx, result))
+	}
+
+	tests := []struct {
+		name  string
+		query *findings.Query
+		want  []*findings.ValidationResult
+	}{
+		{
+			name:  "all results, newest first",
+			query: &findings.Query{},
+			want:  []*findings.ValidationResult{saved[3], saved[2], saved[1], saved[0]},
+		},
+		{
+			name:  "cluster",
+			query: &findings.Query{Cluster: "cluster-1"},
+			want:  []*findings.ValidationResult{saved[3], saved[1], saved[0]},
+		},
+		{
+			name:  "verdict",
+			query: &findings.Query{Verdict: validate.VerdictInvalid},
+			want:  []*findings.ValidationResult{saved[3], saved[1]},
+		},
+		{
+			name:  "instance history",
+			query: &findings.Query{Instance: "node-2"},
+			want:  []*findings.ValidationResult{saved[3], saved[1]},
+		},
+		{
+			name:  "time range",
+			query: &findings.Query{Since: start.Add(time.Minute), Until: start.Add(3 * time.Minute)},
+			want:  []*findings.ValidationResult{saved[2], saved[1]},
+		},
+		{
+			name:  "combined filters and limit",
+			query: &findings.Query{Cluster: "cluster-1", Verdict: validate.VerdictInvalid, Limit: 1},
+			want:  []*findings.ValidationResult{saved[3]},
+		},
+		{
+			name:  "no match",
+			query: &findings.Query{Cluster: "cluster-3"},
+			want:  []*findings.ValidationResult{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := store.Query(ctx, tt.query)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestOpenSQLStoreUnsupportedDriver(t *testing.T) {
+	_, err := findings.OpenSQLStore(context.Background(), "mysql", "")
+	require.ErrorContains(t, err, "unsupported findings store driver")
+}
@@ -0,0 +1,42 @@
+package findings
+
+import (
+	"context"
+	"time"
+
+	"github.com/castai/gcp-node-validator/container/validate"
+)
+
+const (
+	// DefaultLimit of results returned by a query without a limit.
+	DefaultLimit = 100
+	// MaxLimit of results returned by a query.
+	MaxLimit = 1000
+)
+
+// Store persists validation results. Report saves the result, so a Store is also a Sink.
+type Store interface {
+	Sink
+	// Query returns the results matching the query, newest first.
+	Query(ctx context.Context, query *Query) ([]*ValidationResult, error)
+}
+
+// Query filters stored results. Empty fields match all results.
+type Query struct {
+	Cluster string
+	Verdict validate.Verdict
+	// Instance is the name of the instance.
+	Instance string
+	// Since and Until limit the time the results were observed at, Since inclusive and Until exclusive.
+	Since time.Time
+	Until time.Time
+	// Limit of returned results, DefaultLimit when 0 and at most MaxLimit.
+	Limit int
+}
+
+func (q *Query) limit() int {
+	if q.Limit <= 0 {
+		return DefaultLimit
+	}
+	return min(q.Limit, MaxLimit)
+}
@@ -0,0 +1,86 @@
+package gcperr
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/googleapis/gax-go/v2/apierror"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// IsTransient reports whether the Google Cloud request that failed with err can succeed when retried.
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	if IsRateLimited(err) {
+		return true
+	}
+
+	switch grpcCode(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Aborted, codes.Internal:
+		return true
+	}
+
+	switch httpCode(err) {
+	case http.StatusRequestTimeout, http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+
+	return false
+}
+
+func IsRateLimited(err error) bool {
+	return grpcCode(err) == codes.ResourceExhausted || httpCode(err) == http.StatusTooManyRequests
+}
+
+func IsNotFound(err error) bool {
+	return grpcCode(err) == codes.NotFound || httpCode(err) == http.StatusNotFound
+}
+
+// IsPreconditionFailed reports whether the request failed because a precondition, such as the generation of a GCS
+// object, did not match.
+func IsPreconditionFailed(err error) bool {
+	return grpcCode(err) == codes.FailedPrecondition || httpCode(err) == http.StatusPreconditionFailed
+}
+
+func grpcCode(err error) codes.Code {
+	if apiErr, ok := asAPIError(err); ok {
+		if s := apiErr.GRPCStatus(); s != nil {
+			return s.Code()
+		}
+		return codes.OK
+	}
+
+	if s, ok := status.FromError(err); ok {
+		return s.Code()
+	}
+
+	return codes.OK
+}
+
+func httpCode(err error) int {
+	if apiErr, ok := asAPIError(err); ok {
+		return apiErr.HTTPCode()
+	}
+
+	return 0
+}
+
+// asAPIError returns the APIError the Google Cloud clients wrap their errors with, or parses one from
+// a plain googleapi.Error or gRPC status error.
+func asAPIError(err error) (*apierror.APIError, bool) {
+	var apiErr *apierror.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr, true
+	}
+
+	return apierror.FromError(err)
+}
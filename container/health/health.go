@@ -0,0 +1,178 @@
+// Package health serves the liveness and readiness probes of the validator.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+)
+
+// Statuses of checks and of the readiness.
+const (
+	StatusOK          = "ok"
+	StatusUnavailable = "unavailable"
+)
+
+// Check reports whether a dependency is usable, failing with the reason when it is not.
+type Check func(ctx context.Context) error
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Checker runs the readiness checks of the dependencies.
+type Checker struct {
+	logger  logrus.FieldLogger
+	timeout time.Duration
+
+	mu     sync.RWMutex
+	checks []namedCheck
+}
+
+// NewChecker returns a Checker failing checks which do not complete within the timeout.
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{
+		logger:  logrus.StandardLogger(),
+		timeout: timeout,
+	}
+}
+
+// Register adds a check of the dependency with the name.
+func (c *Checker) Register(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, namedCheck{name: name, check: check})
+}
+
+// CheckResult is the outcome of a check.
+type CheckResult struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// Report is the readiness of the validator and the results of its checks, by name.
+type Report struct {
+	Status string                  `json:"status"`
+	Checks map[string]*CheckResult `json:"checks"`
+}
+
+// Run runs the checks concurrently.
+func (c *Checker) Run(ctx context.Context) *Report {
+	c.mu.RLock()
+	checks := c.checks
+	c.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	results := make([]*CheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = run(ctx, check.check)
+		}()
+	}
+	wg.Wait()
+
+	report := &Report{Status: StatusOK, Checks: make(map[string]*CheckResult, len(checks))}
+	for i, check := range checks {
+		report.Checks[check.name] = results[i]
+		if results[i].Status != StatusOK {
+			report.Status = StatusUnavailable
+		}
+	}
+	return report
+}
+
+func run(ctx context.Context, check Check) (result *CheckResult) {
+	start := time.Now()
+	defer func() {
+		if r := recover(); r != nil {
+			result = &CheckResult{Status: StatusUnavailable, Error: fmt.Sprintf("check panicked: %v", r)}
+		}
+		result.Duration = time.Since(start).Round(time.Millisecond).String()
+	}()
+
+	if err := check(ctx); err != nil {
+		return &CheckResult{Status: StatusUnavailable, Error: err.Error()}
+	}
+	return &CheckResult{Status: StatusOK}
+}
+
+// HandleLive responds whether the process is alive, which it is when it responds.
+func (c *Checker) HandleLive(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write([]byte("OK")); err != nil {
+		c.logger.WithError(err).Errorf("failed to write response")
+	}
+}
+
+// HandleReady responds with the report of the checks, with 503 when any check failed.
+func (c *Checker) HandleReady(w http.ResponseWriter, r *http.Request) {
+	report := c.Run(r.Context())
+
+	code := http.StatusOK
+	if report.Status != StatusOK {
+		code = http.StatusServiceUnavailable
+		c.logger.WithField("checks", report.Checks).Warn("not ready")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		c.logger.WithError(err).Errorf("failed to write response")
+	}
+}
+
+// State is a check of a state of the validator, failing with its error until the error is cleared.
+type State struct {
+	err atomic.Pointer[error]
+}
+
+// NewState returns a State failing with err, or succeeding when err is nil.
+func NewState(err error) *State {
+	s := &State{}
+	s.Set(err)
+	return s
+}
+
+// Set fails the check with err, or clears the error when err is nil.
+func (s *State) Set(err error) {
+	if err == nil {
+		s.err.Store(nil)
+		return
+	}
+	s.err.Store(&err)
+}
+
+func (s *State) Check(context.Context) error {
+	if err := s.err.Load(); err != nil {
+		return *err
+	}
+	return nil
+}
+
+// TokenCheck checks that the token source, e.g. of the application default credentials, provides a valid token.
+func TokenCheck(ts oauth2.TokenSource) Check {
+	return func(context.Context) error {
+		token, err := ts.Token()
+		if err != nil {
+			return fmt.Errorf("failed to get token: %w", err)
+		}
+		if !token.Valid() {
+			return fmt.Errorf("token expired at %s", token.Expiry)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,179 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+func TestHandleReady(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		checks     map[string]Check
+		wantStatus int
+		wantReport *Report
+	}{
+		{
+			name:       "no checks",
+			wantStatus: http.StatusOK,
+			wantReport: &Report{Status: StatusOK, Checks: map[string]*CheckResult{}},
+		},
+		{
+			name: "all checks pass",
+			checks: map[string]Check{
+				"bucket": func(context.Context) error { return nil },
+				"config": NewState(nil).Check,
+			},
+			wantStatus: http.StatusOK,
+			wantReport: &Report{Status: StatusOK, Checks: map[string]*CheckResult{
+				"bucket": {Status: StatusOK},
+				"config": {Status: StatusOK},
+			}},
+		},
+		{
+			name: "failed check",
+			checks: map[string]Check{
+				"bucket": func(context.Context) error { return errors.New("bucket not found") },
+				"config": NewState(nil).Check,
+			},
+			wantStatus: http.StatusServiceUnavailable,
+			wantReport: &Report{Status: StatusUnavailable, Checks: map[string]*CheckResult{
+				"bucket": {Status: StatusUnavailable, Error: "bucket not found"},
+				"config": {Status: StatusOK},
+			}},
+		},
+		{
+			name: "timed out check",
+			checks: map[string]Check{
+				"bucket": func(ctx context.Context) error {
+					<-ctx.Done()
+					return ctx.Err()
+				},
+			},
+			wantStatus: http.StatusServiceUnavailable,
+			wantReport: &Report{Status: StatusUnavailable, Checks: map[string]*CheckResult{
+				"bucket": {Status: StatusUnavailable, Error: context.DeadlineExceeded.Error()},
+			}},
+		},
+		{
+			name: "panicked check",
+			checks: map[string]Check{
+				"bucket": func(context.Context) error { panic("nil bucket") },
+			},
+			wantStatus: http.StatusServiceUnavailable,
+			wantReport: &Report{Status: StatusUnavailable, Checks: map[string]*CheckResult{
+				"bucket": {Status: StatusUnavailable, Error: "check panicked: nil bucket"},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			checker := NewChecker(50 * time.Millisecond)
+			for name, check := range tt.checks {
+				checker.Register(name, check)
+			}
+
+			rec := httptest.NewRecorder()
+			checker.HandleReady(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			require.Equal(t, tt.wantStatus, rec.Code)
+			require.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+			report := &Report{}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), report))
+			for _, result := range report.Checks {
+				require.NotEmpty(t, result.Duration)
+				result.Duration = ""
+			}
+			require.Equal(t, tt.wantReport, report)
+		})
+	}
+}
+
+func TestHandleLive(t *testing.T) {
+	t.Parallel()
+
+	checker := NewChecker(time.Second)
+	checker.Register("bucket", func(context.Context) error { return errors.New("bucket not found") })
+
+	rec := httptest.NewRecorder()
+	checker.HandleLive(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	// Liveness does not depend on the checks.
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "OK", rec.Body.String())
+}
+
+func TestState(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	state := NewState(errors.New("not loaded"))
+	require.EqualError(t, state.Check(ctx), "not loaded")
+
+	state.Set(nil)
+	require.NoError(t, state.Check(ctx))
+
+	state.Set(errors.New("shutting down"))
+	require.EqualError(t, state.Check(ctx), "shutting down")
+}
+
+func TestTokenCheck(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		token   *oauth2.Token
+		err     error
+		wantErr string
+	}{
+		{
+			name:  "valid token",
+			token: &oauth2.Token{AccessToken: "token", Expiry: time.Now().Add(time.Hour)},
+		},
+		{
+			name:    "expired token",
+			token:   &oauth2.Token{AccessToken: "token", Expiry: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+			wantErr: "token expired at 2024-01-01 00:00:00 +0000 UTC",
+		},
+		{
+			name:    "token error",
+			err:     errors.New("invalid_grant"),
+			wantErr: "failed to get token: invalid_grant",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := TokenCheck(tokenSource{token: tt.token, err: tt.err})(context.Background())
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+type tokenSource struct {
+	token *oauth2.Token
+	err   error
+}
+
+func (s tokenSource) Token() (*oauth2.Token, error) {
+	return s.token, s.err
+}
@@ -0,0 +1,60 @@
+// Package testutil holds the fakes shared by the tests of the validator packages.
+package testutil
+
+import (
+	"sync"
+	"time"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/samber/lo"
+)
+
+// Clock is a fake clock, whose Now is injected into the type under test in place of time.Now.
+type Clock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func NewClock(t time.Time) *Clock {
+	return &Clock{t: t}
+}
+
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+// Add moves the clock forward by d.
+func (c *Clock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+}
+
+// NewInstance returns a node of the pool node pool of the GKE cluster cluster, managed by CAST as the cluster c1.
+// Its scripts are empty, so it is valid with any whitelist.
+func NewInstance() *computepb.Instance {
+	return &computepb.Instance{
+		Id:   lo.ToPtr[uint64](42),
+		Name: lo.ToPtr("gke-cluster-pool-1234abcd-x1y2"),
+		Labels: map[string]string{
+			"cast-managed-by":         "cast-ai",
+			"cast-cluster-id":         "c1",
+			"goog-k8s-cluster-name":   "cluster",
+			"goog-k8s-node-pool-name": "pool",
+		},
+		LabelFingerprint: lo.ToPtr("labels"),
+		Tags: &computepb.Tags{
+			Fingerprint: lo.ToPtr("tags"),
+			Items:       []string{"gke-cluster-node"},
+		},
+		Metadata: &computepb.Metadata{
+			Fingerprint: lo.ToPtr("metadata"),
+			Items: []*computepb.Items{
+				{Key: lo.ToPtr("configure-sh"), Value: lo.ToPtr("")},
+				{Key: lo.ToPtr("user-data"), Value: lo.ToPtr("")},
+			},
+		},
+	}
+}
@@ -0,0 +1,107 @@
+package kube
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"sync"
+
+	container "cloud.google.com/go/container/apiv1"
+	"cloud.google.com/go/container/apiv1/containerpb"
+	"github.com/castai/gcp-node-validator/container/enforce"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	clusterNameLabel     = "goog-k8s-cluster-name"
+	clusterLocationLabel = "goog-k8s-cluster-location"
+)
+
+// ClientProvider returns a client of the Kubernetes cluster the instance belongs to.
+type ClientProvider interface {
+	Client(ctx context.Context, target *enforce.Target) (kubernetes.Interface, error)
+}
+
+// GKEClientProvider connects to GKE clusters with the credentials of the service.
+type GKEClientProvider struct {
+	clusterClient *container.ClusterManagerClient
+	tokenSource   oauth2.TokenSource
+
+	mu      sync.Mutex
+	clients map[string]kubernetes.Interface
+}
+
+func NewGKEClientProvider(ctx context.Context, clusterClient *container.ClusterManagerClient) (*GKEClientProvider, error) {
+	tokenSource, err := google.DefaultTokenSource(ctx, "https://www.googleapis.com/auth/cloud-platform")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get token source: %w", err)
+	}
+
+	return &GKEClientProvider{
+		clusterClient: clusterClient,
+		tokenSource:   tokenSource,
+		clients:       map[string]kubernetes.Interface{},
+	}, nil
+}
+
+func (p *GKEClientProvider) Client(ctx context.Context, target *enforce.Target) (kubernetes.Interface, error) {
+	clusterName, found := target.Instance.GetLabels()[clusterNameLabel]
+	if !found {
+		return nil, fmt.Errorf("cluster name not found")
+	}
+
+	location, found := target.Instance.GetLabels()[clusterLocationLabel]
+	if !found {
+		// Regional clusters are assumed, as for the node pools of the instance template whitelist.
+		location = target.Zone[:len(target.Zone)-2]
+	}
+
+	clusterURI := fmt.Sprintf("projects/%s/locations/%s/clusters/%s", target.Project, location, clusterName)
+
+	p.mu.Lock()
+	client, found := p.clients[clusterURI]
+	p.mu.Unlock()
+	if found {
+		return client, nil
+	}
+
+	// The cluster is read without holding the lock, so that a slow cluster does not block the others.
+	cluster, err := p.clusterClient.GetCluster(ctx, &containerpb.GetClusterRequest{
+		Name: clusterURI,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cluster: %w", err)
+	}
+
+	caCert, err := base64.StdEncoding.DecodeString(cluster.GetMasterAuth().GetClusterCaCertificate())
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode cluster CA certificate: %w", err)
+	}
+
+	client, err = kubernetes.NewForConfig(&rest.Config{
+		Host: "https://" + cluster.GetEndpoint(),
+		TLSClientConfig: rest.TLSClientConfig{
+			CAData: caCert,
+		},
+		WrapTransport: func(rt http.RoundTripper) http.RoundTripper {
+			return &oauth2.Transport{Source: p.tokenSource, Base: rt}
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// A concurrent call may have created a client of the cluster meanwhile, which is kept.
+	if cached, found := p.clients[clusterURI]; found {
+		return cached, nil
+	}
+	p.clients[clusterURI] = client
+	return client, nil
+}
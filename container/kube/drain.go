@@ -0,0 +1,168 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/castai/gcp-node-validator/container/enforce"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+// ValidationFailedTaint is added to the nodes of invalid instances.
+const ValidationFailedTaint = "cast.ai/validation-failed"
+
+const evictionRetryInterval = 5 * time.Second
+
+// Drainer takes the node of an invalid instance out of the cluster: it cordons the node, taints it with
+// the NoExecute ValidationFailedTaint and evicts its pods.
+type Drainer struct {
+	clients         ClientProvider
+	evictionTimeout time.Duration
+
+	pollInterval time.Duration
+}
+
+func NewDrainer(clients ClientProvider, evictionTimeout time.Duration) *Drainer {
+	return &Drainer{
+		clients:         clients,
+		evictionTimeout: evictionTimeout,
+		pollInterval:    evictionRetryInterval,
+	}
+}
+
+// Drain drains the node of the instance. Instances which have not joined the cluster have no node,
+// which is not an error. Pods which are not evicted within the eviction timeout are left on the node.
+func (d *Drainer) Drain(ctx context.Context, log logrus.FieldLogger, target *enforce.Target) error {
+	client, err := d.clients.Client(ctx, target)
+	if err != nil {
+		return err
+	}
+
+	nodeName := target.Instance.GetName()
+	log = log.WithField("node", nodeName)
+
+	if err := d.cordonAndTaint(ctx, client, nodeName); err != nil {
+		if apierrors.IsNotFound(err) {
+			log.Info("node not found, instance has not joined the cluster")
+			return nil
+		}
+		return err
+	}
+	log.Info("node cordoned and tainted")
+
+	remaining, err := d.evictPods(ctx, log, client, nodeName)
+	if err != nil {
+		return err
+	}
+	if remaining > 0 {
+		log.WithField("remainingPods", remaining).Warn("pods not evicted within timeout")
+		return nil
+	}
+	log.Info("node drained")
+
+	return nil
+}
+
+func (d *Drainer) cordonAndTaint(ctx context.Context, client kubernetes.Interface, nodeName string) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		node, err := client.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		node.Spec.Unschedulable = true
+
+		tainted := false
+		for _, taint := range node.Spec.Taints {
+			if taint.Key == ValidationFailedTaint && taint.Effect == corev1.TaintEffectNoExecute {
+				tainted = true
+				break
+			}
+		}
+		if !tainted {
+			node.Spec.Taints = append(node.Spec.Taints, corev1.Taint{
+				Key:       ValidationFailedTaint,
+				Value:     "true",
+				Effect:    corev1.TaintEffectNoExecute,
+				TimeAdded: &metav1.Time{Time: time.Now()},
+			})
+		}
+
+		_, err = client.CoreV1().Nodes().Update(ctx, node, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// evictPods evicts the pods of the node until none are left or the eviction timeout passes, and returns the
+// number of pods left on the node.
+func (d *Drainer) evictPods(ctx context.Context, log logrus.FieldLogger, client kubernetes.Interface, nodeName string) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.evictionTimeout)
+	defer cancel()
+
+	for {
+		pods, err := client.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+			FieldSelector: fields.OneTermEqualSelector("spec.nodeName", nodeName).String(),
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return -1, nil
+			}
+			return 0, fmt.Errorf("failed to list pods: %w", err)
+		}
+
+		evictable := 0
+		for _, pod := range pods.Items {
+			if !isEvictable(&pod) {
+				continue
+			}
+			evictable++
+
+			if pod.DeletionTimestamp != nil {
+				continue
+			}
+
+			err := client.PolicyV1().Evictions(pod.Namespace).Evict(ctx, &policyv1.Eviction{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      pod.Name,
+					Namespace: pod.Namespace,
+				},
+			})
+			switch {
+			case err == nil, apierrors.IsNotFound(err):
+			case apierrors.IsTooManyRequests(err):
+				// Disruption budget does not allow the eviction yet.
+				log.WithField("pod", pod.Namespace+"/"+pod.Name).Debug("pod eviction not allowed, retrying")
+			default:
+				log.WithError(err).WithField("pod", pod.Namespace+"/"+pod.Name).Warn("failed to evict pod")
+			}
+		}
+
+		if evictable == 0 {
+			return 0, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return evictable, nil
+		case <-time.After(d.pollInterval):
+		}
+	}
+}
+
+// isEvictable reports whether the pod can be evicted. Mirror pods of static pods can not, and pods
+// which completed do not need to be.
+func isEvictable(pod *corev1.Pod) bool {
+	if _, found := pod.Annotations[corev1.MirrorPodAnnotationKey]; found {
+		return false
+	}
+
+	return pod.Status.Phase != corev1.PodSucceeded && pod.Status.Phase != corev1.PodFailed
+}
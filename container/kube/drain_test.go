@@ -0,0 +1,179 @@
+package kube
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/castai/gcp-node-validator/container/enforce"
+	"github.com/samber/lo"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+type staticClientProvider struct {
+	client kubernetes.Interface
+}
+
+func (p *staticClientProvider) Client(context.Context, *enforce.Target) (kubernetes.Interface, error) {
+	return p.client, nil
+}
+
+func node(name string) *corev1.Node {
+	return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}}
+}
+
+func pod(name, nodeName string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec:       corev1.PodSpec{NodeName: nodeName},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+}
+
+// newFakeClient returns a clientset deleting evicted pods, unless their eviction is blocked. The fake clientset
+// ignores field selectors, so pods are filtered by node here.
+func newFakeClient(blocked []string, objects ...runtime.Object) *fake.Clientset {
+	client := fake.NewClientset(objects...)
+	client.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		selector := action.(k8stesting.ListAction).GetListRestrictions().Fields
+		obj, err := client.Tracker().List(schema.GroupVersionResource{Version: "v1", Resource: "pods"}, schema.GroupVersionKind{Version: "v1", Kind: "Pod"}, action.GetNamespace())
+		if err != nil {
+			return true, nil, err
+		}
+
+		pods := obj.(*corev1.PodList)
+		pods.Items = lo.Filter(pods.Items, func(p corev1.Pod, _ int) bool {
+			return selector.Matches(fields.Set{"spec.nodeName": p.Spec.NodeName})
+		})
+		return true, pods, nil
+	})
+	client.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "eviction" {
+			return false, nil, nil
+		}
+
+		eviction := action.(k8stesting.CreateAction).GetObject().(*policyv1.Eviction)
+		if lo.Contains(blocked, eviction.Name) {
+			return true, nil, apierrors.NewTooManyRequests("disruption budget", 1)
+		}
+
+		err := client.Tracker().Delete(schema.GroupVersionResource{Version: "v1", Resource: "pods"}, eviction.Namespace, eviction.Name)
+		return true, nil, err
+	})
+	return client
+}
+
+func target(name string) *enforce.Target {
+	return &enforce.Target{
+		Project:  "project",
+		Zone:     "us-central1-a",
+		Instance: &computepb.Instance{Name: lo.ToPtr(name)},
+	}
+}
+
+func TestDrainerDrain(t *testing.T) {
+	t.Parallel()
+
+	mirrorPod := pod("kube-proxy", "node-1")
+	mirrorPod.Annotations = map[string]string{corev1.MirrorPodAnnotationKey: "hash"}
+
+	completedPod := pod("job", "node-1")
+	completedPod.Status.Phase = corev1.PodSucceeded
+
+	tests := map[string]struct {
+		objects       []runtime.Object
+		blocked       []string
+		wantPods      []string
+		wantUntainted bool
+	}{
+		"node is cordoned, tainted and drained": {
+			objects:  []runtime.Object{node("node-1"), pod("a", "node-1"), pod("b", "node-1"), pod("c", "node-2")},
+			wantPods: []string{"c"},
+		},
+		"mirror and completed pods are not evicted": {
+			objects:  []runtime.Object{node("node-1"), mirrorPod, completedPod},
+			wantPods: []string{"job", "kube-proxy"},
+		},
+		"pods blocked by disruption budgets are left after timeout": {
+			objects:  []runtime.Object{node("node-1"), pod("a", "node-1"), pod("b", "node-1")},
+			blocked:  []string{"b"},
+			wantPods: []string{"b"},
+		},
+		"missing node is skipped": {
+			objects:       []runtime.Object{node("node-2"), pod("c", "node-2")},
+			wantPods:      []string{"c"},
+			wantUntainted: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			r := require.New(t)
+
+			client := newFakeClient(tt.blocked, tt.objects...)
+			drainer := NewDrainer(&staticClientProvider{client: client}, 100*time.Millisecond)
+			drainer.pollInterval = 10 * time.Millisecond
+
+			err := drainer.Drain(context.Background(), logrus.New(), target("node-1"))
+			r.NoError(err)
+
+			pods, err := client.CoreV1().Pods(metav1.NamespaceAll).List(context.Background(), metav1.ListOptions{})
+			r.NoError(err)
+			r.ElementsMatch(tt.wantPods, lo.Map(pods.Items, func(p corev1.Pod, _ int) string { return p.Name }))
+
+			if tt.wantUntainted {
+				return
+			}
+
+			n, err := client.CoreV1().Nodes().Get(context.Background(), "node-1", metav1.GetOptions{})
+			r.NoError(err)
+			r.True(n.Spec.Unschedulable)
+			r.Len(n.Spec.Taints, 1)
+			r.Equal(ValidationFailedTaint, n.Spec.Taints[0].Key)
+			r.Equal(corev1.TaintEffectNoExecute, n.Spec.Taints[0].Effect)
+		})
+	}
+}
+
+func TestDrainerDrainTaintsOnce(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+
+	client := newFakeClient(nil, node("node-1"))
+	drainer := NewDrainer(&staticClientProvider{client: client}, time.Second)
+
+	for range 2 {
+		r.NoError(drainer.Drain(context.Background(), logrus.New(), target("node-1")))
+	}
+
+	n, err := client.CoreV1().Nodes().Get(context.Background(), "node-1", metav1.GetOptions{})
+	r.NoError(err)
+	r.Len(n.Spec.Taints, 1)
+}
+
+func TestDrainerDrainNodeUpdateError(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+
+	client := newFakeClient(nil, node("node-1"))
+	client.PrependReactor("update", "nodes", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("forbidden")
+	})
+	drainer := NewDrainer(&staticClientProvider{client: client}, time.Second)
+
+	r.Error(drainer.Drain(context.Background(), logrus.New(), target("node-1")))
+}
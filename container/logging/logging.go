@@ -0,0 +1,100 @@
+// Package logging formats logs as the structured JSON understood by Cloud Logging.
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Special fields of structured logs, see https://cloud.google.com/logging/docs/structured-logging#special-payload-fields.
+const (
+	fieldSeverity     = "severity"
+	fieldMessage      = "message"
+	fieldTime         = "time"
+	fieldTrace        = "logging.googleapis.com/trace"
+	fieldSpanID       = "logging.googleapis.com/spanId"
+	fieldTraceSampled = "logging.googleapis.com/trace_sampled"
+	fieldLabels       = "logging.googleapis.com/labels"
+)
+
+// LabelsKey is the key of a Labels field, added to the labels of the log entry.
+const LabelsKey = "labels"
+
+// Labels of a log entry, which can be used in filters and log-based metric label extractors.
+type Labels map[string]string
+
+// Formatter formats entries as Cloud Logging structured JSON. The entry fields are written to the JSON payload, the
+// level is written as the severity and the span of the entry context as its trace.
+type Formatter struct {
+	// ProjectID of the traces.
+	ProjectID string
+	// Labels added to every entry.
+	Labels Labels
+}
+
+var _ logrus.Formatter = (*Formatter)(nil)
+
+func (f *Formatter) Format(entry *logrus.Entry) ([]byte, error) {
+	payload := make(map[string]any, len(entry.Data)+4)
+	labels := maps.Clone(f.Labels)
+	for key, value := range entry.Data {
+		switch value := value.(type) {
+		case Labels:
+			if labels == nil {
+				labels = make(Labels, len(value))
+			}
+			maps.Copy(labels, value)
+		case error:
+			// Errors mostly have no exported fields, so they would be written as {}.
+			payload[key] = value.Error()
+		default:
+			payload[key] = value
+		}
+	}
+
+	payload[fieldSeverity] = severity(entry.Level)
+	payload[fieldMessage] = entry.Message
+	payload[fieldTime] = entry.Time.Format(time.RFC3339Nano)
+	if len(labels) > 0 {
+		payload[fieldLabels] = labels
+	}
+
+	if entry.Context != nil {
+		if spanContext := trace.SpanContextFromContext(entry.Context); spanContext.IsValid() {
+			payload[fieldTrace] = fmt.Sprintf("projects/%s/traces/%s", f.ProjectID, spanContext.TraceID())
+			payload[fieldSpanID] = spanContext.SpanID().String()
+			payload[fieldTraceSampled] = spanContext.IsSampled()
+		}
+	}
+
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal log entry: %w", err)
+	}
+	return append(b, '\n'), nil
+}
+
+// severity returns the Cloud Logging severity of the level.
+func severity(level logrus.Level) string {
+	switch level {
+	case logrus.TraceLevel, logrus.DebugLevel:
+		return "DEBUG"
+	case logrus.InfoLevel:
+		return "INFO"
+	case logrus.WarnLevel:
+		return "WARNING"
+	case logrus.ErrorLevel:
+		return "ERROR"
+	case logrus.FatalLevel:
+		return "CRITICAL"
+	case logrus.PanicLevel:
+		return "ALERT"
+	default:
+		return "DEFAULT"
+	}
+}
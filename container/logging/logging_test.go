@@ -0,0 +1,125 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/castai/gcp-node-validator/container/logging"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestFormatter(t *testing.T) {
+	traceID, err := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	require.NoError(t, err)
+	spanID, err := trace.SpanIDFromHex("00f067aa0ba902b7")
+	require.NoError(t, err)
+	traced := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+
+	tests := []struct {
+		name   string
+		log    func(log *logrus.Logger)
+		level  logrus.Level
+		want   map[string]any
+		absent []string
+	}{
+		{
+			name: "fields and severity",
+			log: func(log *logrus.Logger) {
+				log.WithError(errors.New("not found")).WithField("instance", "node-1").Warn("instance is unverifiable")
+			},
+			want: map[string]any{
+				"severity": "WARNING",
+				"message":  "instance is unverifiable",
+				"error":    "not found",
+				"instance": "node-1",
+				"logging.googleapis.com/labels": map[string]any{
+					"service": "validator",
+				},
+			},
+			absent: []string{"logging.googleapis.com/trace"},
+		},
+		{
+			name: "trace of the entry context",
+			log: func(log *logrus.Logger) {
+				log.WithContext(traced).Error("failed to get instance")
+			},
+			want: map[string]any{
+				"severity":                             "ERROR",
+				"logging.googleapis.com/trace":         "projects/project/traces/4bf92f3577b34da6a3ce929d0e0e4736",
+				"logging.googleapis.com/spanId":        "00f067aa0ba902b7",
+				"logging.googleapis.com/trace_sampled": true,
+			},
+		},
+		{
+			name: "entry labels",
+			log: func(log *logrus.Logger) {
+				log.WithField(logging.LabelsKey, logging.Labels{"verdict": "invalid"}).Info("validation result")
+			},
+			want: map[string]any{
+				"severity": "INFO",
+				"logging.googleapis.com/labels": map[string]any{
+					"service": "validator",
+					"verdict": "invalid",
+				},
+			},
+			absent: []string{logging.LabelsKey},
+		},
+		{
+			name: "debug below the level",
+			log: func(log *logrus.Logger) {
+				log.Debug("instance not found, waiting")
+			},
+		},
+		{
+			name:  "debug",
+			level: logrus.DebugLevel,
+			log: func(log *logrus.Logger) {
+				log.Debug("instance not found, waiting")
+			},
+			want: map[string]any{
+				"severity": "DEBUG",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			r := require.New(t)
+
+			var out bytes.Buffer
+			log := logrus.New()
+			log.SetOutput(&out)
+			log.SetLevel(max(tt.level, logrus.InfoLevel))
+			log.SetFormatter(&logging.Formatter{
+				ProjectID: "project",
+				Labels:    logging.Labels{"service": "validator"},
+			})
+
+			tt.log(log)
+
+			if tt.want == nil {
+				r.Empty(out.String())
+				return
+			}
+			var got map[string]any
+			r.NoError(json.Unmarshal(out.Bytes(), &got))
+			r.Contains(got, "time")
+			for key, value := range tt.want {
+				r.Equal(value, got[key], key)
+			}
+			for _, key := range tt.absent {
+				r.NotContains(got, key)
+			}
+		})
+	}
+}
@@ -1,50 +1,280 @@
 package main
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
 	compute "cloud.google.com/go/compute/apiv1"
 	container "cloud.google.com/go/container/apiv1"
+	"cloud.google.com/go/pubsub"
 	"cloud.google.com/go/storage"
 	"github.com/castai/gcp-node-validator/container/api"
+	"github.com/castai/gcp-node-validator/container/configfile"
+	"github.com/castai/gcp-node-validator/container/dashboard"
+	"github.com/castai/gcp-node-validator/container/dedup"
+	"github.com/castai/gcp-node-validator/container/enforce"
+	"github.com/castai/gcp-node-validator/container/evidence"
+	"github.com/castai/gcp-node-validator/container/exempt"
+	"github.com/castai/gcp-node-validator/container/findings"
+	"github.com/castai/gcp-node-validator/container/health"
+	"github.com/castai/gcp-node-validator/container/kube"
+	"github.com/castai/gcp-node-validator/container/logging"
+	"github.com/castai/gcp-node-validator/container/metrics"
+	"github.com/castai/gcp-node-validator/container/notify"
+	"github.com/castai/gcp-node-validator/container/pending"
+	"github.com/castai/gcp-node-validator/container/queue"
+	"github.com/castai/gcp-node-validator/container/tracing"
 	"github.com/castai/gcp-node-validator/container/validate"
 	"github.com/kelseyhightower/envconfig"
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/oauth2/google"
+	securitycenter "google.golang.org/api/securitycenter/v1"
 )
 
+// flushTimeout is the part of the ShutdownTimeout reserved for flushing results.
+const flushTimeout = 2 * time.Second
+
+// whitelistLoadInterval at which the whitelist is loaded in the background, for the readiness check.
+const whitelistLoadInterval = time.Minute
+
 type Config struct {
-	LogLevel      string `default:"info"`
-	ProjectID     string `required:"true"`
-	DeleteInvalid bool   `default:"false"`
-	Port          int    `default:"8080"`
+	LogLevel string `default:"info"`
+	// LogFormat is json for the structured logs of Cloud Logging, or text.
+	LogFormat string `default:"json"`
+	ProjectID string `required:"false"`
+	Port      int    `default:"8080"`
+	// InstanceWaitTimeout in seconds to wait for an inserted instance to become readable.
+	InstanceWaitTimeout int `default:"120"`
+	// ReadinessTimeout in seconds for the checks of the readiness probe.
+	ReadinessTimeout int `default:"5"`
+	// ShutdownTimeout in seconds for completing in-flight requests and queued work on shutdown, and flushing results.
+	// The default stays below the 10 seconds after SIGTERM at which Cloud Run kills the instance.
+	ShutdownTimeout int `default:"8"`
 
 	ClusterIDs      []string `required:"false"`
+	Server          ServerConfig
 	WhitelistBucket WhitelistBucketConfig
+	Dedup           DedupConfig
+	Queue           QueueConfig
+	Evidence        EvidenceConfig
+	Kubernetes      KubernetesConfig
+	Breaker         BreakerConfig
+	Pending         PendingConfig
+	Exceptions      ExceptionsConfig
+	Tracing         TracingConfig
+	PubSub          PubSubConfig
+	Notify          NotifyConfig
+	SCC             SCCConfig
+	FindingsStore   FindingsStoreConfig
+
+	// Admins allowed to call the management API, releasing quarantined instances, approving pending actions and
+	// resetting the breaker, as emails of users or service accounts. The management API rejects all callers when empty.
+	Admins []string `required:"false"`
+	// AllowedPrincipals which may create instances, per CAST cluster ID, in the "cluster=principal|principal;*=principal" format.
+	AllowedPrincipals validate.PrincipalAllowList `required:"false"`
+
+	// ProtectionMode applied to invalid instances, one of log, label, stop, suspend, quarantine or delete.
+	ProtectionMode string `required:"false"`
+	// ClusterProtectionModes overrides the ProtectionMode per CAST cluster ID, in the "cluster:mode,cluster:mode" format.
+	ClusterProtectionModes map[string]string `required:"false"`
+	QuarantineTag          string            `default:"cast-quarantine"`
+	// InstanceGroupMethod deletes invalid instances managed by an instance group through the group, with
+	// instanceGroupManagers.deleteInstances when "delete" or abandonInstances when "abandon".
+	InstanceGroupMethod string `default:"delete"`
+	// DeleteInvalid enables the delete protection mode when no ProtectionMode is set. Deprecated.
+	DeleteInvalid bool `default:"false"`
+	// QuarantineInvalid enables the quarantine protection mode when no ProtectionMode is set. Deprecated.
+	QuarantineInvalid bool `default:"false"`
+}
+
+type ServerConfig struct {
+	// ReadTimeout in seconds for reading a request, including its body.
+	ReadTimeout int `default:"30"`
+	// WriteTimeout in seconds for processing a request and writing the response. Audit logs are processed before
+	// responding without queue workers, so it must exceed the Queue.JobTimeout then.
+	WriteTimeout int `default:"360"`
+	// IdleTimeout in seconds after which idle keep-alive connections are closed.
+	IdleTimeout int `default:"120"`
+}
+
+type QueueConfig struct {
+	// Workers processing queued audit logs. Audit logs are processed before responding when 0.
+	Workers int `default:"4"`
+	// Size is the number of audit logs waiting for a worker, before new ones are rejected.
+	Size int `default:"1000"`
+	// MaxPerCluster is the number of audit logs of a single cluster waiting for a worker.
+	MaxPerCluster int `default:"200"`
+	// JobTimeout in seconds for processing an audit log, including retries.
+	JobTimeout int `default:"300"`
+	// MaxAttempts to process an audit log failing with transient errors.
+	MaxAttempts int `default:"5"`
+}
+
+type EvidenceConfig struct {
+	// Bucket to write evidence of invalid instances to, before destructive actions. Evidence is not captured when empty.
+	Bucket string `required:"false"`
+	// Snapshot the boot disk of invalid instances as evidence.
+	Snapshot bool `default:"false"`
+}
+
+type TracingConfig struct {
+	// Endpoint of the OTLP gRPC receiver spans are exported to, as host:port. Spans are not exported when empty.
+	Endpoint string `required:"false"`
+	// Insecure disables TLS towards the Endpoint.
+	Insecure bool `default:"false"`
+	// SampleRatio of traces not propagated from Eventarc which are sampled.
+	SampleRatio float64 `default:"1"`
+	ServiceName string  `default:"gcp-node-validator"`
+}
+
+type ExceptionsConfig struct {
+	// List of exceptions exempting instances from validation, as a JSON array.
+	List exempt.List `required:"false"`
+	// Bucket and Object of a JSON array of exceptions in GCS, read in addition to the List.
+	Bucket string `required:"false"`
+	Object string `default:"exceptions.json"`
+	// TTL in seconds for which the exceptions object is cached.
+	TTL int `default:"60"`
+}
+
+type PubSubConfig struct {
+	// Topic validation results are published to. Results are not published when empty.
+	Topic string `required:"false"`
+	// Project of the Topic, the ProjectID when empty.
+	Project string `required:"false"`
+	// ValidResults publishes the results of valid instances too, not only of invalid and unverifiable instances.
+	ValidResults bool `default:"false"`
+}
+
+type NotifyConfig struct {
+	// WebhookURL the results of invalid instances are posted to as JSON. Not notified when empty.
+	WebhookURL string `required:"false"`
+	// WebhookSecret signs the webhook requests with HMAC-SHA256.
+	WebhookSecret string `required:"false"`
+	// SlackWebhookURL of a Slack incoming webhook notified of invalid instances. Not notified when empty.
+	SlackWebhookURL string `required:"false"`
+	// MaxAttempts to send a notification failing with a transient error.
+	MaxAttempts int `default:"3"`
+}
+
+type SCCConfig struct {
+	// Source of Security Command Center findings for invalid and unverifiable instances, as
+	// organizations/{organization}/sources/{source}. Findings are not reported when empty.
+	Source string `required:"false"`
+}
+
+type FindingsStoreConfig struct {
+	// Driver of the database validation results are stored in, sqlite or pgx for PostgreSQL.
+	Driver string `default:"sqlite"`
+	// DSN of the database, e.g. a file path for sqlite. Results are not stored when empty.
+	DSN string `required:"false"`
+}
+
+type PendingConfig struct {
+	// Delay in seconds between finding an invalid instance and enforcing the action on it, during which the instance
+	// can be approved. Actions are enforced immediately when 0.
+	Delay int `default:"0"`
+	// Bucket to persist pending actions in. Required with a Delay, unless Path is set.
+	Bucket string `required:"false"`
+	// Path of the directory to persist pending actions in, for deployments with a persistent disk.
+	Path string `required:"false"`
+	// PollInterval in seconds at which due actions are enforced.
+	PollInterval int `default:"15"`
+}
+
+type BreakerConfig struct {
+	// MaxActions is the number of instances of a cluster stopped, suspended, quarantined or deleted within the Window.
+	// Further invalid instances are only reported. Unlimited when 0.
+	MaxActions int `default:"20"`
+	// Window in seconds over which actions and verdicts are counted.
+	Window int `default:"600"`
+	// MaxInvalidRatio of invalid instances of a cluster within the Window, above which the breaker trips and invalid
+	// instances are only reported until it is reset. Disabled when 0.
+	MaxInvalidRatio float64 `default:"0.5"`
+	// MinVerdicts of a cluster within the Window before the invalid ratio is considered.
+	MinVerdicts int `default:"10"`
+	// Bucket to persist trips and actions in, shared by the instances of the service.
+	Bucket string `required:"false"`
+	// StatePath of the file persisting trips and actions across restarts, for deployments with a persistent disk. They
+	// are only kept in memory when neither the Bucket nor the StatePath is set.
+	StatePath string `required:"false"`
+}
+
+type KubernetesConfig struct {
+	// Drain cordons, taints and drains the node of invalid instances before they are taken out of service.
+	Drain bool `default:"false"`
+	// EvictionTimeout in seconds to wait for the pods of a drained node to be evicted.
+	EvictionTimeout int `default:"120"`
+}
+
+type DedupConfig struct {
+	// Size is the number of keys kept in memory.
+	Size int `default:"10000"`
+	// TTL in seconds for which a processed event or instance is not processed again.
+	TTL int `default:"3600"`
+	// Path of the directory to persist processed keys in. Keys are only kept in memory when empty.
+	Path string `required:"false"`
 }
 
 type WhitelistBucketConfig struct {
-	Name string `required:"true"`
+	Name string `required:"false"`
 	TTL  int    `default:"3600"`
 }
 
 func main() {
-	ctx := context.Background()
-
-	cfg := &Config{}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
 
-	if err := envconfig.Process("APP", cfg); err != nil {
+	configFile := &ConfigFileConfig{}
+	if err := envconfig.Process(envPrefix+"_CONFIGFILE", configFile); err != nil {
 		log.WithError(err).Fatal("failed to process config")
 	}
+	var configSource configfile.Source
+	var configData []byte
+	if configFile.Path != "" {
+		source, err := configfile.NewSource(ctx, configFile.Path)
+		if err != nil {
+			log.Fatalf("failed to read config file: %v", err)
+		}
+		defer source.Close()
+		configData, err = source.Read(ctx)
+		if err != nil {
+			log.Fatalf("failed to read config file: %v", err)
+		}
+		configSource = source
+	}
+
+	cfg, err := loadConfig(configData)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	setLogLevel(cfg.LogLevel)
+	switch cfg.LogFormat {
+	case "json":
+		log.SetFormatter(&logging.Formatter{ProjectID: cfg.ProjectID})
+	case "text":
+	default:
+		log.Warnf("invalid log format %s, defaulting to text", cfg.LogFormat)
+	}
 
-	logLevel, err := log.ParseLevel(cfg.LogLevel)
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Exporter{
+		Endpoint:    cfg.Tracing.Endpoint,
+		Insecure:    cfg.Tracing.Insecure,
+		SampleRatio: cfg.Tracing.SampleRatio,
+		ServiceName: cfg.Tracing.ServiceName,
+	})
 	if err != nil {
-		log.Warnf("invalid log level %s, defaulting to info", cfg.LogLevel)
-		logLevel = log.InfoLevel
+		log.Fatalf("failed to set up tracing: %v", err)
 	}
-	log.SetLevel(logLevel)
 
 	computeClient, err := compute.NewInstancesRESTClient(ctx)
 	if err != nil {
@@ -90,15 +320,332 @@ func main() {
 		log.Fatalf("failed to create cloud storage whitelist provider: %v", err)
 	}
 
+	checker := health.NewChecker(time.Duration(cfg.ReadinessTimeout) * time.Second)
+	// The config check fails until all dependencies are set up from the config.
+	configState := health.NewState(errors.New("config is not applied"))
+	checker.Register("config", configState.Check)
+	// The shutdown check fails once the validator is shutting down, so that it receives no new requests.
+	shutdownState := health.NewState(nil)
+	checker.Register("shutdown", shutdownState.Check)
+	// The readiness check reports the last background load, so that probes do not read the bucket.
+	whitelistState := health.NewState(errors.New("whitelist is not loaded yet"))
+	checker.Register("whitelist_bucket", whitelistState.Check)
+	go loadWhitelist(ctx, gcsWhitelistProvider, whitelistState)
+
+	tokenSource, err := google.DefaultTokenSource(ctx, "https://www.googleapis.com/auth/cloud-platform")
+	if err != nil {
+		log.Fatalf("failed to find default credentials: %v", err)
+	}
+	checker.Register("credentials", health.TokenCheck(tokenSource))
+
+	dedupStore, err := newDedupStore(cfg.Dedup)
+	if err != nil {
+		log.Fatalf("failed to create deduplication store: %v", err)
+	}
+
+	handlerOpts := []api.HandlerOption{
+		api.WithDeduplication(dedupStore, time.Duration(cfg.Dedup.TTL)*time.Second),
+		api.WithInstanceWaitTimeout(time.Duration(cfg.InstanceWaitTimeout) * time.Second),
+	}
+
+	if cfg.Evidence.Bucket != "" {
+		var disksClient *compute.DisksClient
+		if cfg.Evidence.Snapshot {
+			disksClient, err = compute.NewDisksRESTClient(ctx)
+			if err != nil {
+				log.Fatalf("failed to create disks client: %v", err)
+			}
+			defer disksClient.Close()
+		}
+
+		handlerOpts = append(handlerOpts, api.WithEvidenceCollector(evidence.NewCollector(computeClient, disksClient, cloudStorageClient, cfg.Evidence.Bucket)))
+	}
+
+	if cfg.Kubernetes.Drain {
+		kubeClients, err := kube.NewGKEClientProvider(ctx, clusterManagerClient)
+		if err != nil {
+			log.Fatalf("failed to create kubernetes client provider: %v", err)
+		}
+
+		handlerOpts = append(handlerOpts, api.WithNodeDrainer(kube.NewDrainer(kubeClients, time.Duration(cfg.Kubernetes.EvictionTimeout)*time.Second)))
+	}
+
+	var breakerState enforce.BreakerState
+	switch {
+	case cfg.Breaker.Bucket != "":
+		breakerState = enforce.NewBucketBreakerState(cloudStorageClient, cfg.Breaker.Bucket)
+	case cfg.Breaker.StatePath != "":
+		breakerState = enforce.NewFileBreakerState(cfg.Breaker.StatePath)
+	}
+	breaker := enforce.NewBreaker(enforce.BreakerLimits{
+		MaxActions:      cfg.Breaker.MaxActions,
+		Window:          time.Duration(cfg.Breaker.Window) * time.Second,
+		MaxInvalidRatio: cfg.Breaker.MaxInvalidRatio,
+		MinVerdicts:     cfg.Breaker.MinVerdicts,
+	}, breakerState)
+	checker.Register("breaker_state", func(ctx context.Context) error {
+		_, err := breaker.Trips(ctx)
+		return err
+	})
+	handlerOpts = append(handlerOpts, api.WithBreaker(breaker))
+
+	exceptionList := exempt.NewDynamicList(cfg.Exceptions.List)
+	exceptionSources := []exempt.Source{exceptionList}
+	if cfg.Exceptions.Bucket != "" {
+		exceptionSources = append(exceptionSources, exempt.NewObjectSource(cloudStorageClient, cfg.Exceptions.Bucket, cfg.Exceptions.Object, time.Duration(cfg.Exceptions.TTL)*time.Second))
+	}
+	handlerOpts = append(handlerOpts, api.WithExceptions(exempt.NewRegistry(exceptionSources...)))
+
+	var sinks []findings.Sink
+	if cfg.PubSub.Topic != "" {
+		pubsubClient, err := pubsub.NewClient(ctx, cmp.Or(cfg.PubSub.Project, cfg.ProjectID))
+		if err != nil {
+			log.Fatalf("failed to create pubsub client: %v", err)
+		}
+		defer pubsubClient.Close()
+
+		var publisherOpts []findings.PublisherOption
+		if cfg.PubSub.ValidResults {
+			publisherOpts = append(publisherOpts, findings.WithValidResults())
+		}
+		sinks = append(sinks, findings.NewPublisher(pubsubClient.Topic(cfg.PubSub.Topic), publisherOpts...))
+	}
+	notifyOpts := []notify.Option{notify.WithRetries(cfg.Notify.MaxAttempts, time.Second)}
+	if cfg.Notify.WebhookURL != "" {
+		sinks = append(sinks, notify.NewWebhook(cfg.Notify.WebhookURL, cfg.Notify.WebhookSecret, notifyOpts...))
+	}
+	if cfg.Notify.SlackWebhookURL != "" {
+		sinks = append(sinks, notify.NewSlack(cfg.Notify.SlackWebhookURL, notifyOpts...))
+	}
+	if cfg.SCC.Source != "" {
+		sccService, err := securitycenter.NewService(ctx)
+		if err != nil {
+			log.Fatalf("failed to create security command center client: %v", err)
+		}
+		sinks = append(sinks, findings.NewSecurityCenter(sccService, cfg.SCC.Source))
+	}
+	var findingsStore *findings.SQLStore
+	if cfg.FindingsStore.DSN != "" {
+		findingsStore, err = findings.OpenSQLStore(ctx, cfg.FindingsStore.Driver, cfg.FindingsStore.DSN)
+		if err != nil {
+			log.Fatalf("failed to open findings store: %v", err)
+		}
+		defer findingsStore.Close()
+		sinks = append(sinks, findingsStore)
+		checker.Register("findings_store", findingsStore.Ping)
+	}
+	handlerOpts = append(handlerOpts, api.WithSinks(sinks...))
+
+	var scheduler *pending.Scheduler
+	if cfg.Pending.Delay > 0 {
+		var store pending.Store
+		switch {
+		case cfg.Pending.Path != "":
+			store, err = pending.NewFileStore(cfg.Pending.Path)
+			if err != nil {
+				log.Fatalf("failed to create pending action store: %v", err)
+			}
+		default:
+			store = pending.NewBucketStore(cloudStorageClient, cfg.Pending.Bucket)
+		}
+
+		scheduler = pending.NewScheduler(store, time.Duration(cfg.Pending.Delay)*time.Second, time.Duration(cfg.Pending.PollInterval)*time.Second, time.Duration(cfg.Queue.JobTimeout)*time.Second)
+		handlerOpts = append(handlerOpts, api.WithScheduler(scheduler))
+	}
+
+	var workQueue *queue.Queue
+	if cfg.Queue.Workers > 0 {
+		workQueue = queue.New(cfg.Queue.Workers, cfg.Queue.Size, cfg.Queue.MaxPerCluster)
+		// Queued jobs keep running while the queue drains on shutdown, and are cancelled when it gives up.
+		workQueue.Start(context.WithoutCancel(ctx))
+		handlerOpts = append(handlerOpts, api.WithQueue(workQueue, time.Duration(cfg.Queue.JobTimeout)*time.Second, cfg.Queue.MaxAttempts))
+	}
+
+	quarantiner := enforce.NewQuarantiner(computeClient, cfg.QuarantineTag)
+
+	groupMethod, err := enforce.ParseGroupMethod(cfg.InstanceGroupMethod)
+	if err != nil {
+		log.Fatalf("failed to parse instance group method: %v", err)
+	}
+
+	deleter := enforce.NewDeleter(computeClient, enforce.WithInstanceGroups(instanceGroupManagersClient, instanceTemplateWhitelistProvider, groupMethod))
+
+	policy, err := newEnforcementPolicy(cfg, computeClient, quarantiner, deleter)
+	if err != nil {
+		log.Fatalf("failed to create enforcement policy: %v", err)
+	}
+
+	validator := validate.NewInstanceValidator(cfg.AllowedPrincipals, instanceTemplateWhitelistProvider, gcsWhitelistProvider)
 	handler := api.NewHandler(
 		cfg.ProjectID,
-		validate.NewInstanceValidator(instanceTemplateWhitelistProvider, gcsWhitelistProvider),
+		validator,
 		computeClient,
 		cfg.ClusterIDs,
-		cfg.DeleteInvalid,
+		policy,
+		handlerOpts...,
 	)
 
-	http.HandleFunc("/", handler.HandleAuditLog)
-	log.WithField("port", cfg.Port).Infof("listening for requests")
-	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", cfg.Port), nil))
+	adminAuth := api.NewAdminAuth(cfg.Admins)
+
+	// Eventarc posts audit logs to the root path only.
+	http.HandleFunc("POST /{$}", handler.HandleAuditLog)
+	http.Handle("GET /metrics", metrics.Handler())
+	http.HandleFunc("GET /healthz", checker.HandleLive)
+	http.HandleFunc("GET /readyz", checker.HandleReady)
+	http.HandleFunc("POST /api/v1/projects/{project}/zones/{zone}/instances/{instance}/release", adminAuth.Wrap(api.NewQuarantineHandler(quarantiner).HandleRelease))
+	if scheduler != nil {
+		scheduler.Start(ctx, handler.EnforcePending)
+
+		pendingHandler := api.NewPendingHandler(scheduler)
+		http.HandleFunc("GET /api/v1/pending", pendingHandler.HandleList)
+		http.HandleFunc("POST /api/v1/projects/{project}/zones/{zone}/instances/{instance}/approve", adminAuth.Wrap(pendingHandler.HandleApprove))
+	}
+	if findingsStore != nil {
+		findingsHandler := api.NewFindingsHandler(findingsStore)
+		http.HandleFunc("GET /api/v1/findings", findingsHandler.HandleList)
+		http.HandleFunc("GET /api/v1/instances/{name}", findingsHandler.HandleInstance)
+
+		dashboardHandler := dashboard.NewHandler(findingsStore, dashboard.WithWhitelistVersions(gcsWhitelistProvider))
+		http.HandleFunc("GET /dashboard/{$}", dashboardHandler.HandleSummary)
+		http.HandleFunc("GET /dashboard/instances/{name}", dashboardHandler.HandleInstance)
+	}
+	breakerHandler := api.NewBreakerHandler(breaker)
+	http.HandleFunc("GET /api/v1/breaker", breakerHandler.HandleStatus)
+	http.HandleFunc("POST /api/v1/breaker/reset", adminAuth.Wrap(breakerHandler.HandleReset))
+
+	server := &http.Server{
+		Addr:         fmt.Sprintf(":%d", cfg.Port),
+		ReadTimeout:  time.Duration(cfg.Server.ReadTimeout) * time.Second,
+		WriteTimeout: time.Duration(cfg.Server.WriteTimeout) * time.Second,
+		IdleTimeout:  time.Duration(cfg.Server.IdleTimeout) * time.Second,
+	}
+	configState.Set(nil)
+
+	if configSource != nil && configFile.ReloadInterval > 0 {
+		reloader := &configReloader{current: cfg, validator: validator, policy: policy, exceptions: exceptionList}
+		go configfile.Watch(ctx, configSource, time.Duration(configFile.ReloadInterval)*time.Second, configData, reloader.reload)
+	}
+
+	go func() {
+		log.WithField("port", cfg.Port).Infof("listening for requests")
+		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Info("shutting down")
+	shutdownState.Set(errors.New("shutting down"))
+
+	shutdown(cfg, server, workQueue, scheduler, sinks, shutdownTracing)
+}
+
+// shutdown completes the in-flight requests, the queued audit logs and the pending action being enforced concurrently,
+// then flushes the results, all within the ShutdownTimeout.
+func shutdown(cfg *Config, server *http.Server, workQueue *queue.Queue, scheduler *pending.Scheduler, sinks []findings.Sink, shutdownTracing func(context.Context) error) {
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.ShutdownTimeout)*time.Second)
+	defer cancel()
+	// Results are flushed within the last seconds.
+	drainCtx, cancel := context.WithTimeout(shutdownCtx, time.Duration(cfg.ShutdownTimeout)*time.Second-flushTimeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		// New requests are refused, while in-flight requests, which process audit logs without queue workers, complete.
+		if err := server.Shutdown(drainCtx); err != nil {
+			log.WithError(err).Error("failed to wait for in-flight requests")
+		}
+	}()
+
+	if workQueue != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Audit logs queued meanwhile by in-flight requests are rejected and redelivered. Audit logs still queued
+			// at the deadline are dropped and logged.
+			log.WithField("queued", workQueue.Len()).Info("draining queue")
+			if err := workQueue.Shutdown(drainCtx); err != nil {
+				log.WithError(err).Error("failed to drain queue")
+			}
+		}()
+	}
+
+	if scheduler != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// The scheduler stopped polling with ctx, and completes the action being enforced.
+			if err := scheduler.Wait(drainCtx); err != nil {
+				log.WithError(err).Error("failed to wait for pending action enforcement")
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Results of the drained jobs are flushed once no job reports results anymore. Other sinks report synchronously.
+	for _, sink := range sinks {
+		if flusher, ok := sink.(findings.Flusher); ok {
+			if err := flusher.Flush(shutdownCtx); err != nil {
+				log.WithError(err).Error("failed to flush findings")
+			}
+		}
+	}
+
+	// Spans of the drained jobs are flushed last.
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.WithError(err).Error("failed to flush spans")
+	}
+}
+
+// loadWhitelist loads the whitelist into its cache every whitelistLoadInterval until ctx is done, and records the result
+// in the state.
+func loadWhitelist(ctx context.Context, provider *validate.CloudStorageWhitelistGetter, state *health.State) {
+	for {
+		loadCtx, cancel := context.WithTimeout(ctx, whitelistLoadInterval)
+		err := provider.Load(loadCtx)
+		cancel()
+		if err != nil && ctx.Err() == nil {
+			log.WithError(err).Warn("failed to load whitelist")
+		}
+		state.Set(err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(whitelistLoadInterval):
+		}
+	}
+}
+
+func newDedupStore(cfg DedupConfig) (dedup.Store, error) {
+	memoryStore := dedup.NewMemoryStore(cfg.Size)
+	if cfg.Path == "" {
+		return memoryStore, nil
+	}
+
+	fileStore, err := dedup.NewFileStore(cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	return dedup.NewTieredStore(memoryStore, fileStore), nil
+}
+
+func newEnforcementPolicy(cfg *Config, computeClient *compute.InstancesClient, quarantiner *enforce.Quarantiner, deleter *enforce.Deleter) (*enforce.Policy, error) {
+	defaultMode, clusterModes, err := parseProtectionModes(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return enforce.NewPolicy(
+		defaultMode,
+		clusterModes,
+		enforce.NewLabeler(computeClient),
+		enforce.NewStopper(computeClient),
+		enforce.NewSuspender(computeClient),
+		quarantiner,
+		deleter,
+	)
 }
@@ -0,0 +1,107 @@
+// Package metrics exposes the Prometheus metrics of the validator.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "node_validator"
+
+// Results of enforcement actions.
+const (
+	ResultApplied   = "applied"
+	ResultFailed    = "failed"
+	ResultWithheld  = "withheld"
+	ResultScheduled = "scheduled"
+	ResultCancelled = "cancelled"
+	// ResultInterrupted is the result of actions whose enforcement was cancelled on shutdown.
+	ResultInterrupted = "interrupted"
+)
+
+var (
+	// EventsReceived counts the audit logs received, by method.
+	EventsReceived = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_received_total",
+		Help:      "Audit log events received, by method.",
+	}, []string{"method"})
+
+	// Verdicts counts the validated instances, by CAST cluster ID, node pool and verdict.
+	Verdicts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "verdicts_total",
+		Help:      "Validated instances, by CAST cluster ID, node pool and verdict.",
+	}, []string{"cluster", "node_pool", "verdict"})
+
+	// ProviderErrors counts the failures of whitelist providers, by provider.
+	ProviderErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "provider_errors_total",
+		Help:      "Failures to get a whitelist, by provider.",
+	}, []string{"provider"})
+
+	// EnforcementActions counts the actions enforced on invalid instances, by action and result.
+	EnforcementActions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "enforcement_actions_total",
+		Help:      "Enforcement actions on invalid instances, by action and result.",
+	}, []string{"action", "result"})
+
+	// WhitelistCacheRequests counts the lookups of whitelist objects in the cache, by hit or miss.
+	WhitelistCacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "whitelist_cache_requests_total",
+		Help:      "Lookups of whitelist objects in the cache, by result, hit or miss.",
+	}, []string{"result"})
+
+	// GCPCallDuration observes the latency of GCP API calls, by processing step and result.
+	GCPCallDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "gcp_call_duration_seconds",
+		Help:      "Latency of GCP API calls, by processing step and result.",
+		Buckets:   []float64{.01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 120},
+	}, []string{"step", "result"})
+)
+
+// Registry holds the metrics of the validator, and the Go runtime and process metrics.
+var Registry = prometheus.NewRegistry()
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		EventsReceived,
+		Verdicts,
+		ProviderErrors,
+		EnforcementActions,
+		WhitelistCacheRequests,
+		GCPCallDuration,
+	)
+}
+
+// Handler serves the metrics of the Registry.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// ObserveGCPCall observes the latency of a GCP API call of the step, started at start and failed with err.
+func ObserveGCPCall(step string, start time.Time, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	GCPCallDuration.WithLabelValues(step, result).Observe(time.Since(start).Seconds())
+}
+
+// CacheResult returns the label of a cache lookup.
+func CacheResult(hit bool) string {
+	if hit {
+		return "hit"
+	}
+	return "miss"
+}
@@ -0,0 +1,47 @@
+package metrics_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/castai/gcp-node-validator/container/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObserveGCPCall(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+
+	metrics.ObserveGCPCall("test_step", time.Now(), nil)
+	metrics.ObserveGCPCall("test_step", time.Now(), errors.New("unavailable"))
+	metrics.ObserveGCPCall("test_step", time.Now(), errors.New("unavailable"))
+
+	srv := httptest.NewServer(metrics.Handler())
+	t.Cleanup(srv.Close)
+
+	resp, err := http.Get(srv.URL)
+	r.NoError(err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	r.NoError(err)
+
+	r.Contains(string(body), `node_validator_gcp_call_duration_seconds_count{result="ok",step="test_step"} 1`)
+	r.Contains(string(body), `node_validator_gcp_call_duration_seconds_count{result="error",step="test_step"} 2`)
+	r.Contains(string(body), "go_goroutines")
+}
+
+func TestCacheResult(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+
+	metrics.WhitelistCacheRequests.WithLabelValues(metrics.CacheResult(true)).Inc()
+	metrics.WhitelistCacheRequests.WithLabelValues(metrics.CacheResult(false)).Inc()
+
+	r.InDelta(1, testutil.ToFloat64(metrics.WhitelistCacheRequests.WithLabelValues("hit")), 0)
+	r.InDelta(1, testutil.ToFloat64(metrics.WhitelistCacheRequests.WithLabelValues("miss")), 0)
+}
@@ -0,0 +1,119 @@
+// Package notify notifies people and systems of invalid instances, through HTTP webhooks and Slack.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/castai/gcp-node-validator/container/findings"
+	"github.com/castai/gcp-node-validator/container/validate"
+)
+
+const (
+	defaultMaxAttempts = 3
+	defaultBackoff     = time.Second
+	maxBackoff         = 30 * time.Second
+	requestTimeout     = 10 * time.Second
+)
+
+// Option configures a notifier.
+type Option func(*poster)
+
+// WithHTTPClient sends notifications with the client.
+func WithHTTPClient(client *http.Client) Option {
+	return func(p *poster) {
+		p.client = client
+	}
+}
+
+// WithRetries attempts to send a notification up to maxAttempts times, backing off exponentially from backoff
+// between attempts. Only network errors, 429 and 5xx responses are retried.
+func WithRetries(maxAttempts int, backoff time.Duration) Option {
+	return func(p *poster) {
+		p.maxAttempts = max(maxAttempts, 1)
+		p.backoff = backoff
+	}
+}
+
+// notified reports whether the result is notified. Only invalid instances are notified.
+func notified(result *findings.ValidationResult) bool {
+	return result.Verdict == validate.VerdictInvalid
+}
+
+// poster posts JSON bodies, retrying transient failures.
+type poster struct {
+	client      *http.Client
+	maxAttempts int
+	backoff     time.Duration
+}
+
+func newPoster(opts []Option) *poster {
+	p := &poster{
+		client:      &http.Client{Timeout: requestTimeout},
+		maxAttempts: defaultMaxAttempts,
+		backoff:     defaultBackoff,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// post posts the body to the URL, with the headers returned by header for each attempt.
+func (p *poster) post(ctx context.Context, url string, body []byte, header func() http.Header) error {
+	backoff := p.backoff
+	for attempt := 1; ; attempt++ {
+		retryAfter, err := p.attempt(ctx, url, body, header())
+		if err == nil {
+			return nil
+		}
+		if retryAfter < 0 || attempt >= p.maxAttempts {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w, last error: %w", ctx.Err(), err)
+		case <-time.After(max(backoff, retryAfter)):
+		}
+		backoff = min(2*backoff, maxBackoff)
+	}
+}
+
+// attempt posts the body once. The returned duration is negative when the failure is permanent, or the delay
+// requested by the server otherwise.
+func (p *poster) attempt(ctx context.Context, url string, body []byte, header http.Header) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return -1, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header = header
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return -1, err
+		}
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// The body is read so that the connection can be reused.
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return 0, nil
+	}
+
+	err = fmt.Errorf("unexpected status %s: %s", resp.Status, bytes.TrimSpace(respBody))
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+		return -1, err
+	}
+	seconds, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+	return min(time.Duration(seconds)*time.Second, maxBackoff), err
+}
@@ -0,0 +1,153 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/castai/gcp-node-validator/container/findings"
+)
+
+const (
+	// maxSlackLines of a finding are shown, Slack limits the text of a section to 3000 characters.
+	maxSlackLines     = 20
+	maxSlackLineChars = 120
+	// maxSlackHeaderChars is the limit of Slack for the text of a header.
+	maxSlackHeaderChars = 150
+)
+
+// Slack posts the validation results of invalid instances to a Slack incoming webhook.
+type Slack struct {
+	url    string
+	poster *poster
+}
+
+var _ findings.Sink = (*Slack)(nil)
+
+func NewSlack(webhookURL string, opts ...Option) *Slack {
+	return &Slack{
+		url:    webhookURL,
+		poster: newPoster(opts),
+	}
+}
+
+type slackMessage struct {
+	Text   string       `json:"text"`
+	Blocks []slackBlock `json:"blocks"`
+}
+
+type slackBlock struct {
+	Type string     `json:"type"`
+	Text *slackText `json:"text,omitempty"`
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+func (s *Slack) Report(ctx context.Context, result *findings.ValidationResult) error {
+	if !notified(result) {
+		return nil
+	}
+
+	body, err := json.Marshal(slackMessageFor(result))
+	if err != nil {
+		return fmt.Errorf("failed to marshal slack message: %w", err)
+	}
+
+	if err := s.poster.post(ctx, s.url, body, func() http.Header { return http.Header{} }); err != nil {
+		return fmt.Errorf("failed to notify slack: %w", err)
+	}
+	return nil
+}
+
+func slackMessageFor(result *findings.ValidationResult) *slackMessage {
+	summary := fmt.Sprintf("Invalid instance %s in cluster %s", result.Instance, clusterOf(result))
+
+	var details strings.Builder
+	fmt.Fprintf(&details, "*Instance:* `%s`\n", slackEscape(result.Instance))
+	fmt.Fprintf(&details, "*Project/zone:* %s / %s\n", slackEscape(result.Project), slackEscape(result.Zone))
+	fmt.Fprintf(&details, "*Cluster:* %s\n", slackEscape(clusterOf(result)))
+	if result.NodePool != "" {
+		fmt.Fprintf(&details, "*Node pool:* %s\n", slackEscape(result.NodePool))
+	}
+	if result.Principal != "" {
+		fmt.Fprintf(&details, "*Created by:* %s\n", slackEscape(result.Principal))
+	}
+	fmt.Fprintf(&details, "*Action:* %s", slackEscape(actionOf(result.Action)))
+
+	blocks := []slackBlock{
+		{Type: "header", Text: &slackText{Type: "plain_text", Text: truncate(summary, maxSlackHeaderChars)}},
+		{Type: "section", Text: &slackText{Type: "mrkdwn", Text: details.String()}},
+	}
+	for _, finding := range result.Findings {
+		blocks = append(blocks, slackBlock{Type: "section", Text: &slackText{Type: "mrkdwn", Text: findingText(finding)}})
+	}
+
+	return &slackMessage{Text: summary, Blocks: blocks}
+}
+
+func clusterOf(result *findings.ValidationResult) string {
+	switch {
+	case result.ClusterName != "" && result.Cluster != "":
+		return fmt.Sprintf("%s (%s)", result.ClusterName, result.Cluster)
+	case result.ClusterName != "":
+		return result.ClusterName
+	case result.Cluster != "":
+		return result.Cluster
+	default:
+		return "unknown"
+	}
+}
+
+func actionOf(action *findings.Action) string {
+	if action == nil {
+		return "none, only reported"
+	}
+	text := fmt.Sprintf("%s %s", action.Name, action.Result)
+	if action.DueAt != nil {
+		text += fmt.Sprintf(", due at %s", action.DueAt.Format("2006-01-02 15:04:05 MST"))
+	}
+	if action.Error != "" {
+		text += fmt.Sprintf(": %s", action.Error)
+	}
+	return text
+}
+
+func findingText(finding findings.Finding) string {
+	switch finding.Type {
+	case findings.TypeUnknownCommands:
+		lines := finding.Lines
+		var more string
+		if len(lines) > maxSlackLines {
+			more = fmt.Sprintf("\n…and %d more lines", len(lines)-maxSlackLines)
+			lines = lines[:maxSlackLines]
+		}
+		truncated := make([]string, len(lines))
+		for i, line := range lines {
+			truncated[i] = slackEscape(strings.ReplaceAll(truncate(line, maxSlackLineChars), "```", "'''"))
+		}
+		return fmt.Sprintf("*Commands not in the whitelist of `%s`:*\n```%s```%s", slackEscape(finding.MetadataKey), strings.Join(truncated, "\n"), more)
+	case findings.TypeUnexpectedPrincipal:
+		return fmt.Sprintf("*Created by an unexpected principal:* %s", slackEscape(finding.Principal))
+	default:
+		return slackEscape(finding.Detail)
+	}
+}
+
+// truncate shortens s to n characters, ending with an ellipsis when it is shortened.
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
+
+// slackEscape escapes the control characters of Slack mrkdwn.
+func slackEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
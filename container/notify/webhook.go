@@ -0,0 +1,75 @@
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/castai/gcp-node-validator/container/findings"
+)
+
+// Headers of webhook requests.
+const (
+	// TimestampHeader is the Unix time the request was signed at.
+	TimestampHeader = "X-Validator-Timestamp"
+	// SignatureHeader is "sha256=" followed by the hex encoded HMAC-SHA256 of the timestamp, a dot and the body.
+	SignatureHeader = "X-Validator-Signature"
+)
+
+// Webhook posts the validation results of invalid instances as JSON to a URL. Requests are signed with a shared
+// secret, so the receiver can verify them and reject replayed requests by their timestamp.
+type Webhook struct {
+	url    string
+	secret []byte
+	poster *poster
+	now    func() time.Time
+}
+
+var _ findings.Sink = (*Webhook)(nil)
+
+func NewWebhook(url, secret string, opts ...Option) *Webhook {
+	return &Webhook{
+		url:    url,
+		secret: []byte(secret),
+		poster: newPoster(opts),
+		now:    time.Now,
+	}
+}
+
+func (w *Webhook) Report(ctx context.Context, result *findings.ValidationResult) error {
+	if !notified(result) {
+		return nil
+	}
+
+	body, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to marshal validation result: %w", err)
+	}
+
+	err = w.poster.post(ctx, w.url, body, func() http.Header {
+		timestamp := strconv.FormatInt(w.now().Unix(), 10)
+		return http.Header{
+			TimestampHeader: []string{timestamp},
+			SignatureHeader: []string{Sign(w.secret, timestamp, body)},
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to notify webhook: %w", err)
+	}
+	return nil
+}
+
+// Sign returns the signature of a webhook request with the timestamp and body.
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
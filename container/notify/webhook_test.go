@@ -0,0 +1,120 @@
+package notify_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/castai/gcp-node-validator/container/findings"
+	"github.com/castai/gcp-node-validator/container/notify"
+	"github.com/castai/gcp-node-validator/container/validate"
+	"github.com/stretchr/testify/require"
+)
+
+func invalidResult() *findings.ValidationResult {
+	return &findings.ValidationResult{
+		SchemaVersion: findings.SchemaVersion,
+		Verdict:       validate.VerdictInvalid,
+		Project:       "project",
+		Zone:          "us-central1-a",
+		Instance:      "gke-cluster-pool-1",
+		Cluster:       "cluster-id",
+		ClusterName:   "cluster",
+		NodePool:      "pool",
+		Findings: []findings.Finding{{
+			Type:        findings.TypeUnknownCommands,
+			MetadataKey: validate.MetadataUserDataKey,
+			Lines:       []string{"curl https://example.com/install.sh | sh"},
+		}},
+		Action: &findings.Action{Name: "delete", Result: "applied"},
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		result       *findings.ValidationResult
+		wantErr      bool
+		wantAttempts int32
+	}{
+		{
+			name:         "invalid instance",
+			statuses:     []int{http.StatusOK},
+			result:       invalidResult(),
+			wantAttempts: 1,
+		},
+		{
+			name:   "valid instance",
+			result: &findings.ValidationResult{Verdict: validate.VerdictValid},
+		},
+		{
+			name:         "retried server errors",
+			statuses:     []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusNoContent},
+			result:       invalidResult(),
+			wantAttempts: 3,
+		},
+		{
+			name:         "attempts exhausted",
+			statuses:     []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway},
+			result:       invalidResult(),
+			wantErr:      true,
+			wantAttempts: 3,
+		},
+		{
+			name:         "client errors are not retried",
+			statuses:     []int{http.StatusUnauthorized},
+			result:       invalidResult(),
+			wantErr:      true,
+			wantAttempts: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			r := require.New(t)
+
+			var attempts atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				attempt := attempts.Add(1)
+				body, err := io.ReadAll(req.Body)
+				r.NoError(err)
+
+				timestamp := req.Header.Get(notify.TimestampHeader)
+				r.NotEmpty(timestamp)
+				r.Equal(notify.Sign([]byte("secret"), timestamp, body), req.Header.Get(notify.SignatureHeader))
+				r.Equal("application/json", req.Header.Get("Content-Type"))
+
+				var got findings.ValidationResult
+				r.NoError(json.Unmarshal(body, &got))
+				r.Equal(tt.result.Instance, got.Instance)
+
+				w.WriteHeader(tt.statuses[attempt-1])
+			}))
+			defer srv.Close()
+
+			webhook := notify.NewWebhook(srv.URL, "secret", notify.WithRetries(3, time.Millisecond))
+			err := webhook.Report(context.Background(), tt.result)
+			if tt.wantErr {
+				r.Error(err)
+			} else {
+				r.NoError(err)
+			}
+			r.Equal(tt.wantAttempts, attempts.Load())
+		})
+	}
+}
+
+func TestSign(t *testing.T) {
+	r := require.New(t)
+
+	// Computed with: printf %s '1700000000.{"verdict":"invalid"}' | openssl dgst -sha256 -hmac secret
+	signature := notify.Sign([]byte("secret"), "1700000000", []byte(`{"verdict":"invalid"}`))
+	r.Equal("sha256=43b0bbeb5f5c2dc83252552c2324aff031810874d130854b241ed87fe76ef04b", signature)
+}
@@ -0,0 +1,128 @@
+package pending
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"cloud.google.com/go/storage"
+	"github.com/castai/gcp-node-validator/container/gcperr"
+	"google.golang.org/api/iterator"
+)
+
+const bucketPrefix = "pending/"
+
+// BucketStore is a Store keeping one JSON object per pending action in a GCS bucket, for services without a
+// persistent disk. Updates are conditional on the generation of the object listed.
+type BucketStore struct {
+	client *storage.Client
+	bucket string
+}
+
+func NewBucketStore(client *storage.Client, bucket string) *BucketStore {
+	return &BucketStore{
+		client: client,
+		bucket: bucket,
+	}
+}
+
+func (s *BucketStore) Put(ctx context.Context, action *Action) error {
+	return s.write(ctx, s.object(action.Key()), action)
+}
+
+func (s *BucketStore) Update(ctx context.Context, action *Action) error {
+	conds := storage.Conditions{GenerationMatch: action.generation}
+	if action.generation == 0 {
+		conds = storage.Conditions{DoesNotExist: true}
+	}
+
+	err := s.write(ctx, s.object(action.Key()).If(conds), action)
+	if gcperr.IsPreconditionFailed(err) || errors.Is(err, storage.ErrObjectNotExist) {
+		return ErrConflict
+	}
+	return err
+}
+
+func (s *BucketStore) write(ctx context.Context, obj *storage.ObjectHandle, action *Action) error {
+	data, err := json.Marshal(action)
+	if err != nil {
+		return fmt.Errorf("failed to marshal pending action: %w", err)
+	}
+
+	w := obj.NewWriter(ctx)
+	w.ContentType = "application/json"
+	if _, err := w.Write(data); err != nil {
+		_ = w.Close()
+		return fmt.Errorf("failed to write pending action: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to write pending action: %w", err)
+	}
+	action.generation = w.Attrs().Generation
+
+	return nil
+}
+
+func (s *BucketStore) Delete(ctx context.Context, key string) error {
+	if err := s.object(key).Delete(ctx); err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to delete pending action: %w", err)
+	}
+
+	return nil
+}
+
+func (s *BucketStore) List(ctx context.Context) ([]*Action, error) {
+	var actions []*Action
+
+	it := s.client.Bucket(s.bucket).Objects(ctx, &storage.Query{Prefix: bucketPrefix})
+	for {
+		attrs, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list pending actions: %w", err)
+		}
+
+		action, err := s.read(ctx, attrs.Name)
+		if err != nil {
+			// Deleted concurrently.
+			if errors.Is(err, storage.ErrObjectNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		actions = append(actions, action)
+	}
+
+	return actions, nil
+}
+
+func (s *BucketStore) read(ctx context.Context, name string) (*Action, error) {
+	r, err := s.client.Bucket(s.bucket).Object(name).NewReader(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pending action: %w", err)
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pending action: %w", err)
+	}
+
+	action := &Action{generation: r.Attrs.Generation}
+	if err := json.Unmarshal(data, action); err != nil {
+		return nil, fmt.Errorf("failed to parse pending action %s: %w", name, err)
+	}
+
+	return action, nil
+}
+
+func (s *BucketStore) object(key string) *storage.ObjectHandle {
+	return s.client.Bucket(s.bucket).Object(bucketPrefix + key + ".json")
+}
@@ -0,0 +1,138 @@
+package pending
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileStore is a Store keeping one JSON file per pending action in a directory.
+type FileStore struct {
+	dir string
+
+	// mu serializes writes, so that updates are conditional on the generation stored.
+	mu sync.Mutex
+}
+
+// fileAction is the stored action, with the generation updates are conditional on.
+type fileAction struct {
+	*Action
+	Generation int64 `json:"generation"`
+}
+
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create store directory: %w", err)
+	}
+
+	return &FileStore{
+		dir: dir,
+	}, nil
+}
+
+func (s *FileStore) Put(_ context.Context, action *Action) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.write(action)
+}
+
+func (s *FileStore) Update(_ context.Context, action *Action) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, err := s.read(s.path(action.Key()))
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrConflict
+	}
+	if err != nil {
+		return err
+	}
+	if stored.generation != action.generation {
+		return ErrConflict
+	}
+
+	return s.write(action)
+}
+
+func (s *FileStore) write(action *Action) error {
+	generation := time.Now().UnixNano()
+	data, err := json.Marshal(fileAction{Action: action, Generation: generation})
+	if err != nil {
+		return fmt.Errorf("failed to marshal pending action: %w", err)
+	}
+
+	// Written to a temporary file first, so that List never reads a partial action.
+	path := s.path(action.Key())
+	if err := os.WriteFile(path+".tmp", data, 0o600); err != nil {
+		return fmt.Errorf("failed to write pending action: %w", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("failed to write pending action: %w", err)
+	}
+	action.generation = generation
+
+	return nil
+}
+
+func (s *FileStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.Remove(s.path(key)); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to remove pending action: %w", err)
+	}
+
+	return nil
+}
+
+func (s *FileStore) List(_ context.Context) ([]*Action, error) {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pending actions: %w", err)
+	}
+
+	actions := make([]*Action, 0, len(paths))
+	for _, path := range paths {
+		action, err := s.read(path)
+		if err != nil {
+			// Deleted concurrently.
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		actions = append(actions, action)
+	}
+
+	return actions, nil
+}
+
+func (s *FileStore) read(path string) (*Action, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pending action: %w", err)
+	}
+
+	stored := fileAction{Action: &Action{}}
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("failed to parse pending action %s: %w", filepath.Base(path), err)
+	}
+	stored.Action.generation = stored.Generation
+
+	return stored.Action, nil
+}
+
+// path returns the file of the key. Project IDs, zones and instance names contain no underscores.
+func (s *FileStore) path(key string) string {
+	return filepath.Join(s.dir, strings.ReplaceAll(key, "/", "_")+".json")
+}
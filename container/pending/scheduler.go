@@ -0,0 +1,163 @@
+package pending
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/castai/gcp-node-validator/container/gcperr"
+	"github.com/sirupsen/logrus"
+)
+
+// maxAttempts to enforce an action failing with transient errors, before it is dropped.
+const maxAttempts = 10
+
+// EnforceFunc enforces a due action. The action is removed once enforced, unless it failed with a transient error.
+type EnforceFunc func(ctx context.Context, action *Action) error
+
+// Scheduler delays enforcement actions by a grace period, during which an operator can approve the instance.
+type Scheduler struct {
+	logger       logrus.FieldLogger
+	store        Store
+	delay        time.Duration
+	pollInterval time.Duration
+	// timeout of enforcing a single action.
+	timeout time.Duration
+	// stopped is closed once the scheduler started by Start stopped.
+	stopped chan struct{}
+
+	now func() time.Time
+}
+
+func NewScheduler(store Store, delay, pollInterval, timeout time.Duration) *Scheduler {
+	return &Scheduler{
+		logger:       logrus.StandardLogger(),
+		store:        store,
+		delay:        delay,
+		pollInterval: pollInterval,
+		timeout:      timeout,
+		now:          time.Now,
+	}
+}
+
+// Schedule stores the action, due after the grace period.
+func (s *Scheduler) Schedule(ctx context.Context, action *Action) (*Action, error) {
+	action.DetectedAt = s.now()
+	action.DueAt = action.DetectedAt.Add(s.delay)
+
+	if err := s.store.Put(ctx, action); err != nil {
+		return nil, fmt.Errorf("failed to schedule action: %w", err)
+	}
+
+	return action, nil
+}
+
+// Approve cancels the pending action of the instance. It returns ErrNotFound when there is none.
+func (s *Scheduler) Approve(ctx context.Context, project, zone, instance string) error {
+	return s.store.Delete(ctx, Key(project, zone, instance))
+}
+
+// Start enforces due actions every poll interval, until the context is done. Wait waits for the scheduler to stop.
+func (s *Scheduler) Start(ctx context.Context, enforce EnforceFunc) {
+	s.stopped = make(chan struct{})
+	go func() {
+		defer close(s.stopped)
+		ticker := time.NewTicker(s.pollInterval)
+		defer ticker.Stop()
+
+		for {
+			if err := s.RunDue(ctx, enforce); err != nil {
+				s.logger.WithError(err).Error("failed to enforce pending actions")
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Wait waits until the scheduler stopped after the context of Start is done, including the enforcement of the action
+// being enforced, or until ctx is done.
+func (s *Scheduler) Wait(ctx context.Context) error {
+	if s.stopped == nil {
+		return nil
+	}
+
+	select {
+	case <-s.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// RunDue enforces the actions whose grace period passed. Each action is claimed before it is enforced, so that the
+// instances of the service sharing the store do not enforce it twice. Actions failing with transient errors are
+// retried at the next poll, up to maxAttempts. Once ctx is done, no further action is enforced, but the action being
+// enforced is completed and removed, so that an instance is not left half deleted.
+func (s *Scheduler) RunDue(ctx context.Context, enforce EnforceFunc) error {
+	actions, err := s.store.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := s.now()
+	for _, action := range actions {
+		if action.DueAt.After(now) || (action.LeasedUntil != nil && action.LeasedUntil.After(now)) {
+			continue
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		log := s.logger.WithFields(logrus.Fields{
+			"project":      action.Project,
+			"zone":         action.Zone,
+			"instanceName": action.Instance,
+			"action":       action.Action,
+		})
+
+		// The lease outlasts the enforcement, and expires when the instance of the service enforcing it stopped.
+		leasedUntil := now.Add(s.timeout + s.pollInterval)
+		action.LeasedUntil = &leasedUntil
+		if err := s.store.Update(ctx, action); err != nil {
+			if errors.Is(err, ErrConflict) {
+				log.Debug("pending action claimed by another instance or approved")
+				continue
+			}
+			return err
+		}
+
+		enforceCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s.timeout)
+		err := enforce(enforceCtx, action)
+		cancel()
+		if err != nil {
+			action.Attempts++
+			log = log.WithError(err).WithField("attempts", action.Attempts)
+			if gcperr.IsTransient(err) && action.Attempts < maxAttempts {
+				log.Warn("failed to enforce pending action, retrying")
+				action.LeasedUntil = nil
+				if err := s.store.Update(context.WithoutCancel(ctx), action); err != nil && !errors.Is(err, ErrConflict) {
+					return err
+				}
+				continue
+			}
+			log.Error("failed to enforce pending action, dropped")
+		}
+
+		if err := s.store.Delete(context.WithoutCancel(ctx), action.Key()); err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// List returns the pending actions.
+func (s *Scheduler) List(ctx context.Context) ([]*Action, error) {
+	return s.store.List(ctx)
+}
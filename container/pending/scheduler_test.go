@@ -0,0 +1,255 @@
+package pending
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/castai/gcp-node-validator/container/internal/testutil"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func newTestScheduler(t *testing.T, dir string, clock *testutil.Clock) *Scheduler {
+	t.Helper()
+
+	store, err := NewFileStore(dir)
+	require.NoError(t, err)
+
+	s := NewScheduler(store, time.Minute, time.Second, time.Second)
+	s.now = clock.Now
+	return s
+}
+
+func newTestAction(instance string) *Action {
+	return &Action{
+		Project:    "project",
+		Zone:       "us-central1-a",
+		Instance:   instance,
+		InstanceID: 1,
+		ClusterID:  "cluster",
+		Action:     "delete",
+	}
+}
+
+type recorder struct {
+	enforced []string
+	err      error
+}
+
+func (r *recorder) enforce(_ context.Context, action *Action) error {
+	r.enforced = append(r.enforced, action.Instance)
+	return r.err
+}
+
+func TestSchedulerEnforcesDueActions(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+	ctx := context.Background()
+
+	clock := testutil.NewClock(time.Now())
+	dir := t.TempDir()
+	s := newTestScheduler(t, dir, clock)
+
+	scheduled, err := s.Schedule(ctx, newTestAction("a"))
+	r.NoError(err)
+	r.Equal(clock.Now().Add(time.Minute), scheduled.DueAt)
+
+	rec := &recorder{}
+	r.NoError(s.RunDue(ctx, rec.enforce))
+	r.Empty(rec.enforced, "action is not due yet")
+
+	clock.Add(time.Minute)
+	restarted := newTestScheduler(t, dir, clock)
+	r.NoError(restarted.RunDue(ctx, rec.enforce))
+	r.Equal([]string{"a"}, rec.enforced, "pending actions survive restarts")
+
+	actions, err := restarted.List(ctx)
+	r.NoError(err)
+	r.Empty(actions, "enforced actions are removed")
+}
+
+func TestSchedulerApprove(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+	ctx := context.Background()
+
+	clock := testutil.NewClock(time.Now())
+	s := newTestScheduler(t, t.TempDir(), clock)
+
+	_, err := s.Schedule(ctx, newTestAction("a"))
+	r.NoError(err)
+	_, err = s.Schedule(ctx, newTestAction("b"))
+	r.NoError(err)
+
+	r.NoError(s.Approve(ctx, "project", "us-central1-a", "a"))
+	r.ErrorIs(s.Approve(ctx, "project", "us-central1-a", "a"), ErrNotFound)
+
+	clock.Add(time.Minute)
+	rec := &recorder{}
+	r.NoError(s.RunDue(ctx, rec.enforce))
+	r.Equal([]string{"b"}, rec.enforced)
+}
+
+func TestSchedulerEnforceErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		err          error
+		runs         int
+		wantAttempts int
+	}{
+		{
+			name:         "transient error is retried",
+			err:          status.Error(codes.Unavailable, "unavailable"),
+			runs:         2,
+			wantAttempts: 2,
+		},
+		{
+			name: "transient error drops the action after max attempts",
+			err:  status.Error(codes.Unavailable, "unavailable"),
+			runs: maxAttempts,
+		},
+		{
+			name: "permanent error drops the action",
+			err:  errors.New("permission denied"),
+			runs: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			r := require.New(t)
+			ctx := context.Background()
+
+			clock := testutil.NewClock(time.Now())
+			s := newTestScheduler(t, t.TempDir(), clock)
+
+			_, err := s.Schedule(ctx, newTestAction("a"))
+			r.NoError(err)
+
+			clock.Add(time.Minute)
+			rec := &recorder{err: tt.err}
+			for range tt.runs {
+				r.NoError(s.RunDue(ctx, rec.enforce))
+			}
+			r.Len(rec.enforced, tt.runs, "failed actions are released for the next poll")
+
+			actions, err := s.List(ctx)
+			r.NoError(err)
+			if tt.wantAttempts == 0 {
+				r.Empty(actions)
+				return
+			}
+			r.Len(actions, 1)
+			r.Equal(tt.wantAttempts, actions[0].Attempts)
+		})
+	}
+}
+
+func TestSchedulerClaimsActions(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+	ctx := context.Background()
+
+	clock := testutil.NewClock(time.Now())
+	dir := t.TempDir()
+	s := newTestScheduler(t, dir, clock)
+	other := newTestScheduler(t, dir, clock)
+
+	_, err := s.Schedule(ctx, newTestAction("a"))
+	r.NoError(err)
+	clock.Add(time.Minute)
+
+	otherRec := &recorder{}
+	r.NoError(s.RunDue(ctx, func(ctx context.Context, action *Action) error {
+		// Another instance polls while the action is being enforced.
+		r.NoError(other.RunDue(ctx, otherRec.enforce))
+		return nil
+	}))
+	r.Empty(otherRec.enforced, "claimed actions are skipped")
+
+	_, err = s.Schedule(ctx, newTestAction("b"))
+	r.NoError(err)
+	clock.Add(time.Minute)
+
+	actions, err := s.List(ctx)
+	r.NoError(err)
+	r.Len(actions, 1)
+	r.NoError(s.Approve(ctx, "project", "us-central1-a", "b"))
+	r.ErrorIs(s.store.Update(ctx, actions[0]), ErrConflict, "approved actions are not claimed")
+
+	_, err = s.Schedule(ctx, newTestAction("b"))
+	r.NoError(err)
+	r.ErrorIs(s.store.Update(ctx, actions[0]), ErrConflict, "rescheduled actions are not claimed")
+}
+
+func TestSchedulerReleasesExpiredLeases(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+	ctx := context.Background()
+
+	clock := testutil.NewClock(time.Now())
+	s := newTestScheduler(t, t.TempDir(), clock)
+
+	_, err := s.Schedule(ctx, newTestAction("a"))
+	r.NoError(err)
+	clock.Add(time.Minute)
+
+	// An instance of the service claimed the action and stopped before enforcing it.
+	actions, err := s.List(ctx)
+	r.NoError(err)
+	leasedUntil := clock.Now().Add(time.Minute)
+	actions[0].LeasedUntil = &leasedUntil
+	r.NoError(s.store.Update(ctx, actions[0]))
+
+	rec := &recorder{}
+	r.NoError(s.RunDue(ctx, rec.enforce))
+	r.Empty(rec.enforced, "leased actions are skipped")
+
+	clock.Add(time.Minute)
+	r.NoError(s.RunDue(ctx, rec.enforce))
+	r.Equal([]string{"a"}, rec.enforced, "expired leases are claimed again")
+}
+
+func TestSchedulerCompletesActionOnStop(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+	ctx := context.Background()
+
+	clock := testutil.NewClock(time.Now())
+	s := newTestScheduler(t, t.TempDir(), clock)
+
+	_, err := s.Schedule(ctx, newTestAction("a"))
+	r.NoError(err)
+	_, err = s.Schedule(ctx, newTestAction("b"))
+	r.NoError(err)
+	clock.Add(time.Minute)
+
+	startCtx, stop := context.WithCancel(ctx)
+	started := make(chan struct{})
+	var enforced []string
+	var enforceErr error
+	s.Start(startCtx, func(ctx context.Context, action *Action) error {
+		enforced = append(enforced, action.Instance)
+		close(started)
+		// The scheduler is stopped while the action is being enforced.
+		stop()
+		enforceErr = ctx.Err()
+		return nil
+	})
+
+	<-started
+	r.NoError(s.Wait(ctx))
+	r.Len(enforced, 1, "no further action is enforced once stopped")
+	r.NoError(enforceErr, "the action being enforced is not cancelled")
+
+	actions, err := s.List(ctx)
+	r.NoError(err)
+	r.Len(actions, 1, "the enforced action is removed")
+	r.NotEqual(enforced[0], actions[0].Instance)
+}
@@ -0,0 +1,64 @@
+package pending
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/castai/gcp-node-validator/container/findings"
+)
+
+var (
+	// ErrNotFound is returned for instances without a pending action.
+	ErrNotFound = errors.New("pending action not found")
+	// ErrConflict is returned when a pending action was changed or deleted since it was listed.
+	ErrConflict = errors.New("pending action changed concurrently")
+)
+
+// Action is an enforcement action waiting for its grace period to pass.
+type Action struct {
+	Project    string `json:"project"`
+	Zone       string `json:"zone"`
+	Instance   string `json:"instance"`
+	InstanceID uint64 `json:"instanceId"`
+	ClusterID  string `json:"clusterId"`
+	// Action is the name of the action resolved when the instance was found invalid.
+	Action     string    `json:"action"`
+	DetectedAt time.Time `json:"detectedAt"`
+	DueAt      time.Time `json:"dueAt"`
+	// Principal which created the instance, Findings of its validation and Evidence captured, reported with the outcome
+	// of the action.
+	Principal string             `json:"principal,omitempty"`
+	Findings  []findings.Finding `json:"findings,omitempty"`
+	Evidence  string             `json:"evidence,omitempty"`
+	// Attempts to enforce the action which failed with a transient error.
+	Attempts int `json:"attempts,omitempty"`
+	// LeasedUntil is the time until which an instance of the service claimed the action to enforce it.
+	LeasedUntil *time.Time `json:"leasedUntil,omitempty"`
+
+	// generation of the stored action when it was listed, which updates are conditional on.
+	generation int64
+}
+
+// Key identifies the pending action of an instance.
+func (a *Action) Key() string {
+	return Key(a.Project, a.Zone, a.Instance)
+}
+
+// Key returns the key of the pending action of the instance.
+func Key(project, zone, instance string) string {
+	return fmt.Sprintf("%s/%s/%s", project, zone, instance)
+}
+
+// Store persists pending actions, so that they survive restarts.
+type Store interface {
+	// Put stores the action, replacing the pending action of the same instance.
+	Put(ctx context.Context, action *Action) error
+	// Delete removes the pending action with the key. It returns ErrNotFound when there is none.
+	Delete(ctx context.Context, key string) error
+	// List returns all pending actions.
+	List(ctx context.Context) ([]*Action, error)
+	// Update stores the listed action, unless it was changed or deleted since, in which case it returns ErrConflict.
+	Update(ctx context.Context, action *Action) error
+}
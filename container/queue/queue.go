@@ -0,0 +1,188 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var (
+	ErrFull   = errors.New("queue is full")
+	ErrClosed = errors.New("queue is closed")
+)
+
+type Job struct {
+	// Key groups jobs which share capacity, jobs of different keys are served round-robin.
+	Key string
+	Run func(ctx context.Context)
+	// Drop is called instead of Run when the job is still waiting for a worker once Shutdown gives up. Optional.
+	Drop func()
+}
+
+// Queue runs jobs on a fixed number of workers. It bounds the number of waiting jobs, in total and per key,
+// and serves the keys fairly, so that a burst of jobs for one key does not delay the others.
+type Queue struct {
+	workers   int
+	size      int
+	maxPerKey int
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	pending map[string][]Job
+	keys    []string
+	len     int
+	closed  bool
+
+	// cancel cancels the context of the running jobs, once Shutdown gives up.
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func New(workers, size, maxPerKey int) *Queue {
+	q := &Queue{
+		workers:   workers,
+		size:      size,
+		maxPerKey: maxPerKey,
+		pending:   map[string][]Job{},
+	}
+	q.cond = sync.NewCond(&q.mu)
+
+	return q
+}
+
+// Start starts the workers. Jobs are run with a context derived from ctx, which should outlive Shutdown for the jobs to
+// complete, and which is cancelled when Shutdown gives up.
+func (q *Queue) Start(ctx context.Context) {
+	ctx, q.cancel = context.WithCancel(ctx)
+	for range q.workers {
+		q.wg.Add(1)
+		go func() {
+			defer q.wg.Done()
+			q.work(ctx)
+		}()
+	}
+}
+
+func (q *Queue) Enqueue(job Job) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return ErrClosed
+	}
+
+	if q.len >= q.size {
+		return ErrFull
+	}
+
+	jobs, found := q.pending[job.Key]
+	if q.maxPerKey > 0 && len(jobs) >= q.maxPerKey {
+		return ErrFull
+	}
+	if !found {
+		q.keys = append(q.keys, job.Key)
+	}
+
+	q.pending[job.Key] = append(jobs, job)
+	q.len++
+	q.cond.Signal()
+
+	return nil
+}
+
+// Len returns the number of jobs waiting for a worker.
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.len
+}
+
+// Shutdown stops accepting jobs and waits until the workers have run all queued jobs, or ctx is done. When ctx is done
+// first, the jobs still waiting for a worker are dropped, and the context of the running jobs is cancelled. Shutdown
+// returns once the running jobs returned, so they must return promptly when their context is cancelled.
+func (q *Queue) Shutdown(ctx context.Context) error {
+	q.mu.Lock()
+	q.closed = true
+	q.cond.Broadcast()
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	for _, job := range q.drain() {
+		if job.Drop != nil {
+			job.Drop()
+		}
+	}
+	if q.cancel != nil {
+		q.cancel()
+	}
+	<-done
+
+	return ctx.Err()
+}
+
+// drain removes the jobs waiting for a worker.
+func (q *Queue) drain() []Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var jobs []Job
+	for _, key := range q.keys {
+		jobs = append(jobs, q.pending[key]...)
+	}
+	q.pending = map[string][]Job{}
+	q.keys = nil
+	q.len = 0
+
+	return jobs
+}
+
+func (q *Queue) work(ctx context.Context) {
+	for {
+		job, ok := q.next()
+		if !ok {
+			return
+		}
+
+		job.Run(ctx)
+	}
+}
+
+func (q *Queue) next() (Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for q.len == 0 && !q.closed {
+		q.cond.Wait()
+	}
+
+	if q.len == 0 {
+		return Job{}, false
+	}
+
+	key := q.keys[0]
+	q.keys = q.keys[1:]
+
+	jobs := q.pending[key]
+	job := jobs[0]
+	if len(jobs) > 1 {
+		q.pending[key] = jobs[1:]
+		q.keys = append(q.keys, key)
+	} else {
+		delete(q.pending, key)
+	}
+	q.len--
+
+	return job, true
+}
@@ -0,0 +1,113 @@
+package queue_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/castai/gcp-node-validator/container/queue"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueueServesKeysRoundRobin(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+
+	q := queue.New(1, 10, 0)
+
+	var mu sync.Mutex
+	var order []string
+	record := func(name string) func(context.Context) {
+		return func(context.Context) {
+			mu.Lock()
+			defer mu.Unlock()
+			order = append(order, name)
+		}
+	}
+
+	r.NoError(q.Enqueue(queue.Job{Key: "a", Run: record("a1")}))
+	r.NoError(q.Enqueue(queue.Job{Key: "a", Run: record("a2")}))
+	r.NoError(q.Enqueue(queue.Job{Key: "a", Run: record("a3")}))
+	r.NoError(q.Enqueue(queue.Job{Key: "b", Run: record("b1")}))
+	r.NoError(q.Enqueue(queue.Job{Key: "c", Run: record("c1")}))
+	r.NoError(q.Enqueue(queue.Job{Key: "b", Run: record("b2")}))
+
+	q.Start(context.Background())
+	r.NoError(q.Shutdown(context.Background()))
+
+	r.Equal([]string{"a1", "b1", "c1", "a2", "b2", "a3"}, order)
+}
+
+func TestQueueBackpressure(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+
+	q := queue.New(1, 3, 2)
+	noop := func(context.Context) {}
+
+	r.NoError(q.Enqueue(queue.Job{Key: "a", Run: noop}))
+	r.NoError(q.Enqueue(queue.Job{Key: "a", Run: noop}))
+	r.ErrorIs(q.Enqueue(queue.Job{Key: "a", Run: noop}), queue.ErrFull, "per key limit must apply")
+	r.NoError(q.Enqueue(queue.Job{Key: "b", Run: noop}))
+	r.ErrorIs(q.Enqueue(queue.Job{Key: "c", Run: noop}), queue.ErrFull, "total limit must apply")
+	r.Equal(3, q.Len())
+}
+
+func TestQueueShutdownDrainsJobs(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+
+	q := queue.New(2, 10, 0)
+	q.Start(context.Background())
+
+	var mu sync.Mutex
+	done := 0
+	for range 5 {
+		r.NoError(q.Enqueue(queue.Job{Key: "a", Run: func(context.Context) {
+			time.Sleep(10 * time.Millisecond)
+			mu.Lock()
+			defer mu.Unlock()
+			done++
+		}}))
+	}
+
+	r.NoError(q.Shutdown(context.Background()))
+	r.Equal(5, done)
+	r.ErrorIs(q.Enqueue(queue.Job{Key: "a", Run: func(context.Context) {}}), queue.ErrClosed)
+}
+
+func TestQueueShutdownTimeout(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+
+	q := queue.New(1, 10, 0)
+	q.Start(context.Background())
+
+	// The running job is interrupted.
+	started, interrupted := make(chan struct{}), false
+	r.NoError(q.Enqueue(queue.Job{Key: "a", Run: func(ctx context.Context) {
+		close(started)
+		<-ctx.Done()
+		interrupted = true
+	}}))
+	<-started
+
+	// Jobs waiting for the worker are dropped.
+	var dropped []string
+	for _, key := range []string{"a", "b"} {
+		r.NoError(q.Enqueue(queue.Job{
+			Key:  key,
+			Run:  func(context.Context) { t.Errorf("dropped job %s must not run", key) },
+			Drop: func() { dropped = append(dropped, key) },
+		}))
+	}
+	r.Eventually(func() bool { return q.Len() == 2 }, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	r.ErrorIs(q.Shutdown(ctx), context.DeadlineExceeded)
+	r.ElementsMatch([]string{"a", "b"}, dropped)
+	r.Zero(q.Len())
+	r.True(interrupted, "shutdown returns once the running jobs returned")
+}
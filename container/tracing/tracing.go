@@ -0,0 +1,97 @@
+// Package tracing traces the validation pipeline with OpenTelemetry.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/castai/gcp-node-validator/container"
+
+// cloudEventTraceParentHeader carries the W3C trace context in the CloudEvents distributed tracing extension.
+const cloudEventTraceParentHeader = "Ce-Traceparent"
+
+// Exporter configures the OTLP exporter.
+type Exporter struct {
+	// Endpoint of the OTLP gRPC receiver, as host:port. Spans are not exported when empty.
+	Endpoint string
+	// Insecure disables TLS towards the Endpoint.
+	Insecure bool
+	// SampleRatio of traces started by the service. Traces propagated from Eventarc follow the sampling decision of
+	// the caller.
+	SampleRatio float64
+	ServiceName string
+}
+
+// Setup registers the W3C trace context propagator and, when the exporter has an endpoint, a tracer provider
+// exporting spans to it. The returned function flushes and stops the tracer provider.
+func Setup(ctx context.Context, exporter Exporter) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	if exporter.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(exporter.Endpoint)}
+	if exporter.Insecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+
+	spanExporter, err := otlptracegrpc.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(exporter.ServiceName),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(spanExporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(exporter.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// Extract returns the context with the trace context of the request. Eventarc sends it in the traceparent header,
+// or in the ce-traceparent header of the CloudEvents distributed tracing extension.
+func Extract(ctx context.Context, header http.Header) context.Context {
+	if header.Get("Traceparent") == "" && header.Get(cloudEventTraceParentHeader) != "" {
+		header = header.Clone()
+		header.Set("Traceparent", header.Get(cloudEventTraceParentHeader))
+	}
+
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(header))
+}
+
+// Start starts a span of the step.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End ends the span, recording the error the step failed with.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,84 @@
+package tracing_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/castai/gcp-node-validator/container/tracing"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	traceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	traceID     = "4bf92f3577b34da6a3ce929d0e0e4736"
+)
+
+func TestExtract(t *testing.T) {
+	_, err := tracing.Setup(context.Background(), tracing.Exporter{})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		header      http.Header
+		wantTraceID string
+	}{
+		{
+			name:        "traceparent",
+			header:      http.Header{"Traceparent": []string{traceParent}},
+			wantTraceID: traceID,
+		},
+		{
+			name:        "CloudEvents distributed tracing extension",
+			header:      http.Header{"Ce-Traceparent": []string{traceParent}},
+			wantTraceID: traceID,
+		},
+		{
+			name:   "no trace context",
+			header: http.Header{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := require.New(t)
+
+			spanContext := trace.SpanContextFromContext(tracing.Extract(context.Background(), tt.header))
+			if tt.wantTraceID == "" {
+				r.False(spanContext.IsValid())
+				return
+			}
+			r.Equal(tt.wantTraceID, spanContext.TraceID().String())
+			r.True(spanContext.IsRemote())
+		})
+	}
+}
+
+func TestStartAndEnd(t *testing.T) {
+	r := require.New(t)
+
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(trace.NewNoopTracerProvider()) })
+
+	ctx := tracing.Extract(context.Background(), http.Header{"Traceparent": []string{traceParent}})
+	ctx, parent := tracing.Start(ctx, "parent")
+	_, child := tracing.Start(ctx, "child")
+	tracing.End(child, errors.New("not found"))
+	tracing.End(parent, nil)
+
+	spans := recorder.Ended()
+	r.Len(spans, 2)
+	r.Equal("child", spans[0].Name())
+	r.Equal(codes.Error, spans[0].Status().Code)
+	r.Equal("not found", spans[0].Status().Description)
+	r.Equal(spans[1].SpanContext().SpanID(), spans[0].Parent().SpanID())
+	r.Equal(traceID, spans[1].SpanContext().TraceID().String(), "the trace of Eventarc is continued")
+	r.Equal(codes.Unset, spans[1].Status().Code)
+}
@@ -5,12 +5,16 @@ import (
 	"encoding/base64"
 	"fmt"
 	"io"
+	"strconv"
 	"time"
 
 	"cloud.google.com/go/compute/apiv1/computepb"
 	"cloud.google.com/go/storage"
+	"github.com/castai/gcp-node-validator/container/metrics"
+	"github.com/castai/gcp-node-validator/container/tracing"
 	"github.com/patrickmn/go-cache"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
 	"google.golang.org/api/iterator"
 )
 
@@ -33,7 +37,14 @@ func NewCloudStorageWhitelistGetter(bucketName string, gcsc *storage.Client, obj
 	}, nil
 }
 
-func (c *CloudStorageWhitelistGetter) GetWhitelist(ctx context.Context, i *computepb.Instance) ([]string, error) {
+func (c *CloudStorageWhitelistGetter) Name() string {
+	return "cloud_storage"
+}
+
+func (c *CloudStorageWhitelistGetter) GetWhitelist(ctx context.Context, i *computepb.Instance) (_ []string, err error) {
+	ctx, span := tracing.Start(ctx, "CloudStorageWhitelistGetter.GetWhitelist", attribute.String("bucket", c.bucketName))
+	defer func() { tracing.End(span, err) }()
+
 	objIterator := c.gcpCloudStorageClient.Bucket(c.bucketName).Objects(ctx, &storage.Query{
 		Prefix: c.objectPrefix,
 	})
@@ -47,13 +58,14 @@ func (c *CloudStorageWhitelistGetter) GetWhitelist(ctx context.Context, i *compu
 		}
 
 		if err != nil {
-			return nil, fmt.Errorf("failed to get object: %v", err)
+			return nil, fmt.Errorf("failed to get object: %w", err)
 		}
 
 		log := logrus.WithField("objectName", attrs.Name)
 
 		cacheKey := fmt.Sprintf("%s:%s", attrs.Name, base64.StdEncoding.EncodeToString(attrs.MD5))
 		cachedObj, found := c.objCache.Get(cacheKey)
+		metrics.WhitelistCacheRequests.WithLabelValues(metrics.CacheResult(found)).Inc()
 		if found {
 			cachedObjData, ok := cachedObj.([]byte)
 			if ok {
@@ -72,15 +84,9 @@ func (c *CloudStorageWhitelistGetter) GetWhitelist(ctx context.Context, i *compu
 			continue
 		}
 
-		reader, err := c.gcpCloudStorageClient.Bucket(c.bucketName).Object(attrs.Name).NewReader(ctx)
+		data, err := c.readObject(ctx, attrs.Name)
 		if err != nil {
-			return nil, fmt.Errorf("failed to get object reader: %v", err)
-		}
-		defer reader.Close()
-
-		data, err := io.ReadAll(reader)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read object: %v", err)
+			return nil, fmt.Errorf("failed to read object: %w", err)
 		}
 
 		c.objCache.Set(cacheKey, data, cache.DefaultExpiration)
@@ -90,3 +96,61 @@ func (c *CloudStorageWhitelistGetter) GetWhitelist(ctx context.Context, i *compu
 
 	return whitelist, nil
 }
+
+// Load reads the whitelist objects of the bucket into the cache, failing when the bucket or an object is not readable.
+func (c *CloudStorageWhitelistGetter) Load(ctx context.Context) error {
+	_, err := c.GetWhitelist(ctx, nil)
+	return err
+}
+
+func (c *CloudStorageWhitelistGetter) readObject(ctx context.Context, name string) (data []byte, err error) {
+	ctx, span := tracing.Start(ctx, "ReadObject", attribute.String("object", name))
+	start := time.Now()
+	defer func() {
+		metrics.ObserveGCPCall("read_whitelist_object", start, err)
+		tracing.End(span, err)
+	}()
+
+	reader, err := c.gcpCloudStorageClient.Bucket(c.bucketName).Object(name).NewReader(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object reader: %w", err)
+	}
+	defer reader.Close()
+
+	return io.ReadAll(reader)
+}
+
+// SourceVersion is a version of a whitelist source.
+type SourceVersion struct {
+	Source  string    `json:"source"`
+	Name    string    `json:"name"`
+	Version string    `json:"version"`
+	Updated time.Time `json:"updated"`
+	Size    int64     `json:"size"`
+}
+
+// Versions returns the generations of the whitelist objects in the bucket.
+func (c *CloudStorageWhitelistGetter) Versions(ctx context.Context) ([]SourceVersion, error) {
+	objIterator := c.gcpCloudStorageClient.Bucket(c.bucketName).Objects(ctx, &storage.Query{
+		Prefix: c.objectPrefix,
+	})
+
+	var versions []SourceVersion
+	for {
+		attrs, err := objIterator.Next()
+		if err == iterator.Done {
+			return versions, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list whitelist objects: %w", err)
+		}
+
+		versions = append(versions, SourceVersion{
+			Source:  fmt.Sprintf("gs://%s", c.bucketName),
+			Name:    attrs.Name,
+			Version: strconv.FormatInt(attrs.Generation, 10),
+			Updated: attrs.Updated,
+			Size:    attrs.Size,
+		})
+	}
+}
@@ -2,21 +2,32 @@ package validate
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"regexp"
+	"time"
 
 	compute "cloud.google.com/go/compute/apiv1"
 	"cloud.google.com/go/compute/apiv1/computepb"
 	container "cloud.google.com/go/container/apiv1"
 	"cloud.google.com/go/container/apiv1/containerpb"
+	"github.com/castai/gcp-node-validator/container/metrics"
+	"github.com/castai/gcp-node-validator/container/tracing"
 )
 
 var (
-	instanceSelfLinkRegexp             = regexp.MustCompile(`https:\/\/www\.googleapis\.com\/compute\/v1\/projects\/(.+?)\/zones\/(.+?)\/instances\/(.+)`)
-	instanceGroupManagerSelfLinkRegexp = regexp.MustCompile(`https:\/\/www\.googleapis\.com\/compute\/v1\/projects\/(.+?)\/zones\/(.+?)\/instanceGroupManagers\/(.+)`)
-	instanceTemplateSelfLinkRegexp     = regexp.MustCompile(`https:\/\/www\.googleapis\.com\/compute\/v1\/projects\/(.+?)\/regions\/(.+?)\/instanceTemplates\/(.+)`)
+	instanceSelfLinkRegexp              = regexp.MustCompile(`https:\/\/www\.googleapis\.com\/compute\/v1\/projects\/(.+?)\/zones\/(.+?)\/instances\/(.+)`)
+	instanceGroupManagerSelfLinkRegexp  = regexp.MustCompile(`https:\/\/www\.googleapis\.com\/compute\/v1\/projects\/(.+?)\/zones\/(.+?)\/instanceGroupManagers\/(.+)`)
+	instanceTemplateSelfLinkRegexp      = regexp.MustCompile(`https:\/\/www\.googleapis\.com\/compute\/v1\/projects\/(.+?)\/regions\/(.+?)\/instanceTemplates\/(.+)`)
+	createdByInstanceGroupManagerRegexp = regexp.MustCompile(`^projects\/[^/]+\/zones\/([^/]+)\/instanceGroupManagers\/([^/]+)$`)
 )
 
+// MetadataCreatedByKey is set by Compute Engine to the instance group manager which created the instance.
+const MetadataCreatedByKey = "created-by"
+
+// ErrNotManaged is returned for instances which are not managed by an instance group.
+var ErrNotManaged = errors.New("instance not managed by the instance group of its node pool")
+
 type InstanceTemplateWhitelistProvider struct {
 	gcpClusterClient               *container.ClusterManagerClient
 	gcpInstanceGroupManagersClient *compute.InstanceGroupManagersClient
@@ -32,7 +43,14 @@ func NewInstanceTemplateWhitelistProvider(cmc *container.ClusterManagerClient, i
 	}, nil
 }
 
-func (c *InstanceTemplateWhitelistProvider) GetWhitelist(ctx context.Context, instance *computepb.Instance) ([]string, error) {
+func (c *InstanceTemplateWhitelistProvider) Name() string {
+	return "instance_template"
+}
+
+func (c *InstanceTemplateWhitelistProvider) GetWhitelist(ctx context.Context, instance *computepb.Instance) (whitelist []string, err error) {
+	ctx, span := tracing.Start(ctx, "InstanceTemplateWhitelistProvider.GetWhitelist")
+	defer func() { tracing.End(span, err) }()
+
 	instanceTemplate, err := c.getInstanceTemplate(ctx, instance)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get instance template: %w", err)
@@ -52,6 +70,75 @@ func (c *InstanceTemplateWhitelistProvider) GetWhitelist(ctx context.Context, in
 }
 
 func (c *InstanceTemplateWhitelistProvider) getInstanceTemplate(ctx context.Context, instance *computepb.Instance) (*computepb.InstanceTemplate, error) {
+	img, err := c.getInstanceGroupManager(ctx, instance)
+	if err != nil {
+		return nil, err
+	}
+
+	_, _, instanceTemplateName, err := parseInstanceTemplateSelfLink(img.GetInstanceTemplate())
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse instance template self link: %w", err)
+	}
+
+	projectID, zone, _, err := parseInstanceSelfLink(instance.GetSelfLink())
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse instance self link: %w", err)
+	}
+
+	spanCtx, span := tracing.Start(ctx, "GetInstanceTemplate")
+	start := time.Now()
+	instanceTemplate, err := c.gcpInstanceTemplateClient.Get(spanCtx, &computepb.GetRegionInstanceTemplateRequest{
+		Project:          projectID,
+		Region:           parseLocationFromZone(zone),
+		InstanceTemplate: instanceTemplateName,
+	})
+	metrics.ObserveGCPCall("get_instance_template", start, err)
+	tracing.End(span, err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get instance template: %w", err)
+	}
+
+	return instanceTemplate, nil
+}
+
+// GetInstanceGroupManager returns the instance group manager which created the instance, named by its created-by
+// metadata. ErrNotManaged is returned without calling the API when the instance was not created by a group, as CAST
+// instances are not.
+func (c *InstanceTemplateWhitelistProvider) GetInstanceGroupManager(ctx context.Context, instance *computepb.Instance) (*computepb.InstanceGroupManager, error) {
+	createdBy, err := findMetadata(instance.GetMetadata(), MetadataCreatedByKey)
+	if err != nil {
+		return nil, ErrNotManaged
+	}
+
+	matches := createdByInstanceGroupManagerRegexp.FindStringSubmatch(createdBy)
+	if matches == nil {
+		return nil, ErrNotManaged
+	}
+	zone, name := matches[1], matches[2]
+
+	// created-by refers to the project by number, the group is read in the project of the instance.
+	projectID, _, _, err := parseInstanceSelfLink(instance.GetSelfLink())
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse instance self link: %w", err)
+	}
+
+	spanCtx, span := tracing.Start(ctx, "GetInstanceGroupManager")
+	start := time.Now()
+	img, err := c.gcpInstanceGroupManagersClient.Get(spanCtx, &computepb.GetInstanceGroupManagerRequest{
+		Project:              projectID,
+		Zone:                 zone,
+		InstanceGroupManager: name,
+	})
+	metrics.ObserveGCPCall("get_instance_group_manager", start, err)
+	tracing.End(span, err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get instance group manager: %w", err)
+	}
+
+	return img, nil
+}
+
+func (c *InstanceTemplateWhitelistProvider) getInstanceGroupManager(ctx context.Context, instance *computepb.Instance) (*computepb.InstanceGroupManager, error) {
 	clusterName, found := instance.Labels["goog-k8s-cluster-name"]
 	if !found {
 		return nil, fmt.Errorf("cluster name not found")
@@ -70,9 +157,13 @@ func (c *InstanceTemplateWhitelistProvider) getInstanceTemplate(ctx context.Cont
 
 	nodePoolURI := fmt.Sprintf("projects/%s/locations/%s/clusters/%s/nodePools/%s", projectID, location, clusterName, nodePoolName)
 
-	np, err := c.gcpClusterClient.GetNodePool(ctx, &containerpb.GetNodePoolRequest{
+	spanCtx, span := tracing.Start(ctx, "GetNodePool")
+	start := time.Now()
+	np, err := c.gcpClusterClient.GetNodePool(spanCtx, &containerpb.GetNodePoolRequest{
 		Name: nodePoolURI,
 	})
+	metrics.ObserveGCPCall("get_node_pool", start, err)
+	tracing.End(span, err)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get node pool: %w", err)
 	}
@@ -87,30 +178,20 @@ func (c *InstanceTemplateWhitelistProvider) getInstanceTemplate(ctx context.Cont
 		return nil, fmt.Errorf("failed to parse instance group manager self link: %w", err)
 	}
 
-	img, err := c.gcpInstanceGroupManagersClient.Get(ctx, &computepb.GetInstanceGroupManagerRequest{
+	spanCtx, span = tracing.Start(ctx, "GetInstanceGroupManager")
+	start = time.Now()
+	img, err := c.gcpInstanceGroupManagersClient.Get(spanCtx, &computepb.GetInstanceGroupManagerRequest{
 		Project:              projectID,
 		Zone:                 zone,
 		InstanceGroupManager: instanceGroupName,
 	})
+	metrics.ObserveGCPCall("get_instance_group_manager", start, err)
+	tracing.End(span, err)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get instance group manager: %w", err)
 	}
 
-	_, _, instanceTemplateName, err := parseInstanceTemplateSelfLink(img.GetInstanceTemplate())
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse instance template self link: %w", err)
-	}
-
-	instanceTemplate, err := c.gcpInstanceTemplateClient.Get(ctx, &computepb.GetRegionInstanceTemplateRequest{
-		Project:          projectID,
-		Region:           location,
-		InstanceTemplate: instanceTemplateName,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to get instance template: %w", err)
-	}
-
-	return instanceTemplate, nil
+	return img, nil
 }
 
 func findInstanceGroupUrlForZone(instanceGroupUrls []string, zone string) (string, error) {
@@ -0,0 +1,101 @@
+package validate_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/castai/gcp-node-validator/container/internal/testutil"
+	"github.com/castai/gcp-node-validator/container/validate"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/option"
+)
+
+func TestGetInstanceGroupManager(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		createdBy string
+		status    int
+		wantErr   error
+		wantGroup string
+		wantReads int
+	}{
+		{
+			name:    "CAST instance of a node pool is not managed without reading its pool",
+			wantErr: validate.ErrNotManaged,
+		},
+		{
+			name:      "instance not created by a group",
+			createdBy: "projects/123/zones/z/instances/creator",
+			wantErr:   validate.ErrNotManaged,
+		},
+		{
+			name:      "group named by created-by",
+			createdBy: "projects/123/zones/z/instanceGroupManagers/gke-cluster-pool-1234abcd-grp",
+			wantGroup: "gke-cluster-pool-1234abcd-grp",
+			wantReads: 1,
+		},
+		{
+			name:      "group lookup error",
+			createdBy: "projects/123/zones/z/instanceGroupManagers/gke-cluster-pool-1234abcd-grp",
+			status:    http.StatusForbidden,
+			wantReads: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			r := require.New(t)
+
+			var reads atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				reads.Add(1)
+				r.Equal("/compute/v1/projects/p/zones/z/instanceGroupManagers/gke-cluster-pool-1234abcd-grp", req.URL.Path)
+				if tt.status != 0 {
+					http.Error(w, `{"error": {"code": 403, "message": "forbidden"}}`, tt.status)
+					return
+				}
+				_, _ = w.Write([]byte(`{"name": "gke-cluster-pool-1234abcd-grp"}`))
+			}))
+			t.Cleanup(srv.Close)
+
+			groups, err := compute.NewInstanceGroupManagersRESTClient(context.Background(), option.WithEndpoint(srv.URL), option.WithoutAuthentication())
+			r.NoError(err)
+			t.Cleanup(func() { _ = groups.Close() })
+
+			// Without a cluster client, reading the node pool would panic.
+			provider, err := validate.NewInstanceTemplateWhitelistProvider(nil, groups, nil)
+			r.NoError(err)
+
+			instance := testutil.NewInstance()
+			instance.SelfLink = lo.ToPtr("https://www.googleapis.com/compute/v1/projects/p/zones/z/instances/" + instance.GetName())
+			if tt.createdBy != "" {
+				instance.Metadata.Items = append(instance.Metadata.Items, &computepb.Items{
+					Key:   lo.ToPtr(validate.MetadataCreatedByKey),
+					Value: lo.ToPtr(tt.createdBy),
+				})
+			}
+
+			img, err := provider.GetInstanceGroupManager(context.Background(), instance)
+			r.Equal(tt.wantReads, int(reads.Load()))
+			switch {
+			case tt.wantErr != nil:
+				r.ErrorIs(err, tt.wantErr)
+			case tt.wantGroup == "":
+				r.Error(err)
+				r.NotErrorIs(err, validate.ErrNotManaged, "lookup errors must not be taken for unmanaged instances")
+			default:
+				r.NoError(err)
+				r.Equal(tt.wantGroup, img.GetName())
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package validate
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// AnyCluster is the PrincipalAllowList key applying to clusters without their own entry.
+const AnyCluster = "*"
+
+// PrincipalAllowList maps CAST cluster IDs to the principals allowed to create instances of the cluster.
+type PrincipalAllowList map[string][]string
+
+// Decode parses the allow list from the "cluster=principal|principal;*=principal" format.
+func (l *PrincipalAllowList) Decode(value string) error {
+	list := PrincipalAllowList{}
+
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		clusterID, principals, found := strings.Cut(entry, "=")
+		if !found || strings.TrimSpace(clusterID) == "" {
+			return fmt.Errorf("invalid principal allow list entry %q", entry)
+		}
+
+		for _, principal := range strings.Split(principals, "|") {
+			if principal = strings.TrimSpace(principal); principal != "" {
+				list[strings.TrimSpace(clusterID)] = append(list[strings.TrimSpace(clusterID)], principal)
+			}
+		}
+	}
+
+	*l = list
+	return nil
+}
+
+// Principals returns the principals allowed to create instances of the cluster, or nil when any principal is allowed.
+func (l PrincipalAllowList) Principals(clusterID string) []string {
+	if principals, found := l[clusterID]; found {
+		return principals
+	}
+
+	return l[AnyCluster]
+}
+
+// Allowed reports whether the principal is allowed to create instances of the cluster.
+func (l PrincipalAllowList) Allowed(clusterID, principal string) bool {
+	principals := l.Principals(clusterID)
+	if len(principals) == 0 {
+		return true
+	}
+
+	return slices.Contains(principals, principal)
+}
+
+// PrincipalError is returned for instances created by a principal which is not allowed for the cluster.
+type PrincipalError struct {
+	Principal string
+}
+
+func (e *PrincipalError) Error() string {
+	if e.Principal == "" {
+		return "instance created by unknown principal"
+	}
+	return fmt.Sprintf("instance created by unexpected principal %s", e.Principal)
+}
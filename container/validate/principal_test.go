@@ -0,0 +1,96 @@
+package validate_test
+
+import (
+	"context"
+	"testing"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/castai/gcp-node-validator/container/validate"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrincipalAllowListDecode(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+
+	var list validate.PrincipalAllowList
+	r.NoError(list.Decode("c1=a@example.com|b@example.com; *=cast@example.com;"))
+
+	r.Equal(validate.PrincipalAllowList{
+		"c1": {"a@example.com", "b@example.com"},
+		"*":  {"cast@example.com"},
+	}, list)
+	r.True(list.Allowed("c1", "b@example.com"))
+	r.False(list.Allowed("c1", "cast@example.com"))
+	r.True(list.Allowed("c2", "cast@example.com"))
+	r.False(list.Allowed("c2", "a@example.com"))
+
+	r.Error(list.Decode("a@example.com"))
+}
+
+func TestInstanceValidatorValidatePrincipal(t *testing.T) {
+	t.Parallel()
+
+	instance := &computepb.Instance{
+		Labels: map[string]string{"cast-cluster-id": "c1"},
+		Metadata: &computepb.Metadata{
+			Items: []*computepb.Items{
+				{Key: lo.ToPtr("configure-sh"), Value: lo.ToPtr("echo 'hello world'")},
+				{Key: lo.ToPtr("user-data"), Value: lo.ToPtr("echo 'hello world'")},
+			},
+		},
+	}
+	provider := &mockWhitelistProvider{whitelist: []string{"echo 'hello world'"}}
+
+	tests := []struct {
+		name      string
+		allowList validate.PrincipalAllowList
+		principal string
+		err       error
+	}{
+		{
+			name:      "no allow list",
+			principal: "someone@example.com",
+		},
+		{
+			name:      "allowed principal",
+			allowList: validate.PrincipalAllowList{"c1": {"cast@example.com"}},
+			principal: "cast@example.com",
+		},
+		{
+			name:      "unexpected principal",
+			allowList: validate.PrincipalAllowList{"c1": {"cast@example.com"}},
+			principal: "someone@example.com",
+			err:       &validate.PrincipalError{Principal: "someone@example.com"},
+		},
+		{
+			name:      "unexpected principal of any cluster",
+			allowList: validate.PrincipalAllowList{"*": {"cast@example.com"}},
+			principal: "",
+			err:       &validate.PrincipalError{},
+		},
+		{
+			name:      "other cluster allow list",
+			allowList: validate.PrincipalAllowList{"c2": {"cast@example.com"}},
+			principal: "someone@example.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := require.New(t)
+			v := validate.NewInstanceValidator(tt.allowList, provider)
+
+			err := v.Validate(context.Background(), instance, tt.principal)
+
+			if tt.err != nil {
+				var principalErr *validate.PrincipalError
+				r.ErrorAs(err, &principalErr)
+				r.Equal(tt.err, principalErr)
+			} else {
+				r.NoError(err)
+			}
+		})
+	}
+}
@@ -6,14 +6,24 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"sync"
 
 	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/castai/gcp-node-validator/container/metrics"
 )
 
 type WhitelistProvider interface {
 	GetWhitelist(ctx context.Context, instance *computepb.Instance) ([]string, error)
 }
 
+// providerName identifies the provider in metrics, by its Name method when it has one.
+func providerName(provider WhitelistProvider) string {
+	if named, ok := provider.(interface{ Name() string }); ok {
+		return named.Name()
+	}
+	return fmt.Sprintf("%T", provider)
+}
+
 type scriptPreprocessor interface {
 	Apply(string) string
 }
@@ -44,8 +54,24 @@ var configureShPreprocessors = []scriptPreprocessor{
 	NewRegexReplacement(`https://.+?/v1/kubernetes/external-clusters/.+?/nodes/.+?/logs`, `https://****/v1/kubernetes/external-clusters/****/nodes/****/logs`),
 }
 
+// Verdict is the outcome of validating an instance.
+type Verdict string
+
+const (
+	VerdictValid   Verdict = "valid"
+	VerdictInvalid Verdict = "invalid"
+	// VerdictUnverifiable is given to instances which could not be validated, e.g. when the instance
+	// could not be read or a whitelist could not be resolved.
+	VerdictUnverifiable Verdict = "unverifiable"
+)
+
 type ValidationError struct {
+	// MetadataKey is the key of the metadata item which failed validation.
+	MetadataKey     string
 	UnknownCommands string
+	// MatchedWhitelist and UnmatchedWhitelist are the whitelist entries which were, and were not, found in the metadata item.
+	MatchedWhitelist   []string
+	UnmatchedWhitelist []string
 }
 
 func (e *ValidationError) Error() string {
@@ -61,21 +87,57 @@ const (
 	MetadataUserDataKey    = "user-data"
 )
 
+const castClusterIDLabel = "cast-cluster-id"
+
 type InstanceValidator struct {
 	providers []WhitelistProvider
+
+	mu                sync.RWMutex
+	allowedPrincipals PrincipalAllowList
 }
 
-func NewInstanceValidator(providers ...WhitelistProvider) *InstanceValidator {
+func NewInstanceValidator(allowedPrincipals PrincipalAllowList, providers ...WhitelistProvider) *InstanceValidator {
 	return &InstanceValidator{
-		providers: providers,
+		providers:         providers,
+		allowedPrincipals: allowedPrincipals,
 	}
 }
 
-func (v *InstanceValidator) Validate(ctx context.Context, i *computepb.Instance) error {
+// SetAllowedPrincipals replaces the principals allowed to create instances, e.g. when the configuration is reloaded.
+func (v *InstanceValidator) SetAllowedPrincipals(allowedPrincipals PrincipalAllowList) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.allowedPrincipals = allowedPrincipals
+}
+
+func (v *InstanceValidator) principals() PrincipalAllowList {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.allowedPrincipals
+}
+
+// Validate validates the instance created by the principal. Instances created by principals which are not
+// allowed fail with PrincipalError, even when their scripts are whitelisted.
+func (v *InstanceValidator) Validate(ctx context.Context, i *computepb.Instance, principal string) error {
+	if !v.principals().Allowed(i.GetLabels()[castClusterIDLabel], principal) {
+		return errors.Join(&PrincipalError{Principal: principal}, v.validateScripts(ctx, i))
+	}
+
+	return v.validateScripts(ctx, i)
+}
+
+// PrincipalAllowed reports whether the principal is allowed to create instances of the cluster.
+func (v *InstanceValidator) PrincipalAllowed(clusterID, principal string) bool {
+	allowedPrincipals := v.principals()
+	return len(allowedPrincipals.Principals(clusterID)) > 0 && allowedPrincipals.Allowed(clusterID, principal)
+}
+
+func (v *InstanceValidator) validateScripts(ctx context.Context, i *computepb.Instance) error {
 	whitelist := []string{}
 	for _, provider := range v.providers {
 		w, err := provider.GetWhitelist(ctx, i)
 		if err != nil {
+			metrics.ProviderErrors.WithLabelValues(providerName(provider)).Inc()
 			return fmt.Errorf("failed to get whitelist: %w", err)
 		}
 
@@ -104,31 +166,47 @@ func (v *InstanceValidator) Validate(ctx context.Context, i *computepb.Instance)
 }
 
 func (v *InstanceValidator) validateConfigureSh(whitelist []string, configureSh string) error {
-	for _, processor := range configureShPreprocessors {
-		configureSh = processor.Apply(configureSh)
-	}
-
-	for _, w := range whitelist {
-		configureSh = strings.ReplaceAll(configureSh, w, "")
-	}
+	return validateScript(MetadataConfigureShKey, whitelist, Redact(configureSh))
+}
 
-	if strings.TrimSpace(configureSh) == "" {
-		return nil
+// Redact replaces the CAST credentials and identifiers of a script with ****, as they are replaced before the
+// configure-sh script is validated.
+func Redact(script string) string {
+	for _, processor := range configureShPreprocessors {
+		script = processor.Apply(script)
 	}
-
-	return &ValidationError{UnknownCommands: configureSh}
+	return script
 }
 
 func (v *InstanceValidator) validateUserData(whitelist []string, userData string) error {
+	return validateScript(MetadataUserDataKey, whitelist, userData)
+}
+
+// validateScript removes the whitelisted parts of the script, failing with ValidationError when anything remains.
+func validateScript(key string, whitelist []string, script string) error {
+	var matched, unmatched []string
+
 	for _, w := range whitelist {
-		userData = strings.ReplaceAll(userData, w, "")
+		remaining := strings.ReplaceAll(script, w, "")
+		if remaining == script {
+			unmatched = append(unmatched, w)
+			continue
+		}
+
+		matched = append(matched, w)
+		script = remaining
 	}
 
-	if strings.TrimSpace(userData) == "" {
+	if strings.TrimSpace(script) == "" {
 		return nil
 	}
 
-	return &ValidationError{UnknownCommands: userData}
+	return &ValidationError{
+		MetadataKey:        key,
+		UnknownCommands:    script,
+		MatchedWhitelist:   matched,
+		UnmatchedWhitelist: unmatched,
+	}
 }
 
 func findMetadata(m *computepb.Metadata, key string) (string, error) {
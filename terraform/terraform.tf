@@ -11,6 +11,50 @@ provider "google" {
 data "google_project" "project" {
 }
 
+locals {
+  protection_mode  = var.protection_mode != "" ? var.protection_mode : (var.delete_mode ? "delete" : (var.quarantine_mode ? "quarantine" : "log"))
+  protection_modes = toset(concat([local.protection_mode], values(var.cluster_protection_modes)))
+  quarantine_mode  = contains(local.protection_modes, "quarantine")
+  disruptive_mode  = length(setintersection(local.protection_modes, ["stop", "suspend", "quarantine", "delete"])) > 0
+
+  # Environment variables of the validator, empty when not set.
+  env = {
+    APP_PROJECTID                  = data.google_project.project.project_id
+    APP_WHITELISTBUCKET_NAME       = google_storage_bucket.main.name
+    APP_PROTECTIONMODE             = local.protection_mode != "log" ? local.protection_mode : ""
+    APP_CLUSTERPROTECTIONMODES     = join(",", [for cluster_id, mode in var.cluster_protection_modes : "${cluster_id}:${mode}"])
+    APP_INSTANCEGROUPMETHOD        = var.instance_group_method != "delete" ? var.instance_group_method : ""
+    APP_QUARANTINETAG              = var.quarantine_tag != "cast-quarantine" ? var.quarantine_tag : ""
+    APP_EVIDENCE_BUCKET            = var.evidence_capture ? google_storage_bucket.evidence[0].name : ""
+    APP_EVIDENCE_SNAPSHOT          = var.evidence_snapshot ? "true" : ""
+    APP_EXCEPTIONS_LIST            = length(var.exceptions) > 0 ? jsonencode(var.exceptions) : ""
+    APP_EXCEPTIONS_BUCKET          = var.exceptions_bucket
+    APP_EXCEPTIONS_OBJECT          = var.exceptions_object != "exceptions.json" ? var.exceptions_object : ""
+    APP_PUBSUB_TOPIC               = var.findings_topic != "" ? google_pubsub_topic.findings[0].name : ""
+    APP_PUBSUB_VALIDRESULTS        = var.findings_valid_results ? "true" : ""
+    APP_NOTIFY_WEBHOOKURL          = var.notification_webhook_url
+    APP_NOTIFY_WEBHOOKSECRET       = var.notification_webhook_secret
+    APP_NOTIFY_SLACKWEBHOOKURL     = var.slack_webhook_url
+    APP_SCC_SOURCE                 = var.scc_source
+    APP_TRACING_ENDPOINT           = var.tracing_endpoint
+    APP_TRACING_SAMPLERATIO        = var.tracing_sample_ratio != 1 ? tostring(var.tracing_sample_ratio) : ""
+    APP_PENDING_DELAY              = var.enforcement_delay != 0 ? tostring(var.enforcement_delay) : ""
+    APP_PENDING_BUCKET             = var.enforcement_delay > 0 ? google_storage_bucket.pending[0].name : ""
+    APP_BREAKER_MAXACTIONS         = var.breaker_max_actions != 20 ? tostring(var.breaker_max_actions) : ""
+    APP_BREAKER_WINDOW             = var.breaker_window != 600 ? tostring(var.breaker_window) : ""
+    APP_BREAKER_MAXINVALIDRATIO    = var.breaker_max_invalid_ratio != 0.5 ? tostring(var.breaker_max_invalid_ratio) : ""
+    APP_BREAKER_BUCKET             = local.disruptive_mode ? google_storage_bucket.breaker[0].name : ""
+    APP_KUBERNETES_DRAIN           = var.kubernetes_drain ? "true" : ""
+    APP_KUBERNETES_EVICTIONTIMEOUT = var.kubernetes_eviction_timeout != 120 ? tostring(var.kubernetes_eviction_timeout) : ""
+    APP_CLUSTERIDS                 = join(",", var.cast_cluster_ids)
+    APP_ADMINS                     = join(",", var.admins)
+    APP_ALLOWEDPRINCIPALS          = join(";", [for cluster_id, principals in var.allowed_principals : "${cluster_id}=${join("|", principals)}"])
+    APP_CONFIGFILE_PATH            = var.config_secret != "" ? "/etc/node-validator/config.yaml" : ""
+    APP_FINDINGSSTORE_DRIVER       = var.findings_store_dsn != "" ? "pgx" : ""
+    APP_FINDINGSSTORE_DSN          = var.findings_store_dsn
+  }
+}
+
 resource "google_service_account" "main" {
   account_id = "${var.name_prefix}-vm-validator-sa"
 }
@@ -22,6 +66,112 @@ resource "google_storage_bucket" "main" {
   public_access_prevention = "enforced"
 }
 
+resource "google_storage_bucket" "evidence" {
+  count = var.evidence_capture ? 1 : 0
+
+  name                        = "${var.name_prefix}-vm-validator-evidence"
+  location                    = "US"
+  force_destroy               = false
+  public_access_prevention    = "enforced"
+  uniform_bucket_level_access = true
+}
+
+resource "google_storage_bucket_iam_member" "evidence_writer" {
+  count = var.evidence_capture ? 1 : 0
+
+  bucket = google_storage_bucket.evidence[0].name
+  role   = "roles/storage.objectCreator"
+  member = "serviceAccount:${google_service_account.main.email}"
+}
+
+resource "google_storage_bucket_iam_member" "exceptions_reader" {
+  count = var.exceptions_bucket != "" ? 1 : 0
+
+  bucket = var.exceptions_bucket
+  role   = "roles/storage.objectViewer"
+  member = "serviceAccount:${google_service_account.main.email}"
+}
+
+# Create GCS bucket persisting actions delayed by the enforcement grace period
+resource "google_storage_bucket" "pending" {
+  count = var.enforcement_delay > 0 ? 1 : 0
+
+  name                        = "${var.name_prefix}-vm-validator-pending"
+  location                    = "US"
+  force_destroy               = true
+  public_access_prevention    = "enforced"
+  uniform_bucket_level_access = true
+}
+
+resource "google_storage_bucket_iam_member" "pending_admin" {
+  count = var.enforcement_delay > 0 ? 1 : 0
+
+  bucket = google_storage_bucket.pending[0].name
+  role   = "roles/storage.objectAdmin"
+  member = "serviceAccount:${google_service_account.main.email}"
+}
+
+# Create GCS bucket persisting the enforcement circuit breaker trips shared by the validator instances
+resource "google_storage_bucket" "breaker" {
+  count = local.disruptive_mode ? 1 : 0
+
+  name                        = "${var.name_prefix}-vm-validator-breaker"
+  location                    = "US"
+  force_destroy               = true
+  public_access_prevention    = "enforced"
+  uniform_bucket_level_access = true
+}
+
+resource "google_storage_bucket_iam_member" "breaker_admin" {
+  count = local.disruptive_mode ? 1 : 0
+
+  bucket = google_storage_bucket.breaker[0].name
+  role   = "roles/storage.objectAdmin"
+  member = "serviceAccount:${google_service_account.main.email}"
+}
+
+# Create Pub/Sub topic receiving validation results
+resource "google_pubsub_topic" "findings" {
+  count = var.findings_topic != "" ? 1 : 0
+
+  name = var.findings_topic
+}
+
+resource "google_pubsub_topic_iam_member" "findings_publisher" {
+  count = var.findings_topic != "" ? 1 : 0
+
+  topic  = google_pubsub_topic.findings[0].name
+  role   = "roles/pubsub.publisher"
+  member = "serviceAccount:${google_service_account.main.email}"
+}
+
+# Grant permission to report Security Command Center findings
+resource "google_scc_source_iam_member" "findings_editor" {
+  count = var.scc_source != "" ? 1 : 0
+
+  organization = split("/", var.scc_source)[1]
+  source       = var.scc_source
+  role         = "roles/securitycenter.findingsEditor"
+  member       = "serviceAccount:${google_service_account.main.email}"
+}
+
+# Grant permission to connect to the Cloud SQL instance of the findings store
+resource "google_project_iam_member" "cloudsql_client" {
+  count = var.findings_store_cloudsql_instance != "" ? 1 : 0
+
+  project = data.google_project.project.id
+  role    = "roles/cloudsql.client"
+  member  = "serviceAccount:${google_service_account.main.email}"
+}
+
+resource "google_secret_manager_secret_iam_member" "config_accessor" {
+  count = var.config_secret != "" ? 1 : 0
+
+  secret_id = var.config_secret
+  role      = "roles/secretmanager.secretAccessor"
+  member    = "serviceAccount:${google_service_account.main.email}"
+}
+
 # Grant permission to receive Eventarc events
 resource "google_project_iam_member" "eventreceiver" {
   project = data.google_project.project.id
@@ -53,7 +203,21 @@ resource "google_project_iam_custom_role" "instance_validator" {
     "container.clusters.get",
     "storage.objects.list",
     "storage.objects.get",
-    var.delete_mode ? "compute.instances.delete" : null,
+    contains(local.protection_modes, "delete") ? "compute.instances.delete" : null,
+    contains(local.protection_modes, "delete") ? "compute.instanceGroupManagers.update" : null,
+    contains(local.protection_modes, "stop") ? "compute.instances.stop" : null,
+    contains(local.protection_modes, "suspend") ? "compute.instances.suspend" : null,
+    local.quarantine_mode ? "compute.instances.setTags" : null,
+    local.quarantine_mode || contains(local.protection_modes, "label") ? "compute.instances.setLabels" : null,
+    local.quarantine_mode ? "compute.instances.setMetadata" : null,
+    local.protection_mode != "log" || length(var.cluster_protection_modes) > 0 ? "compute.zoneOperations.get" : null,
+    var.evidence_capture ? "compute.instances.getSerialPortOutput" : null,
+    var.evidence_capture && var.evidence_snapshot ? "compute.disks.createSnapshot" : null,
+    var.evidence_capture && var.evidence_snapshot ? "compute.snapshots.create" : null,
+    var.kubernetes_drain ? "container.nodes.get" : null,
+    var.kubernetes_drain ? "container.nodes.update" : null,
+    var.kubernetes_drain ? "container.pods.list" : null,
+    var.kubernetes_drain ? "container.pods.evict" : null,
   ])
 }
 
@@ -64,6 +228,39 @@ resource "google_project_iam_member" "instance_validator" {
   member  = "serviceAccount:${google_service_account.main.email}"
 }
 
+# Isolate quarantined instances from the network, which keeps them from joining the cluster
+resource "google_compute_firewall" "quarantine_ingress" {
+  count = local.quarantine_mode ? 1 : 0
+
+  name      = "${var.name_prefix}-quarantine-ingress"
+  network   = var.quarantine_network
+  direction = "INGRESS"
+  priority  = 0
+
+  source_ranges = ["0.0.0.0/0"]
+  target_tags   = [var.quarantine_tag]
+
+  deny {
+    protocol = "all"
+  }
+}
+
+resource "google_compute_firewall" "quarantine_egress" {
+  count = local.quarantine_mode ? 1 : 0
+
+  name      = "${var.name_prefix}-quarantine-egress"
+  network   = var.quarantine_network
+  direction = "EGRESS"
+  priority  = 0
+
+  destination_ranges = ["0.0.0.0/0"]
+  target_tags        = [var.quarantine_tag]
+
+  deny {
+    protocol = "all"
+  }
+}
+
 # Deploy Cloud Run service
 resource "google_cloud_run_v2_service" "default" {
   name     = "${var.name_prefix}-vm-validator"
@@ -74,27 +271,91 @@ resource "google_cloud_run_v2_service" "default" {
   ingress = "INGRESS_TRAFFIC_INTERNAL_ONLY"
 
   template {
+    # Pending actions are enforced by a running instance.
+    scaling {
+      min_instance_count = var.enforcement_delay > 0 ? 1 : 0
+    }
+
     containers {
       image = var.validator_image
-      env {
-        name  = "APP_PROJECTID"
-        value = data.google_project.project.project_id
+      resources {
+        # Audit logs are processed after the request is acknowledged, which needs CPU outside of requests.
+        cpu_idle = false
       }
-      env {
-        name  = "APP_WHITELISTBUCKET_NAME"
-        value = google_storage_bucket.main.name
+      # Inputs left at their default, which is the default of the validator, are not set, so that they do not
+      # override the config file.
+      dynamic "env" {
+        for_each = { for name, value in local.env : name => value if value != "" }
+        content {
+          name  = env.key
+          value = env.value
+        }
       }
-      env {
-        name  = "APP_DELETEINVALID"
-        value = tostring(var.delete_mode)
+
+      dynamic "volume_mounts" {
+        for_each = var.findings_store_cloudsql_instance != "" ? [1] : []
+        content {
+          name       = "cloudsql"
+          mount_path = "/cloudsql"
+        }
+      }
+      dynamic "volume_mounts" {
+        for_each = var.config_secret != "" ? [1] : []
+        content {
+          name       = "config"
+          mount_path = "/etc/node-validator"
+        }
+      }
+
+      # Revisions do not serve until the whitelist bucket, the credentials and the config are checked, so a
+      # misconfigured revision fails to deploy instead of failing to validate instances.
+      startup_probe {
+        initial_delay_seconds = 0
+        timeout_seconds       = 6
+        period_seconds        = 10
+        failure_threshold     = 6
+        http_get {
+          path = "/readyz"
+        }
+      }
+      liveness_probe {
+        timeout_seconds   = 5
+        period_seconds    = 30
+        failure_threshold = 3
+        http_get {
+          path = "/healthz"
+        }
+      }
+    }
+
+    # The Cloud SQL instance of the findings store is reachable through a Unix socket in /cloudsql.
+    dynamic "volumes" {
+      for_each = var.findings_store_cloudsql_instance != "" ? [1] : []
+      content {
+        name = "cloudsql"
+        cloud_sql_instance {
+          instances = [var.findings_store_cloudsql_instance]
+        }
       }
-      env {
-        name  = "APP_CLUSTERIDS"
-        value = join(",", var.cast_cluster_ids)
+    }
+    # The latest version of the config secret is read whenever the validator reads the file, which reloads changes.
+    dynamic "volumes" {
+      for_each = var.config_secret != "" ? [1] : []
+      content {
+        name = "config"
+        secret {
+          secret = var.config_secret
+          items {
+            version = "latest"
+            path    = "config.yaml"
+          }
+        }
       }
     }
     service_account = google_service_account.main.email
   }
+
+  depends_on = [google_secret_manager_secret_iam_member.config_accessor]
 }
 
 resource "google_eventarc_trigger" "instance_insert" {
@@ -134,13 +395,30 @@ resource "google_logging_metric" "invalid_instances" {
   filter      = <<EOF
 resource.type = cloud_run_revision
   AND resource.labels.service_name = ${google_cloud_run_v2_service.default.name}
-  AND "instance is invalid"
+  AND jsonPayload.event.schemaVersion = 1
+  AND jsonPayload.event.verdict = "invalid"
 EOF
 
   metric_descriptor {
     metric_kind = "DELTA"
     value_type  = "INT64"
     unit        = "1"
+
+    labels {
+      key         = "cluster"
+      value_type  = "STRING"
+      description = "The CAST cluster ID of the instance"
+    }
+    labels {
+      key         = "node_pool"
+      value_type  = "STRING"
+      description = "The node pool of the instance"
+    }
+  }
+
+  label_extractors = {
+    "cluster"   = "EXTRACT(jsonPayload.event.cluster)"
+    "node_pool" = "EXTRACT(jsonPayload.event.nodePool)"
   }
 }
 
@@ -150,13 +428,63 @@ resource "google_logging_metric" "valid_instances" {
   filter      = <<EOF
 resource.type = cloud_run_revision
   AND resource.labels.service_name = ${google_cloud_run_v2_service.default.name}
-  AND "instance is valid"
+  AND jsonPayload.event.schemaVersion = 1
+  AND jsonPayload.event.verdict = "valid"
+EOF
+
+  metric_descriptor {
+    metric_kind = "DELTA"
+    value_type  = "INT64"
+    unit        = "1"
+
+    labels {
+      key         = "cluster"
+      value_type  = "STRING"
+      description = "The CAST cluster ID of the instance"
+    }
+    labels {
+      key         = "node_pool"
+      value_type  = "STRING"
+      description = "The node pool of the instance"
+    }
+  }
+
+  label_extractors = {
+    "cluster"   = "EXTRACT(jsonPayload.event.cluster)"
+    "node_pool" = "EXTRACT(jsonPayload.event.nodePool)"
+  }
+}
+
+resource "google_logging_metric" "unverifiable_instances" {
+  name        = "${var.name_prefix}-unverifiable-instances"
+  description = "Count of instances that could not be validated"
+  filter      = <<EOF
+resource.type = cloud_run_revision
+  AND resource.labels.service_name = ${google_cloud_run_v2_service.default.name}
+  AND jsonPayload.event.schemaVersion = 1
+  AND jsonPayload.event.verdict = "unverifiable"
 EOF
 
   metric_descriptor {
     metric_kind = "DELTA"
     value_type  = "INT64"
     unit        = "1"
+
+    labels {
+      key         = "cluster"
+      value_type  = "STRING"
+      description = "The CAST cluster ID of the instance"
+    }
+    labels {
+      key         = "node_pool"
+      value_type  = "STRING"
+      description = "The node pool of the instance"
+    }
+  }
+
+  label_extractors = {
+    "cluster"   = "EXTRACT(jsonPayload.event.cluster)"
+    "node_pool" = "EXTRACT(jsonPayload.event.nodePool)"
   }
 }
 
@@ -179,9 +507,35 @@ resource "google_monitoring_alert_policy" "invalid_instances" {
       filter = <<EOF
 resource.type = cloud_run_revision
   AND resource.labels.service_name = ${google_cloud_run_v2_service.default.name}
-  AND "instance is invalid"
+  AND jsonPayload.event.schemaVersion = 1
+  AND jsonPayload.event.verdict = "invalid"
 EOF
     }
   }
 }
 
+resource "google_monitoring_alert_policy" "enforcement_breaker" {
+  display_name = "CAST Instance Validator Enforcement Circuit Breaker Alert Policy"
+  combiner     = "OR"
+
+  severity              = "CRITICAL"
+  notification_channels = var.alert_notification_channels
+
+  alert_strategy {
+    notification_rate_limit {
+      period = "300s"
+    }
+  }
+
+  conditions {
+    display_name = "Enforcement circuit breaker tripped log"
+    condition_matched_log {
+      filter = <<EOF
+resource.type = cloud_run_revision
+  AND resource.labels.service_name = ${google_cloud_run_v2_service.default.name}
+  AND severity = ERROR
+  AND jsonPayload.message =~ "^enforcement circuit breaker tripped"
+EOF
+    }
+  }
+}
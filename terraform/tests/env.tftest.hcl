@@ -0,0 +1,70 @@
+# Environment variables override the fields of the config file, so the validator must only get those of the inputs
+# which are set. Run with `terraform test`.
+
+mock_provider "google" {
+  mock_data "google_project" {
+    defaults = {
+      project_id = "my-project"
+    }
+  }
+}
+
+variables {
+  project         = "my-project"
+  region          = "europe-west1"
+  name_prefix     = "test"
+  validator_image = "europe-docker.pkg.dev/my-project/validator/validator:latest"
+  config_secret   = "validator-config"
+}
+
+run "default_inputs" {
+  command = plan
+
+  assert {
+    condition = toset([for env in google_cloud_run_v2_service.default.template[0].containers[0].env : "${env.name}=${env.value}"]) == toset([
+      "APP_PROJECTID=my-project",
+      "APP_WHITELISTBUCKET_NAME=test-vm-validator",
+      "APP_CONFIGFILE_PATH=/etc/node-validator/config.yaml",
+    ])
+    error_message = "Inputs left at their default must not override the config file."
+  }
+}
+
+run "set_inputs" {
+  command = plan
+
+  variables {
+    protection_mode           = "delete"
+    cluster_protection_modes  = { "cluster-1" = "log" }
+    exceptions                = [{ name = "debug", nodePool = "debug", reason = "INC-1234", expires = "2025-01-01T00:00:00Z" }]
+    enforcement_delay         = 300
+    breaker_max_actions       = 5
+    breaker_max_invalid_ratio = 0
+    kubernetes_drain          = true
+    cast_cluster_ids          = ["cluster-1", "cluster-2"]
+    admins                    = ["admin@example.com", "operator@example.com"]
+    findings_store_dsn        = "host=/cloudsql/my-project:europe-west1:findings dbname=findings"
+  }
+
+  assert {
+    condition = toset([for env in google_cloud_run_v2_service.default.template[0].containers[0].env : "${env.name}=${env.value}"]) == toset([
+      "APP_PROJECTID=my-project",
+      "APP_WHITELISTBUCKET_NAME=test-vm-validator",
+      "APP_CONFIGFILE_PATH=/etc/node-validator/config.yaml",
+      "APP_PROTECTIONMODE=delete",
+      "APP_CLUSTERPROTECTIONMODES=cluster-1:log",
+      "APP_EXCEPTIONS_LIST=[{\"cluster\":null,\"expires\":\"2025-01-01T00:00:00Z\",\"instanceName\":null,\"labels\":null,\"name\":\"debug\",\"nodePool\":\"debug\",\"reason\":\"INC-1234\"}]",
+      "APP_PENDING_DELAY=300",
+      "APP_PENDING_BUCKET=test-vm-validator-pending",
+      "APP_BREAKER_MAXACTIONS=5",
+      "APP_BREAKER_MAXINVALIDRATIO=0",
+      "APP_BREAKER_BUCKET=test-vm-validator-breaker",
+      "APP_KUBERNETES_DRAIN=true",
+      "APP_CLUSTERIDS=cluster-1,cluster-2",
+      "APP_ADMINS=admin@example.com,operator@example.com",
+      "APP_FINDINGSSTORE_DRIVER=pgx",
+      "APP_FINDINGSSTORE_DSN=host=/cloudsql/my-project:europe-west1:findings dbname=findings",
+    ])
+    error_message = "Inputs differing from their default must be set."
+  }
+}
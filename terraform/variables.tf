@@ -36,7 +36,225 @@ variable "alert_severity" {
 }
 
 variable "delete_mode" {
-  description = "Whether to delete invalid instances"
+  description = "Whether to delete invalid instances. Deprecated, use `protection_mode` instead"
   type        = bool
   default     = false
 }
+
+variable "admins" {
+  description = "The emails of the users and service accounts allowed to release quarantined instances, approve pending actions and reset the enforcement circuit breaker. They need the Cloud Run invoker role on the service too."
+  type        = list(string)
+  default     = []
+}
+
+variable "allowed_principals" {
+  description = <<EOF
+The principals allowed to create CAST instances, per CAST cluster ID. The `*` key applies to clusters without their own entry.
+Instances created by other principals are reported as invalid, even when their scripts are whitelisted.
+EOF
+  type        = map(list(string))
+  default     = {}
+}
+
+variable "quarantine_mode" {
+  description = "Whether to isolate invalid instances from the network instead of deleting them. Deprecated, use `protection_mode` instead"
+  type        = bool
+  default     = false
+}
+
+variable "quarantine_network" {
+  description = "The VPC network of the clusters, where firewall rules isolating quarantined instances are created. Required when any cluster uses the quarantine protection mode."
+  type        = string
+  default     = ""
+}
+
+variable "quarantine_tag" {
+  description = "The network tag set on quarantined instances"
+  type        = string
+  default     = "cast-quarantine"
+}
+
+variable "protection_mode" {
+  description = <<EOF
+The protection applied to invalid instances, one of `log`, `label`, `stop`, `suspend`, `quarantine` or `delete`.
+When empty, it is derived from `delete_mode` and `quarantine_mode`.
+EOF
+  type        = string
+  default     = ""
+
+  validation {
+    condition     = contains(["", "log", "label", "stop", "suspend", "quarantine", "delete"], var.protection_mode)
+    error_message = "The protection mode must be one of log, label, stop, suspend, quarantine or delete."
+  }
+}
+
+variable "cluster_protection_modes" {
+  description = "The protection applied to invalid instances, per CAST cluster ID. Overrides `protection_mode`."
+  type        = map(string)
+  default     = {}
+}
+
+variable "evidence_capture" {
+  description = "Whether to capture evidence of invalid instances into a GCS bucket, before they are stopped, suspended or deleted"
+  type        = bool
+  default     = false
+}
+
+variable "evidence_snapshot" {
+  description = "Whether to snapshot the boot disk of invalid instances as evidence. Requires `evidence_capture`."
+  type        = bool
+  default     = false
+}
+
+variable "instance_group_method" {
+  description = <<EOF
+How invalid instances managed by an instance group are deleted, so that the group does not recreate them.
+With `delete` they are deleted by the group, with `abandon` they are removed from the group and then deleted.
+EOF
+  type        = string
+  default     = "delete"
+
+  validation {
+    condition     = contains(["delete", "abandon"], var.instance_group_method)
+    error_message = "The instance group method must be one of delete or abandon."
+  }
+}
+
+variable "exceptions" {
+  description = <<EOF
+Exceptions exempting instances from validation until they expire. Each exception matches on any of `instanceName`, a glob pattern,
+`labels`, `cluster`, the CAST cluster ID or GKE cluster name, and `nodePool`. The `reason` and the RFC 3339 `expires` time are required.
+EOF
+  type = list(object({
+    name         = string
+    instanceName = optional(string)
+    labels       = optional(map(string))
+    cluster      = optional(string)
+    nodePool     = optional(string)
+    reason       = string
+    expires      = string
+  }))
+  default = []
+}
+
+variable "exceptions_bucket" {
+  description = "An existing GCS bucket holding a JSON array of exceptions in the `exceptions_object`, read in addition to `exceptions`"
+  type        = string
+  default     = ""
+}
+
+variable "exceptions_object" {
+  description = "The GCS object in `exceptions_bucket` holding a JSON array of exceptions"
+  type        = string
+  default     = "exceptions.json"
+}
+
+variable "enforcement_delay" {
+  description = "The grace period in seconds between finding an invalid instance and enforcing the protection mode on it, during which operators can approve the instance. Enforced immediately when 0."
+  type        = number
+  default     = 0
+}
+
+variable "breaker_max_actions" {
+  description = "The number of invalid instances of a cluster stopped, suspended, quarantined or deleted within `breaker_window`. Further invalid instances are only reported. Unlimited when 0."
+  type        = number
+  default     = 20
+}
+
+variable "breaker_window" {
+  description = "The time in seconds over which the enforcement circuit breaker counts actions and validated instances"
+  type        = number
+  default     = 600
+}
+
+variable "breaker_max_invalid_ratio" {
+  description = "The ratio of invalid instances of a cluster within `breaker_window` above which the enforcement circuit breaker trips. Disabled when 0."
+  type        = number
+  default     = 0.5
+}
+
+variable "kubernetes_drain" {
+  description = "Whether to cordon, taint and drain the Kubernetes node of invalid instances before they are stopped, suspended, quarantined or deleted"
+  type        = bool
+  default     = false
+}
+
+variable "kubernetes_eviction_timeout" {
+  description = "The time in seconds to wait for the pods of a drained node to be evicted"
+  type        = number
+  default     = 120
+}
+
+variable "tracing_endpoint" {
+  description = "The OTLP gRPC endpoint, as host:port, OpenTelemetry spans of the validation pipeline are exported to. Spans are not exported when empty."
+  type        = string
+  default     = ""
+}
+
+variable "tracing_sample_ratio" {
+  description = "The ratio of traces not started by Eventarc which are sampled"
+  type        = number
+  default     = 1
+}
+
+variable "findings_topic" {
+  description = "The name of a Pub/Sub topic created for the validation results of invalid and unverifiable instances, published as JSON with the CAST cluster ID as ordering key. Results are not published when empty."
+  type        = string
+  default     = ""
+}
+
+variable "findings_valid_results" {
+  description = "Whether the results of valid instances are published to `findings_topic` too"
+  type        = bool
+  default     = false
+}
+
+variable "notification_webhook_url" {
+  description = "A URL the validation results of invalid instances are posted to as JSON, signed with `notification_webhook_secret`"
+  type        = string
+  default     = ""
+}
+
+variable "notification_webhook_secret" {
+  description = "The shared secret signing the requests to `notification_webhook_url` with HMAC-SHA256. Required with `notification_webhook_url`."
+  type        = string
+  default     = ""
+  sensitive   = true
+}
+
+variable "slack_webhook_url" {
+  description = "The URL of a Slack incoming webhook notified of invalid instances"
+  type        = string
+  default     = ""
+  sensitive   = true
+}
+
+variable "scc_source" {
+  description = "An existing Security Command Center source, as `organizations/{organization}/sources/{source}`, receiving a finding for each invalid or unverifiable instance. Findings are not reported when empty."
+  type        = string
+  default     = ""
+
+  validation {
+    condition     = var.scc_source == "" || can(regex("^organizations/[0-9]+/sources/[0-9]+$", var.scc_source))
+    error_message = "The scc_source must be formatted as organizations/{organization}/sources/{source}."
+  }
+}
+
+variable "findings_store_dsn" {
+  description = "The PostgreSQL connection string of the database storing every validation result, e.g. `host=/cloudsql/project:region:instance user=validator password=secret dbname=validator` with `findings_store_cloudsql_instance`. Results are not stored when empty."
+  type        = string
+  default     = ""
+  sensitive   = true
+}
+
+variable "findings_store_cloudsql_instance" {
+  description = "The connection name, as project:region:instance, of an existing Cloud SQL for PostgreSQL instance hosting the findings store, mounted in /cloudsql"
+  type        = string
+  default     = ""
+}
+
+variable "config_secret" {
+  description = "An existing Secret Manager secret, in the project, holding the YAML config file of the validator. Its latest version is mounted and reloaded on change. The other inputs override its fields, unless they are left at their default."
+  type        = string
+  default     = ""
+}